@@ -0,0 +1,185 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeWAV encodes the samples (in [-1, 1]) as a WAV file, copying them
+// to all channels
+func makeWAV(samples []float64, sampleRate int, channels int, bits int, float bool) []byte {
+	data := &bytes.Buffer{}
+	for _, sample := range samples {
+		for channel := 0; channel < channels; channel++ {
+			switch {
+			case float:
+				binary.Write(data, binary.LittleEndian, float32(sample))
+			case bits == 8:
+				data.WriteByte(byte(sample*127 + 128))
+			case bits == 16:
+				binary.Write(data, binary.LittleEndian, int16(sample*32767))
+			case bits == 24:
+				value := int32(sample * 8388607)
+				data.Write([]byte{byte(value), byte(value >> 8), byte(value >> 16)})
+			}
+		}
+	}
+
+	tag := uint16(wavPCM)
+	if float {
+		tag = wavFloat
+	}
+
+	wav := &bytes.Buffer{}
+	wav.WriteString("RIFF")
+	binary.Write(wav, binary.LittleEndian, uint32(36+12+data.Len()))
+	wav.WriteString("WAVE")
+	wav.WriteString("LIST")
+	binary.Write(wav, binary.LittleEndian, uint32(3))
+	wav.WriteString("foo\x00")
+	wav.WriteString("fmt ")
+	for _, value := range []interface{}{
+		uint32(16), tag, uint16(channels), uint32(sampleRate),
+		uint32(sampleRate * channels * bits / 8), uint16(channels * bits / 8), uint16(bits),
+	} {
+		binary.Write(wav, binary.LittleEndian, value)
+	}
+	wav.WriteString("data")
+	binary.Write(wav, binary.LittleEndian, uint32(data.Len()))
+	wav.Write(data.Bytes())
+	return wav.Bytes()
+}
+
+func readAll(t *testing.T, r *WAVReader) []float64 {
+	var samples []float64
+	buf := make([]float64, 7)
+	for {
+		n, err := r.Read(buf)
+		samples = append(samples, buf[:n]...)
+		if err == io.EOF {
+			return samples
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWAVReader(t *testing.T) {
+	samples := []float64{0, 0.5, -0.5, 0.25, -1, 0.99}
+
+	for _, format := range []struct {
+		channels int
+		bits     int
+		float    bool
+	}{
+		{1, 8, false},
+		{2, 16, false},
+		{1, 24, false},
+		{3, 32, true},
+	} {
+		r, err := NewWAVReader(bytes.NewReader(
+			makeWAV(samples, 8000, format.channels, format.bits, format.float),
+		))
+		if err != nil {
+			t.Errorf("unable to read %+v: %s", format, err)
+			continue
+		}
+
+		assert.Equal(t, 8000, r.SampleRate)
+		assert.Equal(t, format.channels, r.Channels)
+
+		read := readAll(t, r)
+		assert.Equal(t, len(samples), len(read))
+		for i := range read {
+			assert.InDelta(t, samples[i], read[i], 0.02, "%+v", format)
+		}
+	}
+
+	_, err := NewWAVReader(bytes.NewReader([]byte("RIFF\x04\x00\x00\x00AVI ")))
+	assert.NotNil(t, err)
+
+	wav := makeWAV(samples, 8000, 1, 16, false)
+	binary.LittleEndian.PutUint16(wav[32:34], 0x0055) // mp3
+	_, err = NewWAVReader(bytes.NewReader(wav))
+	assert.NotNil(t, err)
+
+	_, err = NewWAVReader(bytes.NewReader(makeWAV(samples, 50, 1, 16, false)))
+	assert.NotNil(t, err)
+}
+
+// speechAudio makes the given number of seconds of quiet noise, with a
+// tone during the given seconds (as if someone's talking then)
+func speechAudio(seconds int, speech map[int]bool, sampleRate int) []float64 {
+	random := rand.New(rand.NewSource(42))
+
+	samples := make([]float64, seconds*sampleRate)
+	for i := range samples {
+		samples[i] = random.Float64()*0.002 - 0.001
+		if speech[i/sampleRate] {
+			samples[i] += 0.3 * math.Sin(2*math.Pi*800*float64(i)/float64(sampleRate))
+		}
+	}
+	return samples
+}
+
+func TestDetectSpeech(t *testing.T) {
+	r, err := NewWAVReader(bytes.NewReader(makeWAV(
+		speechAudio(6, map[int]bool{1: true, 2: true, 4: true}, 16000), 16000, 1, 16, false,
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	speech, err := DetectSpeech(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	framesPerSecond := int(time.Second / FrameDuration)
+	assert.Equal(t, 6*framesPerSecond, len(speech))
+
+	for i, expected := range []bool{false, true, true, false, true, false} {
+		// the filters need a few frames to settle
+		for frame := i*framesPerSecond + 3; frame < (i+1)*framesPerSecond-3; frame++ {
+			if speech[frame] != expected {
+				t.Errorf("speech at frame %d should be %v", frame, expected)
+				break
+			}
+		}
+	}
+
+	r, err = NewWAVReader(bytes.NewReader(makeWAV(make([]float64, 8000), 8000, 1, 16, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	speech, err = DetectSpeech(context.Background(), r)
+	assert.Nil(t, err)
+	assert.Equal(t, make([]bool, 100), speech)
+
+	r, err = NewWAVReader(bytes.NewReader(makeWAV(make([]float64, 8000), 8000, 1, 16, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = DetectSpeech(ctx, r)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestFillRuns(t *testing.T) {
+	frames := []bool{true, false, true, true, false, false, false, true, false, false}
+	fillRuns(frames, false, 2, true)
+	assert.Equal(t, []bool{true, true, true, true, false, false, false, true, false, false}, frames)
+
+	fillRuns(frames, true, 2, false)
+	assert.Equal(t, []bool{true, true, true, true, false, false, false, false, false, false}, frames)
+}
@@ -0,0 +1,8 @@
+// Package audio reads uncompressed WAV audio and detects in which parts
+// of it there's speech, so that subtitles can be synchronised to it.
+//
+// Compressed audio tracks aren't decoded: the track has to be extracted
+// to a WAV file first, for example with
+//
+//	ffmpeg -i Movie.mkv -vn -ac 1 -ar 16000 Movie.wav
+package audio
@@ -0,0 +1,188 @@
+package audio
+
+import (
+	"context"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// FrameDuration is the duration of the frames in which speech is detected
+const FrameDuration = 10 * time.Millisecond
+
+// The detector only looks at the frequencies of the human voice, and
+// ignores sounds which are too short to be speech or pauses which are
+// too short to be anything more than a break between words.
+const (
+	voiceLowFrequency  = 300
+	voiceHighFrequency = 3400
+	minSpeechFrames    = 5
+	maxPauseFrames     = 20
+)
+
+// Energy levels (in dB relative to full scale) used to find the
+// threshold above which a frame is considered to be speech
+const (
+	// silenceLevel is below the noise of any real recording, so quieter
+	// frames are digital silence, and are treated as if they're this loud
+	silenceLevel = -90
+	// minSpeechMargin is how much louder than the noise speech is at least
+	minSpeechMargin = 6
+)
+
+// DetectSpeech reads the whole audio, and tells for each of its frames
+// (of FrameDuration) whether there's speech in it. The detection is based
+// on the energy of the voice frequencies in each frame, compared to the
+// level of the background noise in the whole audio. Reading the audio
+// stops with ctx's error when ctx is cancelled.
+func DetectSpeech(ctx context.Context, r *WAVReader) ([]bool, error) {
+	levels, err := frameLevels(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, ok := speechThreshold(levels)
+	speech := make([]bool, len(levels))
+	if !ok {
+		return speech, nil
+	}
+
+	for i := range levels {
+		speech[i] = levels[i] > threshold
+	}
+
+	fillRuns(speech, true, minSpeechFrames, false)
+	fillRuns(speech, false, maxPauseFrames, true)
+	return speech, nil
+}
+
+// frameLevels returns the energy (in dB) of the voice frequencies in each
+// frame of the audio
+func frameLevels(ctx context.Context, r *WAVReader) ([]float64, error) {
+	filters := []*biquad{highPass(voiceLowFrequency, r.SampleRate)}
+	if voiceHighFrequency < 0.45*float64(r.SampleRate) {
+		filters = append(filters, lowPass(voiceHighFrequency, r.SampleRate))
+	}
+
+	frameSize := int(int64(r.SampleRate) * int64(FrameDuration) / int64(time.Second))
+	samples := make([]float64, frameSize)
+
+	var levels []float64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		n, err := r.Read(samples)
+		if n > 0 {
+			var energy float64
+			for _, sample := range samples[:n] {
+				for _, filter := range filters {
+					sample = filter.apply(sample)
+				}
+				energy += sample * sample
+			}
+			levels = append(levels, 10*math.Log10(energy/float64(n)+1e-20))
+		}
+
+		if err == io.EOF {
+			return levels, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			// a reader which doesn't advance would never reach the end
+			return nil, io.ErrNoProgress
+		}
+	}
+}
+
+// speechThreshold finds the level above which frames are speech: a bit
+// above the noise floor, which is the level of the quietest frames.
+// Returns false if the audio is silent.
+func speechThreshold(levels []float64) (float64, bool) {
+	if len(levels) == 0 {
+		return 0, false
+	}
+
+	sorted := make([]float64, len(levels))
+	for i, level := range levels {
+		sorted[i] = math.Max(level, silenceLevel)
+	}
+	sort.Float64s(sorted)
+
+	noise := sorted[len(sorted)/10]
+	loud := sorted[len(sorted)*95/100]
+	if loud <= silenceLevel {
+		return 0, false
+	}
+	return noise + math.Max(minSpeechMargin, (loud-noise)/3), true
+}
+
+// fillRuns sets the frames of each run of the given value which is
+// shorter than minLength to the opposite value. If inner is true, only
+// runs which are surrounded by other frames are filled.
+func fillRuns(frames []bool, value bool, minLength int, inner bool) {
+	for start := 0; start < len(frames); {
+		if frames[start] != value {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(frames) && frames[end] == value {
+			end++
+		}
+
+		surrounded := start > 0 && end < len(frames)
+		if end-start < minLength && (surrounded || !inner) {
+			for i := start; i < end; i++ {
+				frames[i] = !value
+			}
+		}
+		start = end
+	}
+}
+
+// biquad is a second order IIR filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// highPass and lowPass make Butterworth filters with the given cutoff
+// frequency, as in the Audio EQ Cookbook
+func highPass(frequency float64, sampleRate int) *biquad {
+	w, alpha := filterParameters(frequency, sampleRate)
+	return newBiquad((1+math.Cos(w))/2, -(1 + math.Cos(w)), (1+math.Cos(w))/2, w, alpha)
+}
+
+func lowPass(frequency float64, sampleRate int) *biquad {
+	w, alpha := filterParameters(frequency, sampleRate)
+	return newBiquad((1-math.Cos(w))/2, 1-math.Cos(w), (1-math.Cos(w))/2, w, alpha)
+}
+
+func filterParameters(frequency float64, sampleRate int) (float64, float64) {
+	w := 2 * math.Pi * frequency / float64(sampleRate)
+	return w, math.Sin(w) / math.Sqrt2
+}
+
+func newBiquad(b0, b1, b2, w, alpha float64) *biquad {
+	a0 := 1 + alpha
+	return &biquad{
+		b0: b0 / a0,
+		b1: b1 / a0,
+		b2: b2 / a0,
+		a1: -2 * math.Cos(w) / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *biquad) apply(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
@@ -0,0 +1,183 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV format tags
+const (
+	wavPCM        = 0x0001
+	wavFloat      = 0x0003
+	wavExtensible = 0xfffe
+)
+
+// Sample rates outside this range aren't real audio, most likely the
+// header is broken
+const (
+	minSampleRate = 1000
+	maxSampleRate = 768000
+)
+
+// WAVReader reads the samples of an uncompressed WAV file, mixing all
+// channels down to mono
+type WAVReader struct {
+	SampleRate int
+	Channels   int
+
+	bits  int
+	float bool
+
+	r *bufio.Reader
+	// remaining is the number of bytes left in the data chunk, or -1 if
+	// its size is unknown (as in WAVs streamed to a pipe)
+	remaining int64
+	frame     []byte
+}
+
+// NewWAVReader reads the WAV header, leaving r at the beginning of the
+// samples. Only integer PCM (8, 16, 24 or 32 bits) and floating point
+// (32 or 64 bits) samples are supported.
+func NewWAVReader(r io.Reader) (*WAVReader, error) {
+	w := &WAVReader{r: bufio.NewReader(r)}
+
+	id, _, err := w.readChunkHeader()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read WAV header: %s", err)
+	}
+	format := make([]byte, 4)
+	if _, err = io.ReadFull(w.r, format); err != nil {
+		return nil, fmt.Errorf("unable to read WAV header: %s", err)
+	}
+	if id != "RIFF" || string(format) != "WAVE" {
+		return nil, fmt.Errorf("not a WAV file")
+	}
+
+	haveFormat := false
+	for {
+		id, size, err := w.readChunkHeader()
+		if err != nil {
+			return nil, fmt.Errorf("unable to find WAV data: %s", err)
+		}
+
+		switch id {
+		case "fmt ":
+			if err = w.readFormat(size); err != nil {
+				return nil, err
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, fmt.Errorf("WAV data before its format")
+			}
+			w.remaining = size
+			if size == 0 || size == math.MaxUint32 {
+				w.remaining = -1
+			}
+			return w, nil
+		default:
+			if _, err = w.r.Discard(int(size + size%2)); err != nil {
+				return nil, fmt.Errorf("unable to find WAV data: %s", err)
+			}
+		}
+	}
+}
+
+func (w *WAVReader) readChunkHeader() (string, int64, error) {
+	header := make([]byte, 8)
+	_, err := io.ReadFull(w.r, header)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(header[0:4]), int64(binary.LittleEndian.Uint32(header[4:8])), nil
+}
+
+func (w *WAVReader) readFormat(size int64) error {
+	if size < 16 {
+		return fmt.Errorf("invalid WAV format chunk")
+	}
+	data := make([]byte, size+size%2)
+	if _, err := io.ReadFull(w.r, data); err != nil {
+		return fmt.Errorf("unable to read WAV format: %s", err)
+	}
+
+	tag := binary.LittleEndian.Uint16(data[0:2])
+	w.Channels = int(binary.LittleEndian.Uint16(data[2:4]))
+	w.SampleRate = int(binary.LittleEndian.Uint32(data[4:8]))
+	w.bits = int(binary.LittleEndian.Uint16(data[14:16]))
+
+	if tag == wavExtensible && size >= 26 {
+		// the actual tag is at the beginning of the subformat GUID
+		tag = binary.LittleEndian.Uint16(data[24:26])
+	}
+
+	switch {
+	case tag == wavPCM && (w.bits == 8 || w.bits == 16 || w.bits == 24 || w.bits == 32):
+	case tag == wavFloat && (w.bits == 32 || w.bits == 64):
+		w.float = true
+	default:
+		return fmt.Errorf(
+			"unsupported WAV format %#04x with %d bits per sample (only PCM and floating point are supported)",
+			tag, w.bits,
+		)
+	}
+
+	if w.Channels == 0 || w.SampleRate < minSampleRate || w.SampleRate > maxSampleRate {
+		return fmt.Errorf("invalid WAV format: %d channels at %d Hz", w.Channels, w.SampleRate)
+	}
+
+	w.frame = make([]byte, w.Channels*w.bits/8)
+	return nil
+}
+
+// Read reads mono samples in the range [-1, 1] into the buffer, and returns
+// their count. At the end of the data it returns io.EOF.
+func (w *WAVReader) Read(samples []float64) (int, error) {
+	for i := range samples {
+		if w.remaining >= 0 && w.remaining < int64(len(w.frame)) {
+			return i, io.EOF
+		}
+
+		_, err := io.ReadFull(w.r, w.frame)
+		if err == io.ErrUnexpectedEOF && w.remaining < 0 {
+			err = io.EOF
+		}
+		if err != nil {
+			return i, err
+		}
+		if w.remaining >= 0 {
+			w.remaining -= int64(len(w.frame))
+		}
+
+		var sum float64
+		size := w.bits / 8
+		for channel := 0; channel < w.Channels; channel++ {
+			sum += w.sample(w.frame[channel*size : (channel+1)*size])
+		}
+		samples[i] = sum / float64(w.Channels)
+	}
+	return len(samples), nil
+}
+
+// sample decodes a single little-endian sample
+func (w *WAVReader) sample(data []byte) float64 {
+	switch {
+	case w.float && w.bits == 32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	case w.float:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data))
+	case w.bits == 8:
+		// 8-bit samples are the only unsigned ones
+		return (float64(data[0]) - 128) / 128
+	case w.bits == 16:
+		return float64(int16(binary.LittleEndian.Uint16(data))) / (1 << 15)
+	case w.bits == 24:
+		value := int32(data[0])<<8 | int32(data[1])<<16 | int32(data[2])<<24
+		return float64(value>>8) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(data))) / (1 << 31)
+	}
+}
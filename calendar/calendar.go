@@ -0,0 +1,183 @@
+package calendar
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is something which happens on a given day
+type Event struct {
+	Date    time.Time
+	Summary string
+	// UID uniquely identifies the event, so that calendar applications
+	// can update it when the date changes
+	UID string
+}
+
+// Month renders a grid of the month which contains the given date,
+// followed by a list of the events in that month. Days with events are
+// marked with an asterisk.
+func Month(w io.Writer, date time.Time, events []Event) error {
+	first := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	next := first.AddDate(0, 1, 0)
+
+	inMonth := eventsBetween(events, first, next)
+	marked := make(map[int]bool)
+	for _, event := range inMonth {
+		marked[event.Date.Day()] = true
+	}
+
+	header := first.Format("January 2006")
+	text := &strings.Builder{}
+	fmt.Fprintf(text, "%s%s\n", strings.Repeat(" ", (27-len(header))/2), header)
+	fmt.Fprintf(text, " Mo  Tu  We  Th  Fr  Sa  Su\n")
+
+	// weeks start on monday
+	column := (int(first.Weekday()) + 6) % 7
+	week := strings.Repeat("    ", column)
+
+	for day := first; day.Before(next); day = day.AddDate(0, 0, 1) {
+		marker := " "
+		if marked[day.Day()] {
+			marker = "*"
+		}
+		week += fmt.Sprintf(" %2d%s", day.Day(), marker)
+
+		column++
+		if column == 7 {
+			fmt.Fprintf(text, "%s\n", strings.TrimRight(week, " "))
+			week = ""
+			column = 0
+		}
+	}
+	if column != 0 {
+		fmt.Fprintf(text, "%s\n", strings.TrimRight(week, " "))
+	}
+
+	if len(inMonth) > 0 {
+		text.WriteString("\n")
+	}
+	for _, event := range inMonth {
+		fmt.Fprintf(text, "%s  %s\n", event.Date.Format("Mon 02"), event.Summary)
+	}
+
+	_, err := io.WriteString(w, text.String())
+	return err
+}
+
+// Agenda renders the events day by day for the given number of days,
+// starting with the day of start
+func Agenda(w io.Writer, start time.Time, days int, events []Event) error {
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+
+	text := &strings.Builder{}
+	for i := 0; i < days; i++ {
+		day := first.AddDate(0, 0, i)
+		fmt.Fprintf(text, "%s\n", day.Format("Mon 02 Jan 2006"))
+
+		dayEvents := eventsBetween(events, day, day.AddDate(0, 0, 1))
+		if len(dayEvents) == 0 {
+			text.WriteString("    -\n")
+		}
+		for _, event := range dayEvents {
+			fmt.Fprintf(text, "    %s\n", event.Summary)
+		}
+	}
+
+	_, err := io.WriteString(w, text.String())
+	return err
+}
+
+// ICS writes the events as an iCalendar file with all-day events.
+// stamp is the time at which the calendar is generated.
+func ICS(w io.Writer, events []Event, stamp time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//mvm//mvm//EN",
+		"CALSCALE:GREGORIAN",
+	}
+
+	for _, event := range sortedEvents(events) {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escapeText(event.UID),
+			"DTSTAMP:"+stamp.UTC().Format("20060102T150405Z"),
+			"DTSTART;VALUE=DATE:"+event.Date.Format("20060102"),
+			"DTEND;VALUE=DATE:"+event.Date.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+escapeText(event.Summary),
+			"END:VEVENT",
+		)
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	text := &strings.Builder{}
+	for _, line := range lines {
+		text.WriteString(foldLine(line))
+		text.WriteString("\r\n")
+	}
+
+	_, err := io.WriteString(w, text.String())
+	return err
+}
+
+// eventsBetween returns the events in [start, end), sorted by date
+func eventsBetween(events []Event, start time.Time, end time.Time) []Event {
+	var result []Event
+	for _, event := range sortedEvents(events) {
+		date := dateOf(event.Date)
+		if !date.Before(start) && date.Before(end) {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+func sortedEvents(events []Event) []Event {
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+	return sorted
+}
+
+// dateOf strips the time of day (and location) from a time
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\n", `\n`,
+)
+
+// escapeText escapes a value of type TEXT (RFC 5545, 3.3.11)
+func escapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// foldLine splits lines longer than 75 octets (RFC 5545, 3.1),
+// taking care not to split multi-byte characters
+func foldLine(line string) string {
+	const maxLength = 75
+
+	text := &strings.Builder{}
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > maxLength {
+			text.WriteString("\r\n ")
+			length = 1
+		}
+		text.WriteRune(r)
+		length += size
+	}
+	return text.String()
+}
@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+var testEvents = []Event{
+	{Date: date(2016, time.June, 19), Summary: "Game of Thrones S06E09", UID: "tt4283088@mvm"},
+	{Date: date(2016, time.June, 5), Summary: "Game of Thrones S06E07", UID: "tt4283060@mvm"},
+	{Date: date(2016, time.June, 12), Summary: "Game of Thrones S06E08", UID: "tt4283074@mvm"},
+	{Date: date(2016, time.July, 1), Summary: "Some Movie", UID: "tt0000001@mvm"},
+}
+
+func ExampleMonth() {
+	_ = Month(os.Stdout, date(2016, time.June, 10), testEvents)
+
+	// Output:
+	//          June 2016
+	//  Mo  Tu  We  Th  Fr  Sa  Su
+	//           1   2   3   4   5*
+	//   6   7   8   9  10  11  12*
+	//  13  14  15  16  17  18  19*
+	//  20  21  22  23  24  25  26
+	//  27  28  29  30
+	//
+	// Sun 05  Game of Thrones S06E07
+	// Sun 12  Game of Thrones S06E08
+	// Sun 19  Game of Thrones S06E09
+}
+
+func ExampleAgenda() {
+	_ = Agenda(os.Stdout, date(2016, time.June, 18), 3, testEvents)
+
+	// Output:
+	// Sat 18 Jun 2016
+	//     -
+	// Sun 19 Jun 2016
+	//     Game of Thrones S06E09
+	// Mon 20 Jun 2016
+	//     -
+}
+
+func ExampleICS() {
+	buf := &bytes.Buffer{}
+	_ = ICS(buf, testEvents[:1], time.Date(2016, time.June, 1, 12, 0, 0, 0, time.UTC))
+
+	fmt.Print(strings.Replace(buf.String(), "\r\n", "\n", -1))
+
+	// Output:
+	// BEGIN:VCALENDAR
+	// VERSION:2.0
+	// PRODID:-//mvm//mvm//EN
+	// CALSCALE:GREGORIAN
+	// BEGIN:VEVENT
+	// UID:tt4283088@mvm
+	// DTSTAMP:20160601T120000Z
+	// DTSTART;VALUE=DATE:20160619
+	// DTEND;VALUE=DATE:20160620
+	// SUMMARY:Game of Thrones S06E09
+	// END:VEVENT
+	// END:VCALENDAR
+}
+
+func TestICSEscaping(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := ICS(buf, []Event{{
+		Date:    date(2016, time.June, 19),
+		Summary: "Foo, Bar; Baz\\ " + strings.Repeat("ж", 40),
+		UID:     "1@mvm",
+	}}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		assert.True(len(line) <= 75, "line too long: %s", line)
+	}
+
+	unfolded := strings.Replace(buf.String(), "\r\n ", "", -1)
+	assert.Contains(
+		unfolded,
+		`SUMMARY:Foo\, Bar\; Baz\\ `+strings.Repeat("ж", 40)+"\r\n",
+	)
+}
@@ -0,0 +1,3 @@
+// Package calendar renders dated events (such as episode air dates) as
+// a month grid, a day-by-day agenda or an iCalendar (.ics) file.
+package calendar
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/DexterLB/mvm/calendar"
+	"github.com/DexterLB/mvm/library"
+	"github.com/codegangsta/cli"
+)
+
+// maxCalendarMonths limits the number of months shown by the month calendar
+const maxCalendarMonths = 12
+
+func runMissing(c *cli.Context) {
+	config := parseConfig(c)
+	lib := openLibrary(config)
+
+	episodes, err := lib.MissingEpisodes(time.Now())
+	if err != nil {
+		log.Fatalf("unable to get missing episodes: %s", err)
+	}
+
+	var (
+		seriesID     uint
+		season       = -1
+		seriesTitles = make(map[uint]string)
+	)
+	for _, episode := range episodes {
+		if episode.SeriesID != seriesID {
+			fmt.Printf("%s\n", seriesTitle(lib, episode, seriesTitles))
+			seriesID = episode.SeriesID
+			season = -1
+		}
+		if episode.Season != season {
+			fmt.Printf("    Season %d\n", episode.Season)
+			season = episode.Season
+		}
+		fmt.Printf(
+			"        S%02dE%02d %s (%s)\n",
+			episode.Season, episode.Episode, episode.Title,
+			episode.ReleaseDate.Format("2006-01-02"),
+		)
+	}
+}
+
+func runUpcoming(c *cli.Context) {
+	config := parseConfig(c)
+	lib := openLibrary(config)
+
+	now := time.Now()
+	episodes, err := lib.UpcomingEpisodes(now)
+	if err != nil {
+		log.Fatalf("unable to get upcoming episodes: %s", err)
+	}
+	events := episodeEvents(lib, episodes)
+
+	if filename := c.String("ics"); filename != "" {
+		writeICS(filename, events, now)
+		return
+	}
+
+	switch c.String("calendar") {
+	case "":
+		for _, event := range events {
+			fmt.Printf("%s  %s\n", event.Date.Format("2006-01-02"), event.Summary)
+		}
+	case "month":
+		months := 1
+		if len(events) > 0 {
+			last := events[len(events)-1].Date
+			months = 12*(last.Year()-now.Year()) + int(last.Month()-now.Month()) + 1
+		}
+		if months > maxCalendarMonths {
+			months = maxCalendarMonths
+		}
+
+		for i := 0; i < months; i++ {
+			if i > 0 {
+				fmt.Printf("\n")
+			}
+			month := time.Date(now.Year(), now.Month()+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+			err = calendar.Month(os.Stdout, month, events)
+			if err != nil {
+				log.Fatalf("unable to render calendar: %s", err)
+			}
+		}
+	case "week":
+		err = calendar.Agenda(os.Stdout, now, 7, events)
+		if err != nil {
+			log.Fatalf("unable to render calendar: %s", err)
+		}
+	default:
+		log.Fatalf("unknown calendar type: %s (use month or week)", c.String("calendar"))
+	}
+}
+
+// writeICS writes the events to an iCalendar file (or to stdout if
+// filename is -)
+func writeICS(filename string, events []calendar.Event, now time.Time) {
+	if filename == "-" {
+		err := calendar.ICS(os.Stdout, events, now)
+		if err != nil {
+			log.Fatalf("unable to write calendar: %s", err)
+		}
+		return
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Fatalf("unable to create calendar file: %s", err)
+	}
+
+	err = calendar.ICS(f, events, now)
+	if err != nil {
+		_ = f.Close()
+		log.Fatalf("unable to write calendar: %s", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		log.Fatalf("unable to write calendar: %s", err)
+	}
+}
+
+func episodeEvents(lib *library.Library, episodes []*library.Show) []calendar.Event {
+	seriesTitles := make(map[uint]string)
+
+	events := make([]calendar.Event, len(episodes))
+	for i, episode := range episodes {
+		events[i] = calendar.Event{
+			Date:    episode.ReleaseDate,
+			Summary: showName(lib, episode, seriesTitles),
+			UID:     fmt.Sprintf("tt%07d@mvm", episode.ImdbID),
+		}
+	}
+	return events
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/codegangsta/cli"
+)
+
+func runList(c *cli.Context) {
+	config := parseConfig(c)
+	lib := openLibrary(config)
+
+	shows, err := lib.Query(strings.Join(c.Args(), " "))
+	if err != nil {
+		log.Fatalf("unable to query library: %s", err)
+	}
+
+	seriesTitles := make(map[uint]string)
+	for _, show := range shows {
+		fmt.Printf("%s\n", showName(lib, show, seriesTitles))
+		for _, file := range show.Files {
+			fmt.Printf("    %s\n", file.Path)
+		}
+	}
+}
+
+// showName formats the show's name for displaying. Episodes are prefixed
+// by their series' title, which is cached in seriesTitles.
+func showName(lib *library.Library, show *library.Show, seriesTitles map[uint]string) string {
+	if show.SeriesID == 0 {
+		return fmt.Sprintf("%s (%d)", show.Title, show.Year)
+	}
+
+	return fmt.Sprintf(
+		"%s S%02dE%02d %s",
+		seriesTitle(lib, show, seriesTitles), show.Season, show.Episode, show.Title,
+	)
+}
+
+// seriesTitle returns the title of the episode's series, caching it in
+// seriesTitles
+func seriesTitle(lib *library.Library, episode *library.Show, seriesTitles map[uint]string) string {
+	title, ok := seriesTitles[episode.SeriesID]
+	if ok {
+		return title
+	}
+
+	series, err := lib.GetSeriesByEpisode(episode)
+	if err != nil {
+		log.Printf("unable to get series for %s: %s", episode.Title, err)
+	}
+	if series != nil {
+		title = series.Title
+	}
+	seriesTitles[episode.SeriesID] = title
+	return title
+}
@@ -2,15 +2,20 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/DexterLB/mvm/config"
+	"github.com/DexterLB/mvm/imdb"
 	"github.com/DexterLB/mvm/importer"
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/types"
 	"github.com/cep21/xdgbasedir"
 	"github.com/codegangsta/cli"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
@@ -55,58 +60,138 @@ const (
 	abort
 )
 
+func confirm(input *bufio.Scanner, question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	if !input.Scan() {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(input.Text()))
+	return answer == "y" || answer == "yes"
+}
+
+// maxSuggestions is the number of imdb search results offered when
+// manually identifying a file
+const maxSuggestions = 10
+
+func printSuggestions(suggestions []*imdb.ShortItem) {
+	if len(suggestions) == 0 {
+		fmt.Printf("no suggestions found on imdb.\n")
+		return
+	}
+
+	fmt.Printf("suggestions from imdb:\n")
+	for i, item := range suggestions {
+		fmt.Printf(" [%d] %s (%d) (tt%07d)\n", i+1, item.Title, item.Year, item.ID)
+	}
+}
+
+func suggest(
+	ctx context.Context,
+	importer *importer.Context,
+	file *library.VideoFile,
+	text string,
+) []*imdb.ShortItem {
+	suggestions, err := importer.Suggestions(ctx, file, text)
+	if err != nil {
+		fmt.Printf("unable to get suggestions: %s\n", err)
+		return nil
+	}
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	printSuggestions(suggestions)
+	return suggestions
+}
+
 func manualImport(
+	ctx context.Context,
 	c *cli.Context,
 	importer *importer.Context,
 	shows chan<- library.ShowWithFile,
 	file *library.VideoFile,
+	suggestions *[]*imdb.ShortItem,
 ) userState {
 	input := bufio.NewScanner(os.Stdin)
 
 	fmt.Printf(
-		"What do you want to do? Manually enter [imdb id or link], [f] forget the file, [d] delete the file: ",
+		"What do you want to do? Choose a [number] from the suggestions, enter an [imdb id or link], a [title] to search for, [f] forget the file, [d] delete the file: ",
 	)
 
 	if !input.Scan() {
 		return abort
 	}
-	text := input.Text()
+	text := strings.TrimSpace(input.Text())
 
 	switch text {
-	case "f":
-		log.Printf("not implemented")
+	case "":
 		return retry
+	case "f":
+		err := importer.Library.RemoveFile(file)
+		if err != nil {
+			fmt.Printf("unable to forget file: %s\n", err)
+			return retry
+		}
+		fmt.Printf("forgot %s\n", file.Path)
+		return success
 	case "d":
-		log.Printf("not implemented")
-		return retry
-	case "a":
-		return abort
-	default:
-		imdbID, err := strconv.Atoi(text)
+		if !confirm(input, fmt.Sprintf(
+			"Really delete %s and its subtitles from disk?", file.Path,
+		)) {
+			return retry
+		}
+		err := importer.DeleteFile(file)
 		if err != nil {
-			fmt.Printf("unable to read imdb id: %s\n", err)
+			fmt.Printf("unable to delete file: %s\n", err)
 			return retry
 		}
-		show, err := importer.Library.GetShowByImdbID(imdbID)
+		fmt.Printf("deleted %s\n", file.Path)
+		return success
+	case "a":
+		return abort
+	}
+
+	if number, err := strconv.Atoi(text); err == nil && number >= 1 && number <= len(*suggestions) {
+		imdbID, err := importer.SuggestionID(ctx, file, (*suggestions)[number-1])
 		if err != nil {
-			fmt.Printf("unable to get parse imdb id: %s\n", err)
+			fmt.Printf("unable to identify file: %s\n", err)
 			return retry
 		}
-		fmt.Printf("adding show with imdb id %d\n", imdbID)
+		return identify(importer, shows, file, imdbID)
+	}
 
-		file.OsdbError = nil
+	if imdbID, err := imdb.ParseID(text); err == nil {
+		return identify(importer, shows, file, imdbID)
+	}
 
-		show.Files = append(show.Files, file)
-		shows <- library.ShowWithFile{
-			Show: show,
-			File: file,
-		}
+	*suggestions = suggest(ctx, importer, file, text)
+	return retry
+}
 
-		return success
+func identify(
+	importer *importer.Context,
+	shows chan<- library.ShowWithFile,
+	file *library.VideoFile,
+	imdbID int,
+) userState {
+	show, err := importer.Library.GetShowByImdbID(imdbID)
+	if err != nil {
+		fmt.Printf("unable to get show with imdb id: %s\n", err)
+		return retry
+	}
+	fmt.Printf("adding show with imdb id %d\n", imdbID)
+
+	file.Step(library.OsdbStep).Succeed()
+
+	show.Files = append(show.Files, file)
+	shows <- library.ShowWithFile{
+		Show: show,
+		File: file,
 	}
+
+	return success
 }
 
-func fixFileErrors(c *cli.Context, importer *importer.Context) {
+func fixFileErrors(ctx context.Context, c *cli.Context, importer *importer.Context) {
 	files := importer.FilesWithErrors
 	fmt.Printf(
 		"%d of the files have errors. Let's walk through them:\n", len(files),
@@ -117,37 +202,45 @@ func fixFileErrors(c *cli.Context, importer *importer.Context) {
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
-		importer.ProcessShows(shows)
+		importer.ProcessShows(ctx, shows)
 		wg.Done()
 	}()
 	defer wg.Wait()
 	defer close(shows)
 
 	for i := range files {
+		if ctx.Err() != nil {
+			fmt.Printf("aborting.\n")
+			return
+		}
+
 		fmt.Printf("[%s]\n", files[i].Path)
-		if files[i].ImportError != nil {
-			fmt.Printf(" import error: %s\n", *files[i].ImportError)
+		if status := files[i].Step(library.HashStep); status.Status == types.Error {
+			fmt.Printf(" import error: %s\n", status.Message)
 		}
-		if files[i].OsdbError != nil {
-			fmt.Printf("%s\n", *files[i].OsdbError)
+		if status := files[i].Step(library.OsdbStep); status.Status == types.Error {
+			fmt.Printf("%s\n", status.Message)
 		}
 
+		suggestions := suggest(ctx, importer, files[i], "")
+
+	prompt:
 		for {
-			switch manualImport(c, importer, shows, files[i]) {
+			switch manualImport(ctx, c, importer, shows, files[i], &suggestions) {
 			case abort:
 				fmt.Printf("aborting.\n")
 				return
 			case retry:
 				continue
 			case success:
-				break
+				break prompt
 			}
 		}
 	}
 }
 
 func runImport(c *cli.Context) {
-	if c.NArg() == 0 {
+	if c.NArg() == 0 && !c.Bool("retry-failed") {
 		fmt.Fprintf(os.Stderr, "please supply a filename\n")
 	}
 
@@ -155,21 +248,74 @@ func runImport(c *cli.Context) {
 	library := openLibrary(config)
 
 	importer := importer.NewContext(library, config)
+	importer.Refresh = c.Bool("refresh")
 	go func() {
 		for err := range importer.Errors {
 			log.Printf("import error: %s", err)
 		}
 	}()
 
-	importer.Import([]string(c.Args()))
-	defer close(importer.Stop)
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	var stopProgress func()
+	importer.Progress, stopProgress = startImportProgress(
+		!c.GlobalBool("non-interactive"),
+	)
+
+	if c.Bool("retry-failed") {
+		err := importer.RetryFailed(ctx)
+		if err != nil {
+			log.Printf("error: %s", err)
+		}
+	}
+	if c.NArg() > 0 {
+		importer.Import(ctx, []string(c.Args()))
+	}
+
+	stopProgress()
+	importer.Progress = nil
 
 	if len(importer.FilesWithErrors) > 0 {
-		if c.GlobalBool("non-interactive") {
+		if c.GlobalBool("non-interactive") || ctx.Err() != nil {
 			log.Printf("warning: there have been errors while importing some files.")
 		} else {
-			fixFileErrors(c, importer)
+			fixFileErrors(ctx, c, importer)
+		}
+	}
+}
+
+// interruptContext returns a context which is cancelled when the user
+// presses Ctrl-C, so that whatever has been imported so far can be saved.
+// A second Ctrl-C exits immediately.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case <-interrupts:
+		case <-stopped:
+			return
+		}
+
+		log.Printf("interrupted, saving what has been imported so far (press Ctrl-C again to quit)")
+		cancel()
+
+		select {
+		case <-interrupts:
+			os.Exit(1)
+		case <-stopped:
 		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupts)
+		close(stopped)
+		cancel()
 	}
 }
 
@@ -183,8 +329,142 @@ func main() {
 			Name:      "import",
 			Aliases:   []string{"imp", "i"},
 			Usage:     "import video files into the library",
-			ArgsUsage: "<filename> [filename2] ...",
+			ArgsUsage: "[filename] [filename2] ...",
 			Action:    runImport,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "refresh, r",
+					Usage: "re-process files which are unchanged and already identified",
+				},
+				cli.BoolFlag{
+					Name:  "retry-failed",
+					Usage: "re-run the failed import steps of all files in the library",
+				},
+			},
+		},
+		{
+			Name:      "list",
+			Aliases:   []string{"ls", "l"},
+			Usage:     "list movies and episodes matching a query",
+			ArgsUsage: `[query] (e.g. series:"the wire" season:2 unwatched year>2010 lang:de)`,
+			Action:    runList,
+		},
+		{
+			Name:      "watched",
+			Usage:     "mark movies and episodes matching a query as watched",
+			ArgsUsage: "<query>",
+			Action:    runWatched,
+		},
+		{
+			Name:      "unwatched",
+			Usage:     "mark movies and episodes matching a query as unwatched",
+			ArgsUsage: "<query>",
+			Action:    runUnwatched,
+		},
+		{
+			Name:      "next",
+			Aliases:   []string{"n"},
+			Usage:     "show the next episode to watch (of every series in progress if no query is given)",
+			ArgsUsage: "[series query]",
+			Action:    runNext,
+		},
+		{
+			Name:   "missing",
+			Usage:  "list aired episodes which have no files",
+			Action: runMissing,
+		},
+		{
+			Name:   "upcoming",
+			Usage:  "list episodes which haven't aired yet",
+			Action: runUpcoming,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "calendar, C",
+					Usage: "display a calendar: month (grid) or week (agenda)",
+				},
+				cli.StringFlag{
+					Name:  "ics",
+					Usage: "export the air dates to an iCalendar file (- for stdout)",
+				},
+			},
+		},
+		{
+			Name:  "subs",
+			Usage: "manipulate subtitle files",
+			Subcommands: []cli.Command{
+				{
+					Name:      "convert",
+					Usage:     "convert subtitle files to another format (saved next to them, in UTF-8)",
+					ArgsUsage: "<filename>...",
+					Action:    runSubsConvert,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "to, t",
+							Usage: "the format to convert to: srt, vtt, ass, ssa or sub (MicroDVD)",
+						},
+						cli.Float64Flag{
+							Name:  "framerate, f",
+							Usage: "frame rate for MicroDVD subtitles (by default, that of the video next to the subtitle)",
+						},
+						cli.BoolFlag{
+							Name:  "force",
+							Usage: "overwrite existing files",
+						},
+					},
+				},
+				{
+					Name:      "shift",
+					Usage:     "shift the subtitles of the matching shows by an offset",
+					ArgsUsage: "<query>",
+					Action:    runSubsShift,
+					Flags: []cli.Flag{
+						cli.DurationFlag{
+							Name:  "by",
+							Usage: "the offset, e.g. 1.5s (later) or -500ms (earlier)",
+						},
+						cli.StringFlag{
+							Name:  "lang, l",
+							Usage: "only shift subtitles in this language",
+						},
+					},
+				},
+				{
+					Name:      "retime",
+					Usage:     "convert the subtitles of the matching shows from one frame rate to another",
+					ArgsUsage: "<query>",
+					Action:    runSubsRetime,
+					Flags: []cli.Flag{
+						cli.Float64Flag{
+							Name:  "from",
+							Usage: "the frame rate which the subtitles were made for, e.g. 25",
+						},
+						cli.Float64Flag{
+							Name:  "to",
+							Usage: "the frame rate to convert to (by default, that of the video)",
+						},
+						cli.StringFlag{
+							Name:  "lang, l",
+							Usage: "only retime subtitles in this language",
+						},
+					},
+				},
+				{
+					Name:      "sync",
+					Usage:     "synchronise the subtitles of the matching shows to the speech in their audio",
+					ArgsUsage: "<query>",
+					Action:    runSubsSync,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "audio, a",
+							Usage: "a WAV file with the audio (by default, the one next to the video with the same name)",
+						},
+						cli.StringFlag{
+							Name:  "lang, l",
+							Usage: "only synchronise subtitles in this language",
+						},
+					},
+				},
+			},
 		},
 	}
 
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/codegangsta/cli"
+)
+
+func runNext(c *cli.Context) {
+	config := parseConfig(c)
+	lib := openLibrary(config)
+
+	var (
+		series []*library.Series
+		err    error
+	)
+	if c.NArg() == 0 {
+		series, err = lib.SeriesInProgress()
+	} else {
+		series, err = querySeries(lib, strings.Join(c.Args(), " "))
+	}
+	if err != nil {
+		log.Fatalf("unable to query library: %s", err)
+	}
+
+	seriesTitles := make(map[uint]string)
+	for i := range series {
+		seriesTitles[series[i].ID] = series[i].Title
+
+		next, err := lib.NextEpisode(series[i])
+		if err != nil {
+			log.Fatalf("unable to find next episode of %s: %s", series[i].Title, err)
+		}
+		if next.Episode == nil {
+			if c.NArg() > 0 {
+				fmt.Printf("%s: no next episode\n", series[i].Title)
+			}
+		} else {
+			fmt.Printf("%s\n", showName(lib, next.Episode, seriesTitles))
+			fmt.Printf("    %s\n", next.Episode.BestFile().Path)
+		}
+
+		for _, episode := range next.Missing {
+			fmt.Printf("    skipped %s (no file)\n", showName(lib, episode, seriesTitles))
+		}
+		if c.NArg() > 0 {
+			for _, special := range next.Specials {
+				fmt.Printf("    unwatched special: %s\n", showName(lib, special, seriesTitles))
+			}
+		}
+	}
+}
+
+// querySeries returns the series of all episodes matching the query
+func querySeries(lib *library.Library, query string) ([]*library.Series, error) {
+	shows, err := lib.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*library.Series
+	seen := make(map[uint]bool)
+	for _, show := range shows {
+		if show.SeriesID == 0 || seen[show.SeriesID] {
+			continue
+		}
+		seen[show.SeriesID] = true
+
+		series, err := lib.GetSeriesByEpisode(show)
+		if err != nil {
+			return nil, err
+		}
+		if series != nil {
+			result = append(result, series)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DexterLB/mvm/importer"
+	"github.com/DexterLB/mvm/progress"
+)
+
+// progressLogInterval is how often the import progress is logged in
+// non-interactive mode
+const progressLogInterval = 10 * time.Second
+
+// startImportProgress makes progress objects for all import stages, which
+// are shown as progress bars (one per line), or are logged periodically
+// in non-interactive mode or when the bars can't be shown. The returned
+// function stops showing the progress.
+func startImportProgress(interactive bool) (map[string]progress.Progress, func()) {
+	if interactive {
+		stages, stop, err := displayImportProgress()
+		if err == nil {
+			return stages, stop
+		}
+	}
+	return logImportProgress()
+}
+
+func displayImportProgress() (map[string]progress.Progress, func(), error) {
+	stages := make(map[string]progress.Progress)
+	bars := make([]*progress.ProgressBar, len(importer.Stages))
+	for i, stage := range importer.Stages {
+		bar := progress.NewProgressBar(0)
+		bar.Prefix(fmt.Sprintf("%-10s", stage))
+		bar.ShowSpeed = true
+
+		bars[i] = bar
+		stages[stage] = bar
+	}
+
+	pool, err := progress.StartPool(bars...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stages, func() {
+		_ = pool.Stop()
+	}, nil
+}
+
+func logImportProgress() (map[string]progress.Progress, func()) {
+	stages := make(map[string]progress.Progress)
+	counters := make([]*progress.Counter, len(importer.Stages))
+	for i, stage := range importer.Stages {
+		counters[i] = progress.NewCounter()
+		stages[stage] = counters[i]
+	}
+
+	logProgress := func() {
+		parts := make([]string, len(counters))
+		for i := range counters {
+			parts[i] = fmt.Sprintf("%s %s", importer.Stages[i], counters[i])
+		}
+		log.Printf("import progress: %s", strings.Join(parts, ", "))
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(progressLogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				logProgress()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return stages, func() {
+		close(stop)
+		<-stopped
+		logProgress()
+	}
+}
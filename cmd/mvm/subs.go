@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DexterLB/mvm/importer"
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/probe"
+	"github.com/DexterLB/mvm/subtitles"
+	"github.com/DexterLB/mvm/types"
+	"github.com/codegangsta/cli"
+)
+
+func runSubsConvert(c *cli.Context) {
+	if c.NArg() == 0 {
+		log.Fatalf("please supply a filename")
+	}
+
+	target, err := subtitles.ParseFormat(c.String("to"))
+	if err != nil {
+		log.Fatalf("please supply a format to convert to with --to: %s", err)
+	}
+
+	for _, filename := range c.Args() {
+		output, err := convertSubtitleFile(
+			filename, target, c.Float64("framerate"), c.Bool("force"),
+		)
+		if err != nil {
+			log.Printf("unable to convert %s: %s", filename, err)
+			continue
+		}
+		fmt.Printf("%s -> %s\n", filename, output)
+	}
+}
+
+// convertSubtitleFile converts the subtitle to a UTF-8 file with the
+// same name and the target format's extension, and returns its name
+func convertSubtitleFile(
+	filename string,
+	target subtitles.Format,
+	framerate float64,
+	overwrite bool,
+) (string, error) {
+	output := strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + string(target)
+	if output == filename {
+		return "", fmt.Errorf("it is already in %s format", target)
+	}
+	if _, err := os.Stat(output); err == nil && !overwrite {
+		return "", fmt.Errorf("%s already exists (use --force to overwrite it)", output)
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	contents, err = subtitles.ToUTF8(
+		contents, subtitles.DetectEncoding(contents, types.Language{}),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	format, err := subtitles.DetectFormat(contents)
+	if err != nil {
+		format, err = subtitles.ParseFormat(filepath.Ext(filename))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	frameBased := format == subtitles.MicroDVD || target == subtitles.MicroDVD
+	if framerate == 0 && frameBased {
+		framerate = videoFramerate(filename)
+	}
+
+	converted, err := subtitles.Convert(contents, format, target, framerate)
+	if err != nil && framerate == 0 && frameBased {
+		return "", fmt.Errorf("%s (use --framerate)", err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return output, ioutil.WriteFile(output, converted, 0644)
+}
+
+// videoFramerate finds the frame rate of the video which the subtitle is
+// for: the one in the same directory whose name is the beginning of the
+// subtitle's name (e.g. Movie.mkv for Movie.en.sub). Returns 0 if there's
+// no such video.
+func videoFramerate(subtitle string) float64 {
+	dir := filepath.Dir(subtitle)
+	name := strings.TrimSuffix(filepath.Base(subtitle), filepath.Ext(subtitle))
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	for _, entry := range entries {
+		video := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || entry.Name() == filepath.Base(subtitle) ||
+			!(name == video || strings.HasPrefix(name, video+".")) {
+			continue
+		}
+
+		info, err := probe.File(filepath.Join(dir, entry.Name()))
+		if err == nil && info.Framerate > 0 {
+			return float64(info.Framerate)
+		}
+	}
+	return 0
+}
+
+func runSubsShift(c *cli.Context) {
+	offset := c.Duration("by")
+	if offset == 0 {
+		log.Fatalf("please supply an offset with --by (e.g. --by 1.5s or --by -500ms)")
+	}
+
+	adjustSubtitles(c, func(
+		importer *importer.Context,
+		file *library.VideoFile,
+		subtitle *library.Subtitle,
+	) (string, error) {
+		return fmt.Sprintf("shifted by %s", offset), importer.ShiftSubtitle(file, subtitle, offset)
+	})
+}
+
+func runSubsRetime(c *cli.Context) {
+	from := c.Float64("from")
+	if from <= 0 {
+		log.Fatalf("please supply the subtitles' frame rate with --from")
+	}
+
+	adjustSubtitles(c, func(
+		importer *importer.Context,
+		file *library.VideoFile,
+		subtitle *library.Subtitle,
+	) (string, error) {
+		to := c.Float64("to")
+		if to <= 0 {
+			to = float64(file.Framerate)
+		}
+		if to <= 0 {
+			return "", fmt.Errorf("unknown frame rate of %s (use --to)", file.Path)
+		}
+		return fmt.Sprintf("retimed from %g to %g fps", from, to), importer.RetimeSubtitle(file, subtitle, from, to)
+	})
+}
+
+func runSubsSync(c *cli.Context) {
+	adjustSubtitles(c, func(
+		importer *importer.Context,
+		file *library.VideoFile,
+		subtitle *library.Subtitle,
+	) (string, error) {
+		alignment, err := importer.SyncSubtitle(context.Background(), file, subtitle, c.String("audio"))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(
+			"shifted by %s and scaled by %.4f (match %.0f%%)",
+			alignment.Offset, alignment.Factor, alignment.Score*100,
+		), nil
+	})
+}
+
+// adjustSubtitles applies the adjustment to the subtitles of all files of
+// the shows matching the query (only those in the --lang language, if given)
+func adjustSubtitles(
+	c *cli.Context,
+	adjust func(*importer.Context, *library.VideoFile, *library.Subtitle) (string, error),
+) {
+	if c.NArg() == 0 {
+		log.Fatalf("please supply a query (use \"mvm list\" to check it first)")
+	}
+
+	var language *types.Language
+	if c.String("lang") != "" {
+		lang, err := types.ParseLanguage(c.String("lang"))
+		if err != nil {
+			log.Fatalf("unknown language %s: %s", c.String("lang"), err)
+		}
+		language = &lang
+	}
+
+	config := parseConfig(c)
+	lib := openLibrary(config)
+	importer := importer.NewContext(lib, config)
+
+	shows, err := lib.Query(strings.Join(c.Args(), " "))
+	if err != nil {
+		log.Fatalf("unable to query library: %s", err)
+	}
+
+	for _, show := range shows {
+		for _, file := range show.Files {
+			subs, err := lib.GetSubtitlesByFile(file)
+			if err != nil {
+				log.Fatalf("unable to get subtitles of %s: %s", file.Path, err)
+			}
+
+			for _, subtitle := range subs {
+				if language != nil && subtitle.Language != *language {
+					continue
+				}
+
+				action, err := adjust(importer, file, subtitle)
+				if err != nil {
+					log.Printf("unable to adjust %s: %s", subtitle.Filename, err)
+					continue
+				}
+				fmt.Printf("%s: %s\n", action, subtitle.Filename)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/codegangsta/cli"
+)
+
+func runWatched(c *cli.Context) {
+	setWatched(c, true)
+}
+
+func runUnwatched(c *cli.Context) {
+	setWatched(c, false)
+}
+
+func setWatched(c *cli.Context, watched bool) {
+	if c.NArg() == 0 {
+		log.Fatalf("please supply a query (use \"mvm list\" to check it first)")
+	}
+
+	config := parseConfig(c)
+	lib := openLibrary(config)
+
+	shows, err := lib.Query(strings.Join(c.Args(), " "))
+	if err != nil {
+		log.Fatalf("unable to query library: %s", err)
+	}
+
+	state := "unwatched"
+	if watched {
+		state = "watched"
+	}
+
+	seriesTitles := make(map[uint]string)
+	for _, show := range shows {
+		if watched {
+			err = lib.MarkWatched(show)
+		} else {
+			err = lib.MarkUnwatched(show)
+		}
+		if err != nil {
+			log.Fatalf("unable to mark %s as %s: %s", show.Title, state, err)
+		}
+
+		fmt.Printf("%s: %s\n", state, showName(lib, show, seriesTitles))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/BurntSushi/toml"
+	"github.com/DexterLB/mvm/subtitles"
 	"github.com/DexterLB/mvm/types"
 )
 
@@ -19,11 +20,25 @@ type Config struct {
 // Importer contains the configuration for all importers
 type Importer struct {
 	BufferSize int       `toml:"buffer_size"`
+	Walker     Walker    `toml:"walker"`
 	Osdb       Osdb      `toml:"osdb"`
 	Imdb       Imdb      `toml:"imdb"`
 	Subtitles  Subtitles `toml:"subtitles"`
 }
 
+// Walker contains the configuration for searching directories for video files
+type Walker struct {
+	// VideoExtensions lists the extensions (without the dot) of files which
+	// are considered to be videos. Leave empty for a sane default list.
+	VideoExtensions []string `toml:"video_extensions"`
+	// IgnorePatterns are shell globs for files and directories to skip.
+	// Patterns without a slash are matched against the base name only.
+	IgnorePatterns []string `toml:"ignore_patterns"`
+	// FollowSymlinks makes the walker descend into symlinked directories
+	// and import symlinked files
+	FollowSymlinks bool `toml:"follow_symlinks"`
+}
+
 // Library contains the configuration for the library
 type Library struct {
 	Database    string `toml:"database"`
@@ -44,19 +59,102 @@ type Osdb struct {
 	// MaxSubtitlesPerRequest is the maximum number of subtitles to ask for in a
 	// single request. Currently, opensubtitles limits this to 20
 	MaxSubtitlesPerRequest int `toml:"max_subtitles_per_request"`
+	// RateLimit limits and retries requests to opensubtitles.org
+	// (by default at most 4 requests per second)
+	RateLimit RateLimit `toml:"rate_limit"`
 }
 
 // Imdb contains the configuration related to imdb.com
 type Imdb struct {
 	// MaxRequests is the maximum number of parallel requests to imdb
 	MaxRequests int `toml:"max_requests"`
+	// SkipCatalog stops the importer from adding all episodes of imported
+	// series (as placeholders for the episodes without files)
+	SkipCatalog bool `toml:"skip_catalog"`
+	// Providers lists the sources of metadata in the order in which they
+	// are tried: "scraper" (imdb.com), "dataset" (an offline index of the
+	// imdb datasets), "api" (an imdb_apiserver) and "static" (a JSON file).
+	// Defaults to ["api"] if APIAddress is set and ["scraper"] otherwise.
+	Providers []string `toml:"providers"`
+	// APIAddress is the address of an imdb_apiserver, e.g.
+	// http://localhost:8088, to get data from instead of imdb.com
+	APIAddress string `toml:"api_address"`
+	// DatasetIndex is the database file of the "dataset" provider
+	DatasetIndex string `toml:"dataset_index"`
+	// StaticFile is the JSON file of the "static" provider
+	StaticFile string `toml:"static_file"`
+	// Cache configures the on-disk cache for pages downloaded from imdb
+	Cache HttpCache `toml:"cache"`
+	// RateLimit limits and retries requests to imdb
+	// (by default at most 10 requests per second)
+	RateLimit RateLimit `toml:"rate_limit"`
+}
+
+// RateLimit contains the policy for requests to a remote service. Zero
+// values mean the defaults.
+type RateLimit struct {
+	// RequestsPerSecond is the maximum rate of requests
+	RequestsPerSecond float64 `toml:"requests_per_second"`
+	// MaxRetries is the number of retries for requests which fail with a
+	// server error or a timeout (default 4)
+	MaxRetries int `toml:"max_retries"`
+	// InitialBackoff is the delay before the first retry, which is doubled
+	// for each subsequent one (default 1s)
+	InitialBackoff types.Duration `toml:"initial_backoff"`
+	// MaxBackoff is the maximum delay between retries (default 1m)
+	MaxBackoff types.Duration `toml:"max_backoff"`
+	// QuotaPause is how long to wait when the service says our quota
+	// is exhausted (default 10m)
+	QuotaPause types.Duration `toml:"quota_pause"`
+}
+
+// HttpCache contains the configuration of an on-disk HTTP cache
+type HttpCache struct {
+	// Disabled makes all requests go to the network
+	Disabled bool `toml:"disabled"`
+	// Directory for the cache (defaults to mvm/http in the XDG cache directory)
+	Directory string `toml:"directory"`
+	// MaxSize is the maximum size of the cache in megabytes (default 200)
+	MaxSize int64 `toml:"max_size"`
+	// OldMovieTTL is how long pages of old movies and ended series are
+	// kept (default 30 days)
+	OldMovieTTL types.Duration `toml:"old_movie_ttl"`
+	// RunningSeriesTTL is how long pages of running series are kept
+	// (default 1 day)
+	RunningSeriesTTL types.Duration `toml:"running_series_ttl"`
+	// DefaultTTL is how long all other pages are kept (default 7 days)
+	DefaultTTL types.Duration `toml:"default_ttl"`
 }
 
-// Subtitles contains the configuration for the subtitle downloader
+// Subtitles contains the configuration for finding and downloading subtitles
 type Subtitles struct {
 	Languages            types.Languages `toml:"languages"`
 	Filename             *types.Template `toml:"filename"`
 	SubtitlesPerLanguage int             `toml:"subtitles_per_language"`
+	// SidecarExtensions lists the extensions (without the dot) of subtitle
+	// files next to the videos which are added to the library.
+	// Leave empty for a sane default list.
+	SidecarExtensions []string `toml:"sidecar_extensions"`
+	// SidecarDirectories are the names of directories next to the videos
+	// which are searched for subtitles (case insensitive, by default
+	// "subs" and "subtitles")
+	SidecarDirectories []string `toml:"sidecar_directories"`
+	// KeepOriginalEncoding saves downloaded subtitles in the encoding in
+	// which they come, instead of converting them to UTF-8. It has no
+	// effect when they're converted to another Format.
+	KeepOriginalEncoding bool `toml:"keep_original_encoding"`
+	// Format is the format to which downloaded subtitles are converted
+	// (srt, vtt, ass, ssa or sub). Leave empty to keep them as they are.
+	Format subtitles.Format `toml:"format"`
+	// Sync makes the importer synchronise downloaded subtitles to the
+	// speech in the video, and prefer those which match it best. The
+	// audio is read from an uncompressed WAV file next to the video with
+	// the same name (e.g. Movie.wav for Movie.mkv); subtitles of videos
+	// without one aren't synchronised.
+	Sync bool `toml:"sync"`
+	// SyncMaxOffset is how far subtitles can be shifted when synchronising
+	// them (a minute by default)
+	SyncMaxOffset types.Duration `toml:"sync_max_offset"`
 }
 
 // Load loads a configuration file
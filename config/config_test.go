@@ -2,7 +2,9 @@ package config
 
 import (
 	"testing"
+	"time"
 
+	"github.com/DexterLB/mvm/subtitles"
 	"github.com/DexterLB/mvm/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -18,16 +20,41 @@ func TestLoad(t *testing.T) {
 
 	assert.Equal(50, config.Importer.BufferSize)
 
+	assert.Equal([]string{"mkv", "avi"}, config.Importer.Walker.VideoExtensions)
+	assert.Equal(
+		[]string{"*sample*", "extras/*"},
+		config.Importer.Walker.IgnorePatterns,
+	)
+	assert.Equal(true, config.Importer.Walker.FollowSymlinks)
+
 	assert.Equal(3, config.Importer.Osdb.MaxRequests)
 	assert.Equal(199, config.Importer.Osdb.MaxMoviesPerRequest)
 	assert.Equal(19, config.Importer.Osdb.MaxSubtitlesPerRequest)
 	assert.Equal("foo", config.Importer.Osdb.Username)
 	assert.Equal("bar", config.Importer.Osdb.Password)
+	assert.Equal(2.5, config.Importer.Osdb.RateLimit.RequestsPerSecond)
+	assert.Equal(3, config.Importer.Osdb.RateLimit.MaxRetries)
+	assert.Equal(types.Duration(2*time.Second), config.Importer.Osdb.RateLimit.InitialBackoff)
+	assert.Equal(types.Duration(30*time.Second), config.Importer.Osdb.RateLimit.MaxBackoff)
+	assert.Equal(types.Duration(time.Hour), config.Importer.Osdb.RateLimit.QuotaPause)
 
 	assert.Equal("foosql", config.Library.Database)
 	assert.Equal("bar", config.Library.DatabaseDSN)
 
 	assert.Equal(16, config.Importer.Imdb.MaxRequests)
+	assert.True(config.Importer.Imdb.SkipCatalog)
+	assert.Equal([]string{"dataset", "api", "scraper"}, config.Importer.Imdb.Providers)
+	assert.Equal("http://localhost:8088", config.Importer.Imdb.APIAddress)
+	assert.Equal("/var/lib/mvm/imdb.db", config.Importer.Imdb.DatasetIndex)
+	assert.Equal("metadata.json", config.Importer.Imdb.StaticFile)
+	assert.False(config.Importer.Imdb.Cache.Disabled)
+	assert.Equal("/tmp/mvm-cache", config.Importer.Imdb.Cache.Directory)
+	assert.Equal(int64(50), config.Importer.Imdb.Cache.MaxSize)
+	assert.Equal(types.Duration(1000*time.Hour), config.Importer.Imdb.Cache.OldMovieTTL)
+	assert.Equal(types.Duration(12*time.Hour), config.Importer.Imdb.Cache.RunningSeriesTTL)
+	assert.Equal(types.Duration(100*time.Hour), config.Importer.Imdb.Cache.DefaultTTL)
+	assert.Equal(5.0, config.Importer.Imdb.RateLimit.RequestsPerSecond)
+	assert.Equal(0, config.Importer.Imdb.RateLimit.MaxRetries)
 
 	assert.Equal(
 		types.Languages{
@@ -47,4 +74,10 @@ func TestLoad(t *testing.T) {
 	}
 
 	assert.Equal(2, config.Importer.Subtitles.SubtitlesPerLanguage)
+	assert.Equal([]string{"srt", "ass"}, config.Importer.Subtitles.SidecarExtensions)
+	assert.Equal([]string{"subs"}, config.Importer.Subtitles.SidecarDirectories)
+	assert.True(config.Importer.Subtitles.KeepOriginalEncoding)
+	assert.Equal(subtitles.WebVTT, config.Importer.Subtitles.Format)
+	assert.True(config.Importer.Subtitles.Sync)
+	assert.Equal(types.Duration(30*time.Second), config.Importer.Subtitles.SyncMaxOffset)
 }
@@ -2,14 +2,49 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/imdb/httpcache"
 	"github.com/DexterLB/mvm/imdb/jsonapi"
+	"github.com/DexterLB/mvm/throttle"
 )
 
+// cacheSize is the maximum size of the on-disk cache of imdb pages
+const cacheSize = 1024 * 1024 * 1024
+
+// requestsPerSecond is the maximum rate of requests to imdb
+const requestsPerSecond = 10
+
+func imdbClient() imdb.HttpGetter {
+	policy := throttle.DefaultPolicy
+	policy.RequestsPerSecond = requestsPerSecond
+
+	client := &http.Client{
+		Transport: &throttle.Transport{Limiter: throttle.NewLimiter(policy)},
+	}
+
+	dir, err := httpcache.DefaultDirectory()
+	if err != nil {
+		log.Printf("not caching imdb pages: %s", err)
+		return client
+	}
+
+	cache, err := httpcache.New(dir)
+	if err != nil {
+		log.Printf("not caching imdb pages: %s", err)
+		return client
+	}
+	cache.Client = client
+	cache.MaxSize = cacheSize
+
+	return cache
+}
+
 func serve(address string) error {
-	s := &jsonapi.Server{http.DefaultClient}
+	s := &jsonapi.Server{imdbClient()}
 	return http.ListenAndServe(address, s)
 }
 
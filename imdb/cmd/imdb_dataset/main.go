@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/DexterLB/mvm/imdb/dataset"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func ingest(databaseFile string, datasetDir string) error {
+	index, err := dataset.Open("sqlite3", databaseFile)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	return index.Ingest(datasetDir)
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Printf("usage: $0 <index database file> <directory with dataset files>\n")
+		os.Exit(2)
+	}
+	err := ingest(os.Args[1], os.Args[2])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,187 @@
+package dataset
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DexterLB/mvm/imdb"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func testIndex(t *testing.T) *Index {
+	index, err := Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = index.Ingest("fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return index
+}
+
+func TestItem(t *testing.T) {
+	index := testIndex(t)
+	defer index.Close()
+
+	data, err := index.Item(403358)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(403358, data.ID)
+	assert.Equal(imdb.Movie, data.Type)
+	assert.Equal("Night Watch", data.Title)
+	assert.Equal(2004, data.Year)
+	assert.Equal(114*time.Minute, data.Duration)
+	assert.InDelta(6.5, data.Rating, 0.01)
+	assert.Equal(47181, data.Votes)
+	assert.Equal(map[string]string{
+		"BG":       "Нощна стража",
+		"FI (dvd)": "Yövahti",
+		"AR":       "Guardianes de la noche",
+		"DE (imdbDisplay, working, literal title)": "Wächter der Nacht",
+	}, data.OtherTitles)
+
+	_, err = index.Item(1234)
+	assert.NotNil(err)
+}
+
+func TestItemEpisode(t *testing.T) {
+	index := testIndex(t)
+	defer index.Close()
+
+	data, err := index.Item(2816136)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(imdb.Episode, data.Type)
+	assert.Equal("Two Swords", data.Title)
+	assert.Equal(4, data.SeasonNumber)
+	assert.Equal(1, data.EpisodeNumber)
+
+	if data.Series == nil {
+		t.Fatal("episode has no series")
+	}
+	assert.Equal(944947, data.Series.ID())
+	title, err := data.Series.Title()
+	assert.Nil(err)
+	assert.Equal("Game of Thrones", title)
+	seriesType, err := data.Series.Type()
+	assert.Nil(err)
+	assert.Equal(imdb.Series, seriesType)
+}
+
+func TestEpisodes(t *testing.T) {
+	index := testIndex(t)
+	defer index.Close()
+
+	episodes, err := index.Episodes(944947)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, episode := range episodes {
+		names = append(names, fmt.Sprintf(
+			"S%02dE%02d %s", episode.SeasonNumber, episode.EpisodeNumber, episode.Title,
+		))
+	}
+
+	assert.Equal(t, []string{
+		"S01E01 Winter Is Coming",
+		"S01E02 The Kingsroad",
+		"S04E01 Two Swords",
+	}, names)
+
+	// not in the dataset, so that other providers can be asked
+	_, err = index.Episodes(1)
+	assert.NotNil(t, err)
+}
+
+func TestSearchCommonWord(t *testing.T) {
+	index, err := Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+
+	tx := index.db.Begin()
+	for i := 1; i <= maxSearchCandidates+100; i++ {
+		tx.Create(&Title{ID: i, Type: imdb.Movie, Title: fmt.Sprintf("Love Story %d", i), Votes: 1000 + i})
+		tx.Create(&Word{Word: "love", TitleID: i})
+	}
+	tx.Create(&Title{ID: 10000, Type: imdb.Movie, Title: "Love", Votes: 1})
+	tx.Create(&Word{Word: "love", TitleID: 10000})
+	if err = tx.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := index.Search(&imdb.SearchQuery{Query: "love", Category: imdb.Any})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, maxSearchResults, len(items))
+	assert.Equal(t, 10000, items[0].ID)
+	assert.Equal(t, maxSearchCandidates+100, items[1].ID)
+}
+
+func ExampleIndex_Search() {
+	index, err := Open("sqlite3", ":memory:")
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return
+	}
+	defer index.Close()
+
+	err = index.Ingest("fixtures")
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return
+	}
+
+	for _, query := range []*imdb.SearchQuery{
+		{Query: "Stalker"},
+		{Query: "Stalker", Year: 1979, Category: imdb.Movie, Exact: true},
+		{Query: "nochnoy dozor"},
+		{Query: "guardianes noche", Category: imdb.Movie},
+		{Query: "Игра на тронове", Category: imdb.Series},
+		{Query: "stalker", Category: imdb.Episode},
+	} {
+		items, err := index.Search(query)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			return
+		}
+
+		fmt.Printf("%s:\n", query.Query)
+		for _, item := range items {
+			fmt.Printf("[%s] %07d: %s (%d)\n", item.Type, item.ID, item.Title, item.Year)
+		}
+	}
+
+	// Output:
+	// Stalker:
+	// [Movie] 0079944: Stalker (1979)
+	// [Movie] 2140465: Stalker (2012)
+	// [Episode] 1223975: Stalker (2008)
+	// Stalker:
+	// [Movie] 0079944: Stalker (1979)
+	// nochnoy dozor:
+	// [Movie] 0403358: Night Watch (2004)
+	// guardianes noche:
+	// [Movie] 0403358: Night Watch (2004)
+	// Игра на тронове:
+	// [Series] 0944947: Game of Thrones (2011)
+	// stalker:
+	// [Episode] 1223975: Stalker (2008)
+}
@@ -0,0 +1,8 @@
+// Package dataset is an offline alternative to scraping IMDB. It ingests
+// the official IMDB dataset dumps (https://www.imdb.com/interfaces/) into
+// a local SQL index, and answers item lookups and searches from it.
+//
+// The datasets don't contain plots, posters, taglines or exact release
+// dates, so the returned data only has titles, years, durations, ratings,
+// alternative titles and episode numbering.
+package dataset
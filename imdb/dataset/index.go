@@ -0,0 +1,289 @@
+package dataset
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/release"
+	"github.com/jinzhu/gorm"
+)
+
+// maxSearchResults is the maximum number of items returned by Search.
+// At most maxSearchCandidates titles are read from the index for a
+// search, so that queries with common words don't load the whole index.
+const (
+	maxSearchResults    = 50
+	maxSearchCandidates = 500
+)
+
+// Index is a local index of the IMDB datasets
+type Index struct {
+	db *gorm.DB
+}
+
+// Title is a row of the title index
+type Title struct {
+	ID            int `gorm:"primary_key"`
+	Type          imdb.ItemType
+	Title         string
+	OriginalTitle string
+	Year          int
+	Minutes       int
+	Rating        float32
+	Votes         int
+
+	SeriesID int `gorm:"index"`
+	Season   int
+	Episode  int
+}
+
+// Aka is an alternative title of a title
+type Aka struct {
+	ID      uint `gorm:"primary_key"`
+	TitleID int  `gorm:"index"`
+	Title   string
+	Region  string
+	Kind    string
+}
+
+// Word is a normalised word contained in one of the titles of a title
+type Word struct {
+	Word    string `gorm:"index"`
+	TitleID int
+}
+
+// Open opens (creating it if needed) an index in the specified database
+func Open(dbDriver string, arguments ...interface{}) (*Index, error) {
+	db, err := gorm.Open(dbDriver, arguments...)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(dbDriver, "sqlite") {
+		db.Exec("PRAGMA busy_timeout = 5000")
+		db.DB().SetMaxOpenConns(1) // sqlite doesn't like multithreadedness
+	}
+
+	err = db.AutoMigrate(&Title{}, &Aka{}, &Word{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &Index{
+		db: db,
+	}, nil
+}
+
+// Close closes the index's database
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// Item returns information for an item with a given IMDB ID
+func (ix *Index) Item(id int) (*imdb.ItemData, error) {
+	title := &Title{}
+	err := ix.db.Where("id = ?", id).First(title).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("tt%07d is not in the dataset", id)
+		}
+		return nil, err
+	}
+
+	data := itemData(title)
+
+	var akas []*Aka
+	err = ix.db.Where("title_id = ?", id).Order("id").Find(&akas).Error
+	if err != nil {
+		return nil, err
+	}
+	data.OtherTitles = otherTitles(akas)
+
+	if title.Type == imdb.Episode && title.SeriesID != 0 {
+		series := &Title{}
+		err = ix.db.Where("id = ?", title.SeriesID).First(series).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		if err == gorm.ErrRecordNotFound {
+			series.ID = title.SeriesID
+			series.Type = imdb.Series
+		}
+		data.Series = imdb.NewFromShort(shortItem(series))
+	}
+
+	return data, nil
+}
+
+// Series returns information for a series with a given IMDB ID
+func (ix *Index) Series(id int) (*imdb.ItemData, error) {
+	return ix.Item(id)
+}
+
+// Episodes returns all episodes of the series with the given IMDB ID,
+// ordered by season and episode number
+func (ix *Index) Episodes(seriesID int) ([]*imdb.ItemData, error) {
+	var titles []*Title
+	err := ix.db.Where("series_id = ?", seriesID).
+		Order("season, episode, id").Find(&titles).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(titles) == 0 {
+		return nil, fmt.Errorf("no episodes of tt%07d in the dataset", seriesID)
+	}
+
+	episodes := make([]*imdb.ItemData, len(titles))
+	for i := range titles {
+		episodes[i] = itemData(titles[i])
+	}
+	return episodes, nil
+}
+
+// Search finds items whose primary, original or alternative titles
+// contain all words of the query (alternative titles of episodes aren't
+// searched). Exact matches are first, and the rest
+// are ordered by popularity. If the query has a year, only items from
+// that year (give or take one) are returned.
+func (ix *Index) Search(query *imdb.SearchQuery) ([]*imdb.ShortItem, error) {
+	words := release.TitleWords(query.Query)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty search query")
+	}
+
+	db := ix.db.Where(
+		"id IN (SELECT title_id FROM words WHERE word IN (?) "+
+			"GROUP BY title_id HAVING COUNT(DISTINCT word) = ?)",
+		uniqueWords(words), len(uniqueWords(words)),
+	)
+
+	if query.Category != imdb.Unknown && query.Category != imdb.Any {
+		db = db.Where("type = ?", query.Category)
+	}
+
+	if query.Year != 0 {
+		db = db.Where("year BETWEEN ? AND ?", query.Year-1, query.Year+1)
+	}
+
+	normalised := strings.Join(words, " ")
+	lower := strings.ToLower(strings.TrimSpace(query.Query))
+
+	// titles which are (most likely) exact matches are read first; they
+	// are told apart properly below
+	var titles []*Title
+	err := db.Order(gorm.Expr(
+		"CASE WHEN LOWER(title) IN (?, ?) OR LOWER(original_title) IN (?, ?) THEN 0 ELSE 1 END",
+		normalised, lower, normalised, lower,
+	)).Order("votes DESC, id").Limit(maxSearchCandidates).Find(&titles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	exact := make(map[int]bool)
+	for _, title := range titles {
+		exact[title.ID] = strings.Join(release.TitleWords(title.Title), " ") == normalised ||
+			strings.Join(release.TitleWords(title.OriginalTitle), " ") == normalised
+	}
+	if query.Exact {
+		err = ix.markExactAkas(titles, normalised, exact)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(titles, func(i, j int) bool {
+		if exact[titles[i].ID] != exact[titles[j].ID] {
+			return exact[titles[i].ID]
+		}
+		if query.Year != 0 && titles[i].Year != titles[j].Year {
+			return titles[i].Year == query.Year
+		}
+		return false
+	})
+
+	var items []*imdb.ShortItem
+	for _, title := range titles {
+		if query.Exact && !exact[title.ID] {
+			continue
+		}
+		items = append(items, shortItem(title))
+		if len(items) == maxSearchResults {
+			break
+		}
+	}
+	return items, nil
+}
+
+// markExactAkas marks titles which have an alternative title exactly
+// matching the normalised query
+func (ix *Index) markExactAkas(titles []*Title, normalised string, exact map[int]bool) error {
+	ids := make([]int, 0, len(titles))
+	for _, title := range titles {
+		if !exact[title.ID] {
+			ids = append(ids, title.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var akas []*Aka
+	err := ix.db.Where("title_id IN (?)", ids).Find(&akas).Error
+	if err != nil {
+		return err
+	}
+
+	for _, aka := range akas {
+		if strings.Join(release.TitleWords(aka.Title), " ") == normalised {
+			exact[aka.TitleID] = true
+		}
+	}
+	return nil
+}
+
+func itemData(title *Title) *imdb.ItemData {
+	return &imdb.ItemData{
+		ID:            title.ID,
+		Type:          title.Type,
+		Title:         title.Title,
+		Year:          title.Year,
+		Duration:      time.Duration(title.Minutes) * time.Minute,
+		Rating:        title.Rating,
+		Votes:         title.Votes,
+		SeasonNumber:  title.Season,
+		EpisodeNumber: title.Episode,
+	}
+}
+
+func shortItem(title *Title) *imdb.ShortItem {
+	return &imdb.ShortItem{
+		ID:    title.ID,
+		Title: title.Title,
+		Type:  title.Type,
+		Year:  title.Year,
+	}
+}
+
+// otherTitles makes a map of alternative titles similar to the one
+// returned by the scraper, e.g. "BG" -> "Нощна стража" or
+// "FI (dvd)" -> "Yövahti". The first title for each key is kept.
+func otherTitles(akas []*Aka) map[string]string {
+	titles := make(map[string]string)
+	for _, aka := range akas {
+		if aka.Region == "" {
+			continue
+		}
+
+		key := aka.Region
+		if aka.Kind != "" {
+			key = fmt.Sprintf("%s (%s)", aka.Region, aka.Kind)
+		}
+		if _, ok := titles[key]; !ok {
+			titles[key] = aka.Title
+		}
+	}
+	return titles
+}
@@ -0,0 +1,382 @@
+package dataset
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/release"
+)
+
+// Ingest replaces the contents of the index with the datasets in the
+// given directory. The files are expected to have their original names
+// (title.basics.tsv.gz etc), and may also be uncompressed (title.basics.tsv).
+// Only title.basics is required.
+func (ix *Index) Ingest(dir string) error {
+	tx, err := ix.db.DB().Begin()
+	if err != nil {
+		return err
+	}
+
+	err = ingest(tx, dir)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ingest(tx *sql.Tx, dir string) error {
+	for _, table := range []string{"titles", "akas", "words"} {
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table))
+		if err != nil {
+			return err
+		}
+	}
+
+	// titles whose alternative titles are stored
+	searchable := make(map[int]bool)
+
+	err := withDataset(dir, "title.basics", true, func(r *tsvReader) error {
+		return ingestBasics(tx, r, searchable)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = withDataset(dir, "title.episode", false, func(r *tsvReader) error {
+		return ingestEpisodes(tx, r)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = withDataset(dir, "title.ratings", false, func(r *tsvReader) error {
+		return ingestRatings(tx, r)
+	})
+	if err != nil {
+		return err
+	}
+
+	return withDataset(dir, "title.akas", false, func(r *tsvReader) error {
+		return ingestAkas(tx, r, searchable)
+	})
+}
+
+func ingestBasics(tx *sql.Tx, r *tsvReader, searchable map[int]bool) error {
+	insertTitle, err := tx.Prepare(
+		"INSERT INTO titles (id, type, title, original_title, year, minutes, " +
+			"rating, votes, series_id, season, episode) " +
+			"VALUES (?, ?, ?, ?, ?, ?, 0, 0, 0, 0, 0)",
+	)
+	if err != nil {
+		return err
+	}
+	defer insertTitle.Close()
+
+	insertWord, err := tx.Prepare("INSERT INTO words (word, title_id) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertWord.Close()
+
+	for r.Next() {
+		id, err := r.ID("tconst")
+		if err != nil {
+			return err
+		}
+
+		itemType := itemType(r.Field("titleType"))
+		if itemType == imdb.Unknown {
+			continue
+		}
+
+		title := r.Field("primaryTitle")
+		originalTitle := r.Field("originalTitle")
+
+		_, err = insertTitle.Exec(
+			id, itemType, title, originalTitle,
+			r.Int("startYear"), r.Int("runtimeMinutes"),
+		)
+		if err != nil {
+			return err
+		}
+
+		if itemType != imdb.Episode {
+			// there are too many episodes to index their alternative titles
+			searchable[id] = true
+		}
+
+		for _, word := range uniqueWords(release.TitleWords(title + " " + originalTitle)) {
+			_, err = insertWord.Exec(word, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.Err()
+}
+
+func ingestEpisodes(tx *sql.Tx, r *tsvReader) error {
+	update, err := tx.Prepare(
+		"UPDATE titles SET series_id = ?, season = ?, episode = ? WHERE id = ?",
+	)
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+
+	for r.Next() {
+		id, err := r.ID("tconst")
+		if err != nil {
+			return err
+		}
+		seriesID, err := r.ID("parentTconst")
+		if err != nil {
+			return err
+		}
+
+		_, err = update.Exec(
+			seriesID, r.Int("seasonNumber"), r.Int("episodeNumber"), id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.Err()
+}
+
+func ingestRatings(tx *sql.Tx, r *tsvReader) error {
+	update, err := tx.Prepare("UPDATE titles SET rating = ?, votes = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+
+	for r.Next() {
+		id, err := r.ID("tconst")
+		if err != nil {
+			return err
+		}
+
+		rating, _ := strconv.ParseFloat(r.Field("averageRating"), 32)
+
+		_, err = update.Exec(rating, r.Int("numVotes"), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.Err()
+}
+
+// ingestAkas stores the alternative titles. Episodes are skipped.
+func ingestAkas(tx *sql.Tx, r *tsvReader, searchable map[int]bool) error {
+	insertAka, err := tx.Prepare(
+		"INSERT INTO akas (title_id, title, region, kind) VALUES (?, ?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer insertAka.Close()
+
+	insertWord, err := tx.Prepare("INSERT INTO words (word, title_id) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertWord.Close()
+
+	for r.Next() {
+		id, err := r.ID("titleId")
+		if err != nil {
+			return err
+		}
+		if !searchable[id] || r.Field("isOriginalTitle") == "1" {
+			continue
+		}
+
+		title := r.Field("title")
+		kind := strings.Join(
+			append(r.List("types"), r.List("attributes")...), ", ",
+		)
+
+		_, err = insertAka.Exec(id, title, r.Field("region"), kind)
+		if err != nil {
+			return err
+		}
+
+		for _, word := range uniqueWords(release.TitleWords(title)) {
+			_, err = insertWord.Exec(word, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.Err()
+}
+
+// withDataset opens the dataset with the given name (e.g. title.basics)
+// from dir and calls f with a reader for it
+func withDataset(dir string, name string, required bool, f func(r *tsvReader) error) error {
+	var (
+		file *os.File
+		err  error
+	)
+	for _, filename := range []string{name + ".tsv.gz", name + ".tsv"} {
+		file, err = os.Open(filepath.Join(dir, filename))
+		if err == nil || !os.IsNotExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return fmt.Errorf("unable to open dataset %s: %s", name, err)
+	}
+	defer file.Close()
+
+	var input io.Reader = file
+	if strings.HasSuffix(file.Name(), ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("unable to decompress dataset %s: %s", name, err)
+		}
+		defer gz.Close()
+		input = gz
+	}
+
+	r, err := newTsvReader(input)
+	if err != nil {
+		return fmt.Errorf("unable to read dataset %s: %s", name, err)
+	}
+
+	err = f(r)
+	if err != nil {
+		return fmt.Errorf("unable to ingest dataset %s: %s", name, err)
+	}
+	return nil
+}
+
+// itemType converts a titleType from the datasets to an item type.
+// Types which aren't movies, series or episodes (such as video games)
+// are Unknown.
+func itemType(titleType string) imdb.ItemType {
+	switch titleType {
+	case "movie", "tvMovie", "video", "short", "tvShort", "tvSpecial":
+		return imdb.Movie
+	case "tvSeries", "tvMiniSeries":
+		return imdb.Series
+	case "tvEpisode":
+		return imdb.Episode
+	default:
+		return imdb.Unknown
+	}
+}
+
+func uniqueWords(words []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			unique = append(unique, word)
+		}
+	}
+	return unique
+}
+
+// tsvReader reads the tab-separated dataset files, which have a header
+// row, no quoting and \N for missing values
+type tsvReader struct {
+	scanner *bufio.Scanner
+	columns map[string]int
+	fields  []string
+	line    int
+}
+
+func newTsvReader(input io.Reader) (*tsvReader, error) {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if scanner.Err() != nil {
+			return nil, scanner.Err()
+		}
+		return nil, fmt.Errorf("no header row")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range strings.Split(scanner.Text(), "\t") {
+		columns[name] = i
+	}
+
+	return &tsvReader{
+		scanner: scanner,
+		columns: columns,
+		line:    1,
+	}, nil
+}
+
+// Next reads the next row, returning false at the end of the file
+func (r *tsvReader) Next() bool {
+	if !r.scanner.Scan() {
+		return false
+	}
+	r.line++
+	r.fields = strings.Split(r.scanner.Text(), "\t")
+	return true
+}
+
+// Err returns the error which stopped reading, if any
+func (r *tsvReader) Err() error {
+	return r.scanner.Err()
+}
+
+// Field returns the value of the given column in the current row, or an
+// empty string if it's missing
+func (r *tsvReader) Field(name string) string {
+	i, ok := r.columns[name]
+	if !ok || i >= len(r.fields) || r.fields[i] == `\N` {
+		return ""
+	}
+	return r.fields[i]
+}
+
+// List returns the values of a column which contains an array
+func (r *tsvReader) List(name string) []string {
+	var values []string
+	for _, value := range strings.Split(r.Field(name), "\x02") {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Int returns the value of the given column as an integer, or 0 if it's
+// missing or invalid
+func (r *tsvReader) Int(name string) int {
+	value, _ := strconv.Atoi(r.Field(name))
+	return value
+}
+
+// ID parses an IMDB ID (tt1234567) from the given column
+func (r *tsvReader) ID(name string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.Field(name), "tt"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid id on line %d: %s", r.line, r.Field(name))
+	}
+	return id, nil
+}
@@ -2,6 +2,7 @@ package imdb
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -40,3 +41,25 @@ func TestRatingEdgeCases_AllData(t *testing.T) {
 	assert.InDelta(8.4, data.Rating, 0.1)
 	assert.Equal(4, data.Votes/100)
 }
+
+func TestParseAirDate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(
+		time.Date(2008, time.June, 15, 0, 0, 0, 0, time.UTC),
+		parseAirDate("\n    15 Jun. 2008\n    "),
+	)
+	assert.Equal(
+		time.Date(2008, time.May, 4, 0, 0, 0, 0, time.UTC),
+		parseAirDate("4 May 2008"),
+	)
+	assert.Equal(
+		time.Date(2017, time.September, 1, 0, 0, 0, 0, time.UTC),
+		parseAirDate("Sep. 2017"),
+	)
+	assert.Equal(
+		time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
+		parseAirDate("2018"),
+	)
+	assert.True(parseAirDate("").IsZero())
+}
@@ -0,0 +1,322 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cep21/xdgbasedir"
+)
+
+// Cache is a HTTP client which stores successful responses to GET requests
+// in a directory. It implements imdb.HttpGetter.
+type Cache struct {
+	// Client performs the actual requests (nil means http.DefaultClient)
+	Client *http.Client
+	// Policy decides how long pages are kept
+	Policy Policy
+	// MaxSize is the maximum total size of the stored pages in bytes
+	// (0 means unlimited)
+	MaxSize int64
+
+	dir  string
+	lock sync.Mutex
+	size int64
+	now  func() time.Time
+}
+
+// entry is the metadata of a stored page
+type entry struct {
+	URL          string    `json:"url"`
+	Expires      time.Time `json:"expires"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+}
+
+// DefaultDirectory returns the directory for the cache inside the
+// user's XDG cache directory
+func DefaultDirectory() (string, error) {
+	base, err := xdgbasedir.CacheDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "mvm", "http"), nil
+}
+
+// New creates a cache in the given directory (creating it if needed) with
+// the default policy and no size limit
+func New(dir string) (*Cache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		Policy: DefaultPolicy,
+		dir:    dir,
+		now:    time.Now,
+	}
+
+	pages, err := c.pages()
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		c.size += page.Size()
+	}
+
+	return c, nil
+}
+
+// Get returns the stored page for the url if it hasn't expired, and
+// downloads it otherwise. Expired pages are revalidated if the server
+// has sent an ETag or a Last-Modified date for them, and are returned
+// as they are if the server can't be reached.
+func (c *Cache) Get(url string) (*http.Response, error) {
+	return c.GetContext(context.Background(), url)
+}
+
+// GetContext is like Get, but the request is cancelled with the context
+func (c *Cache) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	key := cacheKey(url)
+	cached, body := c.load(key, url)
+
+	if cached != nil && c.now().Before(cached.Expires) {
+		c.touch(key)
+		return cachedResponse(cached, body), nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			request.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			request.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.client().Do(request)
+	if err != nil {
+		if cached != nil && ctx.Err() == nil {
+			return cachedResponse(cached, body), nil
+		}
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		_ = resp.Body.Close()
+
+		cached.Expires = c.now().Add(c.Policy.TTL(url, body, c.now()))
+		// if this fails, the page will be revalidated again next time
+		_ = writeFile(c.path(key, ".json"), cached)
+		c.touch(key)
+
+		return cachedResponse(cached, body), nil
+	case resp.StatusCode == http.StatusOK:
+		data, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+		// failing to store the page only means it will be downloaded again
+		_ = c.store(key, &entry{
+			URL:          url,
+			Expires:      c.now().Add(c.Policy.TTL(url, data, c.now())),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			ContentType:  resp.Header.Get("Content-Type"),
+		}, data)
+
+		return resp, nil
+	default:
+		return resp, nil
+	}
+}
+
+// Size returns the total size of the stored pages in bytes
+func (c *Cache) Size() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.size
+}
+
+func (c *Cache) client() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *Cache) path(key string, extension string) string {
+	return filepath.Join(c.dir, key+extension)
+}
+
+// load returns the stored page for the url, or nil if there's none
+func (c *Cache) load(key string, url string) (*entry, []byte) {
+	metadata, err := ioutil.ReadFile(c.path(key, ".json"))
+	if err != nil {
+		return nil, nil
+	}
+
+	cached := &entry{}
+	err = json.Unmarshal(metadata, cached)
+	if err != nil || cached.URL != url {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadFile(c.path(key, ".body"))
+	if err != nil {
+		return nil, nil
+	}
+
+	return cached, body
+}
+
+// store saves the page and evicts old pages if the cache is too big
+func (c *Cache) store(key string, cached *entry, body []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if info, err := os.Stat(c.path(key, ".body")); err == nil {
+		c.size -= info.Size()
+	}
+
+	err := writeFile(c.path(key, ".body"), body)
+	if err != nil {
+		return err
+	}
+	c.size += int64(len(body))
+	c.touch(key)
+
+	err = writeFile(c.path(key, ".json"), cached)
+	if err != nil {
+		return err
+	}
+
+	if c.MaxSize > 0 && c.size > c.MaxSize {
+		return c.evict()
+	}
+	return nil
+}
+
+// evict removes the least recently used pages until the cache fits
+// in MaxSize. It must be called with the lock held.
+func (c *Cache) evict() error {
+	pages, err := c.pages()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].ModTime().Before(pages[j].ModTime())
+	})
+
+	for _, page := range pages {
+		if c.size <= c.MaxSize {
+			break
+		}
+
+		key := strings.TrimSuffix(page.Name(), ".body")
+		err = os.Remove(c.path(key, ".body"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		_ = os.Remove(c.path(key, ".json"))
+
+		c.size -= page.Size()
+	}
+
+	return nil
+}
+
+// touch marks the page as recently used
+func (c *Cache) touch(key string) {
+	now := c.now()
+	_ = os.Chtimes(c.path(key, ".body"), now, now)
+}
+
+// pages lists the files containing the stored pages
+func (c *Cache) pages() ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".body") {
+			pages = append(pages, file)
+		}
+	}
+	return pages, nil
+}
+
+func cacheKey(url string) string {
+	hash := sha1.Sum([]byte(url))
+	return hex.EncodeToString(hash[:])
+}
+
+// writeFile atomically writes data (bytes or an object to be encoded as
+// JSON) to the file
+func writeFile(filename string, data interface{}) error {
+	raw, ok := data.([]byte)
+	if !ok {
+		var err error
+		raw, err = json.Marshal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(raw)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+func cachedResponse(cached *entry, body []byte) *http.Response {
+	header := make(http.Header)
+	if cached.ContentType != "" {
+		header.Set("Content-Type", cached.ContentType)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
@@ -0,0 +1,222 @@
+package httpcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testServer struct {
+	*httptest.Server
+
+	requests    int
+	revalidated int
+	pages       map[string]string
+}
+
+func newTestServer() *testServer {
+	s := &testServer{pages: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s.requests++
+
+			page, ok := s.pages[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			etag := fmt.Sprintf(`"%x"`, len(page))
+			if r.Header.Get("If-None-Match") == etag {
+				s.revalidated++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, page)
+		},
+	))
+	return s
+}
+
+func testCache(t *testing.T, server *testServer) (*Cache, *time.Time) {
+	dir, err := ioutil.TempDir("", "httpcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Client = server.Client()
+
+	now := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	return cache, &now
+}
+
+func get(t *testing.T, cache *Cache, url string) string {
+	resp, err := cache.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCacheGet(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	server.pages["/title/tt0403358/"] = "<title>Nochnoy dozor (2004) - IMDb</title>"
+
+	cache, now := testCache(t, server)
+	defer os.RemoveAll(cache.dir)
+
+	assert := assert.New(t)
+	url := server.URL + "/title/tt0403358/"
+
+	assert.Equal(server.pages["/title/tt0403358/"], get(t, cache, url))
+	assert.Equal(1, server.requests)
+
+	assert.Equal(server.pages["/title/tt0403358/"], get(t, cache, url))
+	assert.Equal(1, server.requests, "fresh page is downloaded again")
+
+	// an old movie is kept for a month
+	*now = now.Add(29 * 24 * time.Hour)
+	get(t, cache, url)
+	assert.Equal(1, server.requests)
+
+	*now = now.Add(2 * 24 * time.Hour)
+	assert.Equal(server.pages["/title/tt0403358/"], get(t, cache, url))
+	assert.Equal(2, server.requests)
+	assert.Equal(1, server.revalidated)
+
+	// pages which aren't found aren't stored
+	resp, err := cache.Get(server.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	resp, err = cache.Get(server.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(4, server.requests)
+}
+
+func TestCacheChangedPage(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	server.pages["/title/tt0944947/"] = "<title>Game of Thrones (TV Series 2011– ) - IMDb</title>"
+
+	cache, now := testCache(t, server)
+	defer os.RemoveAll(cache.dir)
+
+	url := server.URL + "/title/tt0944947/"
+	get(t, cache, url)
+
+	// running series are revalidated daily
+	*now = now.Add(25 * time.Hour)
+	server.pages["/title/tt0944947/"] += "<p>new episode</p>"
+
+	assert.True(t, strings.HasSuffix(get(t, cache, url), "new episode</p>"))
+	assert.Equal(t, 2, server.requests)
+	assert.Equal(t, 0, server.revalidated)
+}
+
+func TestCacheStaleWhenOffline(t *testing.T) {
+	server := newTestServer()
+	server.pages["/find"] = "results"
+
+	cache, now := testCache(t, server)
+	defer os.RemoveAll(cache.dir)
+
+	url := server.URL + "/find"
+	get(t, cache, url)
+	server.Close()
+
+	*now = now.Add(30 * 24 * time.Hour)
+	assert.Equal(t, "results", get(t, cache, url))
+}
+
+func TestCacheEviction(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		server.pages["/"+name] = strings.Repeat(name, 100)
+	}
+
+	cache, now := testCache(t, server)
+	defer os.RemoveAll(cache.dir)
+	cache.MaxSize = 250
+
+	assert := assert.New(t)
+
+	get(t, cache, server.URL+"/a")
+	*now = now.Add(time.Minute)
+	get(t, cache, server.URL+"/b")
+	*now = now.Add(time.Minute)
+	get(t, cache, server.URL+"/a") // a is now more recently used than b
+	*now = now.Add(time.Minute)
+	get(t, cache, server.URL+"/c")
+
+	assert.Equal(int64(200), cache.Size())
+	assert.Equal(3, server.requests)
+
+	get(t, cache, server.URL+"/a")
+	get(t, cache, server.URL+"/c")
+	assert.Equal(3, server.requests)
+
+	get(t, cache, server.URL+"/b")
+	assert.Equal(4, server.requests, "least recently used page not evicted")
+
+	reopened, err := New(cache.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(int64(200), reopened.Size())
+}
+
+func TestPolicyTTL(t *testing.T) {
+	now := time.Date(2017, time.March, 1, 0, 0, 0, 0, time.UTC)
+	policy := &DefaultPolicy
+
+	for _, test := range []struct {
+		url  string
+		body string
+		ttl  time.Duration
+	}{
+		{"/title/tt0403358/combined", "<title>Nochnoy dozor (2004)</title>", policy.OldMovieTTL},
+		{"/title/tt2527336/combined", "<title>Star Wars: The Last Jedi (2017)</title>", policy.DefaultTTL},
+		{"/title/tt0295630/combined", "<title>Star Wars (1987/I)</title>", policy.OldMovieTTL},
+		{"/title/tt0944947/combined", "<title>Game of Thrones (TV Series 2011– )</title>", policy.RunningSeriesTTL},
+		{"/title/tt0306414/combined", "<title>The Wire (TV Series 2002–2008)</title>", policy.OldMovieTTL},
+		{"/title/tt0944947/episodes?season=4", "<title>Game of Thrones - Season 4</title>", policy.RunningSeriesTTL},
+		{"/title/tt2816136/combined", "<title>\"Game of Thrones\" Two Swords (TV Episode 2014)</title>", policy.OldMovieTTL},
+		{"/find?q=stalker", "<title>Find - IMDb</title>", policy.DefaultTTL},
+		{"/find?q=stalker", "no title", policy.DefaultTTL},
+	} {
+		assert.Equal(
+			t, test.ttl, policy.TTL(test.url, []byte(test.body), now), test.body,
+		)
+	}
+}
@@ -0,0 +1,10 @@
+// Package httpcache implements a HTTP client which stores the pages it
+// downloads on disk, so that repeated imports and queries of the same
+// titles don't hit IMDB again.
+//
+// Each page is kept for a time which depends on its content: pages of
+// old movies rarely change, while those of running series get new
+// episodes all the time. Expired pages are revalidated with their ETag
+// or Last-Modified date, and the least recently used pages are evicted
+// when the cache grows over its size limit.
+package httpcache
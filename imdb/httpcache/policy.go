@@ -0,0 +1,71 @@
+package httpcache
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Policy decides how long pages are kept, based on their URL and content
+type Policy struct {
+	// OldMovieTTL is for titles (and their pages) released at least
+	// OldMovieAge ago, and for series which have ended
+	OldMovieTTL time.Duration
+	// RunningSeriesTTL is for series which are still running and for
+	// their season pages
+	RunningSeriesTTL time.Duration
+	// DefaultTTL is for everything else, e.g. recent movies and searches
+	DefaultTTL time.Duration
+
+	OldMovieAge time.Duration
+}
+
+// DefaultPolicy keeps old movies for a month, running series for a day
+// and everything else for a week
+var DefaultPolicy = Policy{
+	OldMovieTTL:      30 * 24 * time.Hour,
+	RunningSeriesTTL: 24 * time.Hour,
+	DefaultTTL:       7 * 24 * time.Hour,
+	OldMovieAge:      2 * 365 * 24 * time.Hour,
+}
+
+var (
+	titleMatcher         = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+	runningSeriesMatcher = regexp.MustCompile(`\(.*\d{4}\s*(–|-)\s*\)`)
+	endedSeriesMatcher   = regexp.MustCompile(`\(.*\d{4}\s*(–|-)\s*\d{4}\)`)
+	yearMatcher          = regexp.MustCompile(`\((?:[^()]*\s)?(\d{4})(?:/[IVX]+)?\)`)
+	seasonURLMatcher     = regexp.MustCompile(`/episodes(\?|$)`)
+)
+
+// TTL returns the time for which the page should be kept
+func (p *Policy) TTL(url string, body []byte, now time.Time) time.Duration {
+	title := titleMatcher.FindSubmatch(body)
+	if title == nil {
+		return p.DefaultTTL
+	}
+
+	switch {
+	case runningSeriesMatcher.Match(title[1]):
+		return p.RunningSeriesTTL
+	case endedSeriesMatcher.Match(title[1]):
+		return p.OldMovieTTL
+	case seasonURLMatcher.MatchString(url):
+		// we don't know if the series is still running
+		return p.RunningSeriesTTL
+	}
+
+	year := yearMatcher.FindSubmatch(title[1])
+	if year == nil {
+		return p.DefaultTTL
+	}
+	number, err := strconv.Atoi(string(year[1]))
+	if err != nil {
+		return p.DefaultTTL
+	}
+
+	released := time.Date(number+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if now.Sub(released) >= p.OldMovieAge {
+		return p.OldMovieTTL
+	}
+	return p.DefaultTTL
+}
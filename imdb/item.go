@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +23,7 @@ type Item struct {
 	itemType             ItemType
 	season               *int
 	episode              *int
+	releaseDate          *time.Time
 	cachedDocuments      map[string]*htmlParser.HtmlDocument
 	cacheIndividualLocks map[string]*sync.Mutex
 	cacheLock            sync.Mutex
@@ -77,6 +79,16 @@ func New(id int) *Item {
 	}
 }
 
+// NewFromShort creates an item whose Type, Title and Year methods return
+// the data from the ShortItem without making any requests
+func NewFromShort(short *ShortItem) *Item {
+	item := New(short.ID)
+	item.itemType = short.Type
+	item.title = &short.Title
+	item.year = &short.Year
+	return item
+}
+
 // NewWithClient creates a item from an IMDB ID which will use the given
 // HTTP client to communicate with IMDBIMDB.
 func NewWithClient(id int, client HttpGetter) *Item {
@@ -180,6 +192,24 @@ func idFromLink(link string) (int, error) {
 	return id, nil
 }
 
+// ParseID extracts an IMDB ID from user input: either a bare number
+// (1234567), a prefixed id (tt1234567) or a link to the item's page
+func ParseID(text string) (int, error) {
+	text = strings.TrimSpace(text)
+
+	if id, err := strconv.Atoi(text); err == nil {
+		if id <= 0 {
+			return 0, fmt.Errorf("invalid imdb id: %s", text)
+		}
+		return id, nil
+	}
+
+	if strings.HasPrefix(text, "tt") {
+		text = "/" + text
+	}
+	return idFromLink(text)
+}
+
 // parseDate parses a date from IMDB's default format
 func parseDate(text string) (time.Time, error) {
 	t, err := time.Parse("2 January 2006", text)
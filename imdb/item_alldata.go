@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	htmlParser "github.com/jbowtie/gokogiri/html"
 )
 
 // ShortItem contains only the essential data to identify an item
@@ -312,6 +315,28 @@ func (i *Item) MarshalJSON() ([]byte, error) {
 	return json.Marshal(short)
 }
 
+// UnmarshalJSON unmarshals an item from a ShortItem. The resulting item's
+// Type, Title and Year methods will return the unmarshaled data.
+func (i *Item) UnmarshalJSON(data []byte) error {
+	short := &ShortItem{}
+	err := json.Unmarshal(data, short)
+	if err != nil {
+		return err
+	}
+
+	i.id = short.ID
+	i.itemType = short.Type
+	i.title = &short.Title
+	i.year = &short.Year
+	if i.cachedDocuments == nil {
+		i.cachedDocuments = make(map[string]*htmlParser.HtmlDocument)
+	}
+	if i.cacheIndividualLocks == nil {
+		i.cacheIndividualLocks = make(map[string]*sync.Mutex)
+	}
+	return nil
+}
+
 // String returns the data in a human-readable form
 func (s *ShortItem) String() string {
 	text := fmt.Sprintf(
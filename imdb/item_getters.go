@@ -163,6 +163,10 @@ func (s *Item) OtherTitles() (map[string]string, error) {
 // ReleaseDate returns the item's release date.
 // Only applicable for Movie and Episode.
 func (s *Item) ReleaseDate() (time.Time, error) {
+	if s.releaseDate != nil {
+		return *s.releaseDate, nil
+	}
+
 	itemType, err := s.Type()
 	if err != nil {
 		return time.Time{}, err
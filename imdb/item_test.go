@@ -744,3 +744,63 @@ func ExampleItem_MarshalJSON() {
 	// Output:
 	// {"id":403358,"title":"Nochnoy dozor","type":2,"year":2004}
 }
+
+func ExampleItem_UnmarshalJSON() {
+	movie := &Item{}
+	err := json.Unmarshal(
+		[]byte(`{"id":403358,"title":"Nochnoy dozor","type":2,"year":2004}`),
+		movie,
+	)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return
+	}
+	defer movie.Free()
+
+	title, _ := movie.Title()
+	year, _ := movie.Year()
+
+	fmt.Printf("%07d: %s (%d)\n", movie.ID(), title, year)
+
+	// Output:
+	// 0403358: Nochnoy dozor (2004)
+}
+
+func ExampleParseID() {
+	for _, text := range []string{
+		"403358",
+		"tt0403358",
+		"http://www.imdb.com/title/tt0403358/?ref_=nv_sr_1",
+	} {
+		id, err := ParseID(text)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			continue
+		}
+		fmt.Printf("id: %d\n", id)
+	}
+
+	// Output:
+	// id: 403358
+	// id: 403358
+	// id: 403358
+}
+
+func ExampleNewFromShort() {
+	movie := NewFromShort(&ShortItem{
+		ID:    79944,
+		Title: "Stalker",
+		Type:  Movie,
+		Year:  1979,
+	})
+	defer movie.Free()
+
+	title, _ := movie.Title()
+	year, _ := movie.Year()
+	itemType, _ := movie.Type()
+
+	fmt.Printf("%s (%d): %s\n", title, year, itemType)
+
+	// Output:
+	// Stalker (1979): Movie
+}
@@ -17,23 +17,63 @@ type Client struct {
 
 // Item returns information for an item with a given IMDB ID
 func (c *Client) Item(id int) (*imdb.ItemData, error) {
-	resp, err := c.HttpClient.Get(fmt.Sprintf(
-		"%s/item?id=%d", c.Address, id,
-	))
+	data := &imdb.ItemData{}
+	err := c.get(fmt.Sprintf("%s/item?id=%d", c.Address, id), data)
 	if err != nil {
 		return nil, err
 	}
+	return data, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http error: %s", resp.Status)
+// Series returns information for a series with a given IMDB ID
+func (c *Client) Series(id int) (*imdb.ItemData, error) {
+	return c.Item(id)
+}
+
+// Season returns the episodes of a season of the series with the given
+// IMDB ID. Only their ids, titles, season and episode numbers and air
+// dates are set.
+func (c *Client) Season(seriesID int, number int) ([]*imdb.ItemData, error) {
+	var data []*imdb.ItemData
+	err := c.get(
+		fmt.Sprintf("%s/season?id=%d&number=%d", c.Address, seriesID, number),
+		&data,
+	)
+	if err != nil {
+		return nil, err
 	}
+	return data, nil
+}
 
-	data := &imdb.ItemData{}
-	err = json.NewDecoder(resp.Body).Decode(data)
+// Episodes returns the episodes of all seasons of the series with the
+// given IMDB ID. Only their ids, titles, season and episode numbers and
+// air dates are set.
+func (c *Client) Episodes(seriesID int) ([]*imdb.ItemData, error) {
+	var data []*imdb.ItemData
+	err := c.get(
+		fmt.Sprintf("%s/series/episodes?id=%d", c.Address, seriesID),
+		&data,
+	)
 	if err != nil {
 		return nil, err
 	}
-	return data, err
+	return data, nil
+}
+
+func (c *Client) get(url string, data interface{}) error {
+	resp, err := c.HttpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http error: %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(data)
 }
 
 // SearchQuery performs a search on IMDB
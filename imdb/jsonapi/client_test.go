@@ -3,8 +3,12 @@ package jsonapi
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
 
 	"github.com/DexterLB/mvm/imdb"
+	"github.com/stretchr/testify/assert"
 )
 
 func ExampleClient_Item() {
@@ -93,3 +97,71 @@ func ExampleClient_Search() {
 	// Output:
 	// [Movie] 0079944: Stalker (1979)
 }
+
+func TestClientEpisodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/series/episodes":
+				assert.Equal(t, "944947", r.URL.Query().Get("id"))
+				fmt.Fprint(w, `[
+					{"id":1480055,"type":4,"title":"Winter Is Coming","season_number":1,"episode_number":1,"release_date":"2011-04-17T00:00:00Z"},
+					{"id":2816136,"type":4,"title":"Two Swords","season_number":4,"episode_number":1,"release_date":"2014-04-06T00:00:00Z"}
+				]`)
+			case "/season":
+				assert.Equal(t, "944947", r.URL.Query().Get("id"))
+				assert.Equal(t, "4", r.URL.Query().Get("number"))
+				fmt.Fprint(w, `[{"id":2816136,"type":4,"title":"Two Swords","season_number":4,"episode_number":1}]`)
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+
+	client := &Client{
+		HttpClient: server.Client(),
+		Address:    server.URL,
+	}
+
+	assert := assert.New(t)
+
+	episodes, err := client.Episodes(944947)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(2, len(episodes))
+	assert.Equal(1480055, episodes[0].ID)
+	assert.Equal(imdb.Episode, episodes[0].Type)
+	assert.Equal("Winter Is Coming", episodes[0].Title)
+	assert.Equal(4, episodes[1].SeasonNumber)
+	assert.Equal(
+		time.Date(2014, time.April, 6, 0, 0, 0, 0, time.UTC),
+		episodes[1].ReleaseDate,
+	)
+
+	season, err := client.Season(944947, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1, len(season))
+	assert.Equal("Two Swords", season[0].Title)
+
+	_, err = client.Item(403358)
+	assert.Equal("http error: 404 Not Found", err.Error())
+}
+
+func TestServerParameters(t *testing.T) {
+	server := &Server{}
+
+	for _, url := range []string{
+		"/season?id=944947",
+		"/season?number=4",
+		"/series/episodes",
+		"/series/episodes?id=foo",
+	} {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, 500, recorder.Code, url)
+	}
+}
@@ -20,6 +20,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	mux.HandleFunc("/item", s.item)
 	mux.HandleFunc("/search", s.search)
+	mux.HandleFunc("/season", s.season)
+	mux.HandleFunc("/series/episodes", s.seriesEpisodes)
 
 	mux.ServeHTTP(w, r)
 }
@@ -146,6 +148,109 @@ func (s *Server) item(w http.ResponseWriter, r *http.Request) {
 	writeData(w, data)
 }
 
+// season returns the episodes of a single season of the series,
+// e.g. /season?id=944947&number=4
+func (s *Server) season(w http.ResponseWriter, r *http.Request) {
+	parameters := r.URL.Query()
+	id, err := strconv.Atoi(parameters.Get("id"))
+	if err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("ID parameter missing or not integer: %s", err),
+			500,
+		)
+		return
+	}
+	number, err := strconv.Atoi(parameters.Get("number"))
+	if err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("number parameter missing or not integer: %s", err),
+			500,
+		)
+		return
+	}
+
+	series := imdb.NewWithClient(id, s.ImdbClient)
+	defer series.Free()
+
+	seasons, err := series.Seasons()
+	if err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("Unable to get seasons from imdb: %s", err),
+			500,
+		)
+		return
+	}
+
+	for _, season := range seasons {
+		seasonNumber, err := season.Number()
+		if err != nil || seasonNumber != number {
+			continue
+		}
+
+		data, err := season.EpisodesData()
+		if err != nil {
+			http.Error(
+				w,
+				fmt.Sprintf("Unable to get episodes from imdb: %s", err),
+				500,
+			)
+			return
+		}
+
+		writeData(w, data)
+		return
+	}
+
+	http.Error(w, fmt.Sprintf("No season %d", number), 404)
+}
+
+// seriesEpisodes returns the episodes of all seasons of the series,
+// e.g. /series/episodes?id=944947
+func (s *Server) seriesEpisodes(w http.ResponseWriter, r *http.Request) {
+	parameters := r.URL.Query()
+	id, err := strconv.Atoi(parameters.Get("id"))
+	if err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("ID parameter missing or not integer: %s", err),
+			500,
+		)
+		return
+	}
+
+	series := imdb.NewWithClient(id, s.ImdbClient)
+	defer series.Free()
+
+	seasons, err := series.Seasons()
+	if err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("Unable to get seasons from imdb: %s", err),
+			500,
+		)
+		return
+	}
+
+	data := []*imdb.ItemData{}
+	for _, season := range seasons {
+		episodes, err := season.EpisodesData()
+		if err != nil {
+			http.Error(
+				w,
+				fmt.Sprintf("Unable to get episodes from imdb: %s", err),
+				500,
+			)
+			return
+		}
+		data = append(data, episodes...)
+	}
+
+	writeData(w, data)
+}
+
 func writeData(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
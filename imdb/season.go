@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	htmlParser "github.com/jbowtie/gokogiri/html"
 	"github.com/jbowtie/gokogiri/xml"
@@ -63,8 +64,11 @@ func (s *Season) Number() (int, error) {
 }
 
 // Episodes returns an ordered slice of all episodes in this season.
-// The returned items will have be of type Episode, and their Title and
-// SeasonEpisode methods will return pre-cached results.
+// The returned items will have be of type Episode, and their Title,
+// SeasonEpisode and ReleaseDate methods will return pre-cached results.
+// ReleaseDate returns a zero time for episodes which haven't been given
+// an air date yet, and only the year (or month) may be set for episodes
+// whose exact air date isn't known.
 //
 // Please note that although the episodes will probably be in the order
 // they've come out, you shouldn't count on the fact that episode numbers
@@ -101,6 +105,29 @@ func (s *Season) Episodes() ([]*Item, error) {
 	return episodes, nil
 }
 
+// EpisodesData returns the data of the season's episodes which is
+// available from the season page: their ids, titles, season and episode
+// numbers and air dates
+func (s *Season) EpisodesData() ([]*ItemData, error) {
+	episodes, err := s.Episodes()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]*ItemData, len(episodes))
+	for i, episode := range episodes {
+		data[i] = &ItemData{
+			ID:            episode.id,
+			Type:          Episode,
+			Title:         *episode.title,
+			SeasonNumber:  *episode.season,
+			EpisodeNumber: *episode.episode,
+			ReleaseDate:   *episode.releaseDate,
+		}
+	}
+	return data, nil
+}
+
 // episode parses episode data from the episode's html element
 func (s *Season) episode(element xml.Node) (*Item, error) {
 	idMatcher := regexp.MustCompile(`tt([0-9]+)`)
@@ -140,15 +167,48 @@ func (s *Season) episode(element xml.Node) (*Item, error) {
 		return nil, err
 	}
 
+	var airDate time.Time
+	airDateElement, err := firstMatchingOnNode(
+		element,
+		`.//div[contains(@class,'airdate')]`,
+	)
+	if err == nil {
+		airDate = parseAirDate(airDateElement.Content())
+	}
+
 	return &Item{
-		id:       id,
-		title:    &title,
-		itemType: Episode,
-		season:   &seasonNumber,
-		episode:  &number,
+		id:          id,
+		title:       &title,
+		itemType:    Episode,
+		season:      &seasonNumber,
+		episode:     &number,
+		releaseDate: &airDate,
 	}, nil
 }
 
+// parseAirDate parses an air date from a season's page. Dates with
+// unknown day or month are approximated, and unknown dates are zero.
+func parseAirDate(text string) time.Time {
+	text = strings.Join(strings.Fields(text), " ")
+
+	for _, layout := range []string{
+		"2 Jan. 2006",
+		"2 January 2006",
+		"2 Jan 2006",
+		"Jan. 2006",
+		"January 2006",
+		"Jan 2006",
+		"2006",
+	} {
+		date, err := time.Parse(layout, text)
+		if err == nil {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
+
 func (s *Season) page() (*xml.ElementNode, error) {
 	if s.document == nil {
 		page, err := parsePage(s.client, s.URL())
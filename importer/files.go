@@ -1,87 +1,387 @@
 package importer
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/DexterLB/mvm/config"
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/progress"
+	"github.com/DexterLB/mvm/release"
 	"github.com/DexterLB/mvm/types"
 	"github.com/DexterLB/osdb"
 )
 
-// FileInfo takes filenames and constructs video file data
-func (c *Context) FileInfo(filenames <-chan string, files chan<- *library.VideoFile) {
+// FileInfo takes filenames and constructs video file data. Files whose
+// import steps have all been done during previous imports are skipped,
+// unless they've changed on disk or Refresh is set.
+func (c *Context) FileInfo(ctx context.Context, filenames <-chan string, files chan<- *library.VideoFile) {
 	defer close(files)
 
-	for {
-		select {
-		case filename, ok := <-filenames:
-			if !ok {
-				return
-			}
-			relativePath, err := relative(c.Config.FileRoot, filename)
-			if err != nil {
-				c.Errorf("Invalid filename: %s", err)
-				return
+	progress := c.stageProgress(HashStage)
+	for filename := range filenames {
+		progress.AddTotal(1)
+		if ctx.Err() == nil {
+			if file := c.fileInfo(filename); file != nil {
+				files <- file
 			}
+		}
+		progress.Add(1)
+	}
+}
 
-			file, err := c.Library.GetFileByPath(relativePath)
-			if err != nil {
-				c.Errorf("Library error while looking up file: %s", err)
-				return
-			}
+// fileInfo returns the file with the given name, or nil if it doesn't
+// need to be imported
+func (c *Context) fileInfo(filename string) *library.VideoFile {
+	relativePath, err := relative(c.Config.FileRoot, filename)
+	if err != nil {
+		c.Errorf("Invalid filename: %s", err)
+		return nil
+	}
 
-			file.Size, err = filesize(filename)
-			if err != nil {
-				file.ImportError = types.Errorf(
-					"unable to get file size: %s", err,
-				)
-				continue
-			}
+	file, err := c.lookupFile(filename, relativePath)
+	if err != nil {
+		c.Errorf("Library error while looking up file: %s", err)
+		return nil
+	}
 
-			hash, err := osdb.Hash(filename)
-			if err != nil {
-				file.ImportError = types.Errorf(
-					"unable to calculate file hash: %s", err,
-				)
-				continue
-			}
-			file.OsdbHash = types.BigUint64(hash)
+	setFilenameData(&file.FilenameData, release.Parse(filename))
 
-			file.ImportError = nil
-			files <- file
-		case <-c.Stop:
-			return
+	info, err := os.Stat(filename)
+	if err != nil {
+		file.Step(library.HashStep).Errorf(
+			"unable to get file size: %s", err,
+		)
+		return file
+	}
+
+	if !unchanged(file, info) {
+		// everything needs to be done again for the new contents
+		file.ImportStatus = nil
+	} else if !c.Refresh && c.complete(file) {
+		return nil
+	}
+
+	if c.shouldRun(file.Step(library.HashStep)) {
+		hash, err := osdb.Hash(filename)
+		if err != nil {
+			file.Step(library.HashStep).Errorf(
+				"unable to calculate file hash: %s", err,
+			)
+		} else {
+			file.OsdbHash = types.BigUint64(hash)
+			file.Size = uint64(info.Size())
+			file.ModTime = info.ModTime()
+			file.Step(library.HashStep).Succeed()
 		}
 	}
+
+	return file
 }
 
 // WalkPaths recursively searches for video files in the given directories
 // and sends them on the channel. Non-folder paths are sent as-are.
-func (c *Context) WalkPaths(paths []string, filenames chan<- string) {
+func (c *Context) WalkPaths(ctx context.Context, paths []string, filenames chan<- string) {
 	defer close(filenames)
 
-	// TODO: actually walk directories
+	walker := newPathWalker(ctx, c, filenames)
 	for i := range paths {
-		filenames <- paths[i]
+		if !walker.walkRoot(paths[i]) {
+			return
+		}
+	}
+}
+
+// defaultVideoExtensions is used when the config doesn't specify any
+var defaultVideoExtensions = []string{
+	"3gp", "avi", "divx", "flv", "m2ts", "m4v", "mkv", "mov", "mp4",
+	"mpeg", "mpg", "ogm", "ogv", "rm", "rmvb", "ts", "vob", "webm", "wmv",
+}
+
+type pathWalker struct {
+	ctx        context.Context
+	context    *Context
+	progress   progress.Progress
+	config     *config.Walker
+	extensions map[string]bool
+	filenames  chan<- string
+
+	// visited contains the real paths of all directories walked so far,
+	// so that symlink loops are entered only once
+	visited map[string]bool
+	root    string
+}
+
+func newPathWalker(ctx context.Context, c *Context, filenames chan<- string) *pathWalker {
+	config := &c.Config.Importer.Walker
+
+	return &pathWalker{
+		ctx:        ctx,
+		context:    c,
+		progress:   c.stageProgress(WalkStage),
+		config:     config,
+		extensions: extensionSet(config.VideoExtensions, defaultVideoExtensions),
+		filenames:  filenames,
+		visited:    make(map[string]bool),
+	}
+}
+
+// extensionSet makes a set of lowercase extensions (without the dot),
+// using the defaults if extensions is empty
+func extensionSet(extensions []string, defaults []string) map[string]bool {
+	if len(extensions) == 0 {
+		extensions = defaults
+	}
+
+	set := make(map[string]bool)
+	for i := range extensions {
+		set[strings.ToLower(strings.TrimPrefix(extensions[i], "."))] = true
+	}
+	return set
+}
+
+// hasExtension tells whether the filename's extension is in the set
+func hasExtension(filename string, extensions map[string]bool) bool {
+	return extensions[strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))]
+}
+
+// walkRoot walks a path given by the user. Returns false if the import
+// has been stopped.
+func (w *pathWalker) walkRoot(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		// let FileInfo report the error for nonexistent files
+		return w.send(path)
+	}
+
+	w.root = path
+	return w.walkDir(path)
+}
+
+func (w *pathWalker) walkDir(dir string) bool {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		realDir, err = filepath.Abs(realDir)
+	}
+	if err != nil {
+		w.context.Errorf("unable to resolve directory %s: %s", dir, err)
+		return true
+	}
+	if w.visited[realDir] {
+		return true
+	}
+	w.visited[realDir] = true
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		w.context.Errorf("unable to read directory %s: %s", dir, err)
+		return true
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if w.ignored(path, entry.Name()) {
+			continue
+		}
+
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if !w.config.FollowSymlinks {
+				continue
+			}
+			entry, err = os.Stat(path)
+			if err != nil {
+				continue // dangling symlink
+			}
+		}
+
+		if entry.IsDir() {
+			if !w.walkDir(path) {
+				return false
+			}
+		} else if entry.Mode().IsRegular() && w.isVideo(path) {
+			if !w.send(path) {
+				return false
+			}
+		}
 	}
+
+	return true
 }
 
-func filesize(filename string) (uint64, error) {
-	file, err := os.Open(filename)
-	defer func() {
-		_ = file.Close()
-	}()
+// ignored tells whether a file or directory should be skipped. Hidden
+// entries are always skipped.
+func (w *pathWalker) ignored(path string, name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
 
+	relativePath, err := filepath.Rel(w.root, path)
 	if err != nil {
-		return 0, err
+		relativePath = path
+	}
+
+	for _, pattern := range w.config.IgnorePatterns {
+		subject := name
+		if strings.Contains(pattern, "/") {
+			subject = relativePath
+		}
+		if matched, _ := filepath.Match(pattern, subject); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *pathWalker) isVideo(path string) bool {
+	return hasExtension(path, w.extensions)
+}
+
+func (w *pathWalker) send(path string) bool {
+	select {
+	case w.filenames <- path:
+		w.progress.Add(1)
+		return true
+	case <-w.ctx.Done():
+		return false
 	}
-	fi, err := file.Stat()
+}
+
+// lookupFile finds the file in the library by its path. If the path is new,
+// but the library contains an identical file whose path doesn't exist
+// anymore, the file is assumed to have been moved, and its old entry is
+// moved to the new path.
+func (c *Context) lookupFile(filename string, relativePath string) (*library.VideoFile, error) {
+	known, err := c.Library.HasFileWithPath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		return c.Library.GetFileByPath(relativePath)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		// the error will be reported by FileInfo
+		return c.Library.GetFileByPath(relativePath)
+	}
+	hash, err := osdb.Hash(filename)
 	if err != nil {
-		return 0, err
+		return c.Library.GetFileByPath(relativePath)
+	}
+
+	candidates, err := c.Library.GetFilesByHash(
+		types.BigUint64(hash), uint64(info.Size()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range candidates {
+		if _, err := os.Stat(c.absolute(file.Path)); os.IsNotExist(err) {
+			return file, c.moveFile(file, relativePath)
+		}
+	}
+
+	return c.Library.GetFileByPath(relativePath)
+}
+
+// moveFile changes the file's path and relocates its subtitles which are
+// stored next to it.
+func (c *Context) moveFile(file *library.VideoFile, newPath string) error {
+	oldPrefix := strings.TrimSuffix(file.Path, filepath.Ext(file.Path))
+	newPrefix := strings.TrimSuffix(newPath, filepath.Ext(newPath))
+
+	file.Path = newPath
+	file.OriginalBasename = filepath.Base(newPath)
+	err := c.Library.Save(file)
+	if err != nil {
+		return err
+	}
+
+	for _, subtitle := range file.Subtitles {
+		if !strings.HasPrefix(subtitle.Filename, oldPrefix) {
+			continue
+		}
+
+		newFilename := newPrefix + strings.TrimPrefix(subtitle.Filename, oldPrefix)
+		err = os.Rename(c.absolute(subtitle.Filename), c.absolute(newFilename))
+		if err != nil {
+			c.Errorf("unable to move subtitle %s: %s", subtitle.Filename, err)
+			continue
+		}
+
+		subtitle.Filename = newFilename
+		err = c.Library.Save(subtitle)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteFile removes the file and its subtitles from disk, and then from
+// the library
+func (c *Context) DeleteFile(file *library.VideoFile) error {
+	for _, subtitle := range file.Subtitles {
+		err := os.Remove(c.absolute(subtitle.Filename))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to delete subtitle %s: %s", subtitle.Filename, err)
+		}
+	}
+
+	err := os.Remove(c.absolute(file.Path))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to delete file: %s", err)
+	}
+
+	return c.Library.RemoveFile(file)
+}
+
+func setFilenameData(data *library.FilenameData, info *release.Info) {
+	data.GuessedTitle = info.Title
+	data.GuessedYear = info.Year
+	data.GuessedSeason = info.Season
+	data.GuessedEpisode = info.Episode
+	data.GuessedResolution = info.Resolution
+	data.GuessedSource = info.Source
+	data.GuessedGroup = info.Group
+}
+
+// unchanged tells whether the file on disk is the same as the one
+// in the library, based on its size and modification time
+func unchanged(file *library.VideoFile, info os.FileInfo) bool {
+	return file.OsdbHash != 0 &&
+		file.Size == uint64(info.Size()) &&
+		file.ModTime.Unix() == info.ModTime().Unix()
+}
+
+// complete tells whether all import steps of the file and its show have
+// been done during previous imports, so there's nothing to resume
+func (c *Context) complete(file *library.VideoFile) bool {
+	if !file.ImportStatus.Done(library.FileSteps...) {
+		return false
+	}
+
+	show, err := c.Library.GetShowByFile(file)
+	if err != nil || show == nil {
+		return false
+	}
+
+	return show.ImportStatus.Done(library.ShowSteps...)
+}
+
+// absolute returns the absolute path of a path in the library
+func (c *Context) absolute(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
 	}
-	return uint64(fi.Size()), nil
+	return filepath.Join(c.Config.FileRoot, filename)
 }
 
 func relative(root string, path string) (string, error) {
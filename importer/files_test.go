@@ -1,19 +1,26 @@
 package importer
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/types"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestFileInfo(t *testing.T) {
-	context := testContext(t)
+	c := testContext(t)
 
 	filenames := make(chan string, 5)
 	files := make(chan *library.VideoFile, 5)
 
-	go context.FileInfo(filenames, files)
+	go c.FileInfo(context.Background(), filenames, files)
 
 	filenames <- "fixtures/drop.avi"
 	close(filenames)
@@ -24,21 +31,289 @@ func TestFileInfo(t *testing.T) {
 		t.Errorf("files channel not closed after reading all files")
 	}
 
-	close(context.Stop)
-
 	assert := assert.New(t)
 
-	assert.Nil(dropFile.ImportError)
+	assert.Equal(types.Success, dropFile.Step(library.HashStep).Status)
 	assert.Equal("drop.avi", dropFile.Path)
 	assert.Equal(uint64(675840), dropFile.Size)
 	assert.Equal(uint64(0x450f3f0c98a1f11d), uint64(dropFile.OsdbHash))
+	assert.Equal("drop", dropFile.GuessedTitle)
+}
+
+func TestWalkPaths(t *testing.T) {
+	c := testContext(t)
+	c.Config.Importer.Walker.IgnorePatterns = []string{"*sample*", "extras/*"}
+	c.Config.Importer.Walker.FollowSymlinks = true
+
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	for _, name := range []string{
+		"a.mkv",
+		"notes.txt",
+		"b/c.AVI",
+		"b/c-sample.avi",
+		"b/d/e.mp4",
+		".hidden/f.mkv",
+		"extras/g.mkv",
+	} {
+		path := filepath.Join(tempdir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("foo"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a symlink loop, which should be walked only once
+	err = os.Symlink(filepath.Join(tempdir, "b"), filepath.Join(tempdir, "b/d/loop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filenames := make(chan string, 10)
+	go c.WalkPaths(context.Background(), []string{tempdir, "fixtures/drop.avi"}, filenames)
+
+	var found []string
+	for filename := range filenames {
+		found = append(found, strings.TrimPrefix(filename, tempdir+"/"))
+	}
+
+	assert.Equal(t, []string{
+		"a.mkv",
+		"b/c.AVI",
+		"b/d/e.mp4",
+		"fixtures/drop.avi",
+	}, found)
+}
+
+func TestFileInfoIncremental(t *testing.T) {
+	c := testContext(t)
+
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "drop.avi")
+	data, err := ioutil.ReadFile("fixtures/drop.avi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileInfo := func() []*library.VideoFile {
+		filenames := make(chan string, 5)
+		files := make(chan *library.VideoFile, 5)
+		go c.FileInfo(context.Background(), filenames, files)
+
+		filenames <- filename
+		close(filenames)
+
+		var result []*library.VideoFile
+		for file := range files {
+			result = append(result, file)
+		}
+		return result
+	}
+
+	files := fileInfo()
+	if len(files) != 1 {
+		t.Fatalf("new file not processed")
+	}
+
+	show, err := c.Library.GetShowByImdbID(999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	show.Files = files
+	if err := c.Library.Save(show); err != nil {
+		t.Fatal(err)
+	}
+
+	if files := fileInfo(); len(files) != 1 {
+		t.Errorf("file with incomplete steps not processed again")
+	}
+
+	for _, step := range library.FileSteps {
+		files[0].Step(step).Succeed()
+	}
+	show.Step(library.ImdbStep).Succeed()
+	if err := c.Library.Save(show); err != nil {
+		t.Fatal(err)
+	}
+
+	if files := fileInfo(); len(files) != 0 {
+		t.Errorf("unchanged identified file processed again")
+	}
+
+	show.Step(library.ImdbStep).Errorf("some error")
+	if err := c.Library.Save(show); err != nil {
+		t.Fatal(err)
+	}
+	if files := fileInfo(); len(files) != 1 {
+		t.Errorf("file whose show has failed not processed again")
+	} else {
+		assert.True(t, files[0].ImportStatus.Done(library.FileSteps...))
+	}
+	show.Step(library.ImdbStep).Succeed()
+	if err := c.Library.Save(show); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Refresh = true
+	if files := fileInfo(); len(files) != 1 {
+		t.Errorf("file not processed again with refresh")
+	}
+	c.Refresh = false
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+	files = fileInfo()
+	if len(files) != 1 {
+		t.Fatalf("changed file not processed again")
+	}
+	assert.Equal(t, later.Unix(), files[0].ModTime.Unix())
+	assert.Equal(t, types.Success, files[0].Step(library.HashStep).Status)
+	assert.Equal(t, types.Incomplete, files[0].Step(library.OsdbStep).Status)
 
-	/* TODO
-	assert.Equal(256, dropFile.ResolutionX)
-	assert.Equal(240, dropFile.ResolutionY)
-	assert.Equal("yuv410p", dropFile.VideoFormat)
-	assert.InDelta(30, dropFile.Framerate)
-	assert.InDelta(887, dropFile.VideoBitrate)
-	assert.Equal(types.Duration(6.07 * float32(time.Second)), dropFile.Duration)
-	*/
+}
+
+func TestFileInfoMoved(t *testing.T) {
+	c := testContext(t)
+
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	data, err := ioutil.ReadFile("fixtures/drop.avi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[100] ^= 0xff // make the hash differ from other tests' files
+
+	oldFilename := filepath.Join(tempdir, "a.avi")
+	if err := ioutil.WriteFile(oldFilename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileInfo := func(filename string) *library.VideoFile {
+		filenames := make(chan string, 5)
+		files := make(chan *library.VideoFile, 5)
+		go c.FileInfo(context.Background(), filenames, files)
+
+		filenames <- filename
+		close(filenames)
+
+		file := <-files
+		if _, ok := <-files; ok {
+			t.Errorf("files channel not closed after reading all files")
+		}
+		return file
+	}
+
+	file := fileInfo(oldFilename)
+
+	subtitle, err := c.Library.GetSubtitleByFilename(
+		filepath.Join(tempdir, "a.en.srt"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(subtitle.Filename, []byte("foo"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Subtitles = []*library.Subtitle{subtitle}
+	file.LastPosition = 42
+	if err := c.Library.Save(file); err != nil {
+		t.Fatal(err)
+	}
+
+	newFilename := filepath.Join(tempdir, "b", "b.avi")
+	if err := os.Mkdir(filepath.Join(tempdir, "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(oldFilename, newFilename); err != nil {
+		t.Fatal(err)
+	}
+
+	movedFile := fileInfo(newFilename)
+
+	assert := assert.New(t)
+
+	assert.Equal(file.ID, movedFile.ID)
+	assert.Equal(newFilename, movedFile.Path)
+	assert.Equal(types.Duration(42), movedFile.LastPosition)
+
+	isin, err := c.Library.HasFileWithPath(oldFilename)
+	assert.Nil(err)
+	assert.False(isin)
+
+	if assert.Equal(1, len(movedFile.Subtitles)) {
+		newSubtitle := filepath.Join(tempdir, "b", "b.en.srt")
+		assert.Equal(newSubtitle, movedFile.Subtitles[0].Filename)
+		_, err = os.Stat(newSubtitle)
+		assert.Nil(err)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	c := testContext(t)
+
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "deleted.avi")
+	subtitleFilename := filepath.Join(tempdir, "deleted.en.srt")
+	for _, name := range []string{filename, subtitleFilename} {
+		if err := ioutil.WriteFile(name, []byte("foo"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := c.Library.GetFileByPath(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtitle, err := c.Library.GetSubtitleByFilename(subtitleFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Subtitles = []*library.Subtitle{subtitle}
+	if err := c.Library.Save(file); err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.DeleteFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	_, err = os.Stat(filename)
+	assert.True(os.IsNotExist(err))
+	_, err = os.Stat(subtitleFilename)
+	assert.True(os.IsNotExist(err))
+
+	isin, err := c.Library.HasFileWithPath(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(isin)
 }
@@ -0,0 +1,229 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/release"
+)
+
+// A show guessed from a file name is accepted without asking the user only
+// if its confidence is at least minGuessConfidence, and it's better than
+// the next best match by at least minGuessMargin (so that e.g. a remake
+// isn't chosen over the original just because imdb lists it first)
+const (
+	minGuessConfidence = 0.8
+	minGuessMargin     = 0.1
+)
+
+// guessShowID identifies the file by searching imdb for the title guessed
+// from its name. It returns the imdb id of the best match, if the match
+// is good enough.
+func (c *Context) guessShowID(ctx context.Context, file *library.VideoFile) (int, error) {
+	data := &file.FilenameData
+	if data.GuessedTitle == "" {
+		return 0, fmt.Errorf("unable to guess title from file name")
+	}
+
+	metadata := providerWithContext(ctx, c.Metadata)
+
+	items, err := metadata.Search(guessQuery(data))
+	if err != nil {
+		return 0, fmt.Errorf("unable to search imdb: %s", err)
+	}
+
+	best, confidence, runnerUp := bestMatch(items, data.GuessedTitle, data.GuessedYear)
+	if best == nil || confidence < minGuessConfidence {
+		return 0, fmt.Errorf(
+			"no confident match on imdb for \"%s\"", data.GuessedTitle,
+		)
+	}
+	if confidence-runnerUp < minGuessMargin {
+		return 0, fmt.Errorf(
+			"more than one show on imdb matches \"%s\"", data.GuessedTitle,
+		)
+	}
+
+	if data.GuessedEpisode == 0 {
+		return best.ID, nil
+	}
+
+	return imdbEpisodeID(metadata, best.ID, data.GuessedSeason, data.GuessedEpisode)
+}
+
+// guessQuery makes an imdb search query from the title and year guessed
+// from a file's name
+func guessQuery(data *library.FilenameData) *imdb.SearchQuery {
+	query := &imdb.SearchQuery{
+		Query:    data.GuessedTitle,
+		Year:     data.GuessedYear,
+		Category: imdb.Movie,
+	}
+	if data.GuessedEpisode != 0 {
+		// the year of an episode is rarely the year of the series
+		query.Category = imdb.Series
+		query.Year = 0
+	}
+	return query
+}
+
+// Suggestions searches imdb for shows which the file may be. If text is
+// empty, the title and year guessed from the file's name are used.
+// The results are ordered from most to least likely.
+func (c *Context) Suggestions(ctx context.Context, file *library.VideoFile, text string) ([]*imdb.ShortItem, error) {
+	var (
+		query *imdb.SearchQuery
+		title string
+		year  int
+	)
+
+	if text == "" {
+		if file.GuessedTitle == "" {
+			return nil, fmt.Errorf("unable to guess title from file name")
+		}
+		query = guessQuery(&file.FilenameData)
+		title, year = file.GuessedTitle, file.GuessedYear
+	} else {
+		query = &imdb.SearchQuery{Query: text, Category: imdb.Any}
+		title = text
+	}
+
+	items, err := providerWithContext(ctx, c.Metadata).Search(query)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search imdb: %s", err)
+	}
+
+	sortByConfidence(items, title, year)
+	return items, nil
+}
+
+// SuggestionID returns the imdb id of the show chosen for the file from
+// the suggestions. If the file is an episode and a series was chosen, the
+// episode is looked up in the series.
+func (c *Context) SuggestionID(
+	ctx context.Context,
+	file *library.VideoFile,
+	item *imdb.ShortItem,
+) (int, error) {
+	if file.GuessedEpisode == 0 {
+		return item.ID, nil
+	}
+
+	metadata := providerWithContext(ctx, c.Metadata)
+
+	itemType := item.Type
+	if itemType == imdb.Unknown {
+		data, err := metadata.Item(item.ID)
+		if err != nil {
+			return item.ID, nil
+		}
+		itemType = data.Type
+	}
+	if itemType != imdb.Series {
+		return item.ID, nil
+	}
+
+	return imdbEpisodeID(metadata, item.ID, file.GuessedSeason, file.GuessedEpisode)
+}
+
+// sortByConfidence orders search results by their match confidence with
+// the given title and year, keeping imdb's order for equal confidences
+func sortByConfidence(items []*imdb.ShortItem, title string, year int) {
+	confidences := make(map[*imdb.ShortItem]float64, len(items))
+	for _, item := range items {
+		confidences[item] = matchConfidence(title, year, item.Title, item.Year)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return confidences[items[i]] > confidences[items[j]]
+	})
+}
+
+// bestMatch returns the search result which most closely resembles the
+// given title and year, the confidence of the match, and the confidence
+// of the next best result (a different show)
+func bestMatch(items []*imdb.ShortItem, title string, year int) (*imdb.ShortItem, float64, float64) {
+	var (
+		best           *imdb.ShortItem
+		bestConfidence float64
+		runnerUp       float64
+	)
+
+	for _, item := range items {
+		confidence := matchConfidence(title, year, item.Title, item.Year)
+		switch {
+		case best != nil && item.ID == best.ID:
+			// search results can contain the same show twice
+		case confidence > bestConfidence:
+			best, bestConfidence, runnerUp = item, confidence, bestConfidence
+		case confidence > runnerUp:
+			runnerUp = confidence
+		}
+	}
+
+	return best, bestConfidence, runnerUp
+}
+
+// matchConfidence tells how likely it is (between 0 and 1) that a title
+// and year guessed from a file name refer to a show with the given title
+// and year. Guessed years of 0 are ignored.
+func matchConfidence(guessedTitle string, guessedYear int, title string, year int) float64 {
+	confidence := titleSimilarity(guessedTitle, title)
+
+	if guessedYear != 0 && year != 0 {
+		switch guessedYear - year {
+		case 0:
+		case -1, 1:
+			confidence *= 0.9
+		default:
+			confidence *= 0.5
+		}
+	}
+
+	return confidence
+}
+
+// titleSimilarity compares titles word by word using the Dice coefficient
+func titleSimilarity(a string, b string) float64 {
+	wordsA := release.TitleWords(a)
+	wordsB := release.TitleWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	counts := make(map[string]int)
+	for _, word := range wordsA {
+		counts[word]++
+	}
+
+	common := 0
+	for _, word := range wordsB {
+		if counts[word] > 0 {
+			counts[word]--
+			common++
+		}
+	}
+
+	return 2 * float64(common) / float64(len(wordsA)+len(wordsB))
+}
+
+// imdbEpisodeID finds the id of an episode of the given series
+func imdbEpisodeID(metadata MetadataProvider, seriesID int, season int, episode int) (int, error) {
+	episodes, err := metadata.Episodes(seriesID)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get episodes from imdb: %s", err)
+	}
+
+	for _, data := range episodes {
+		if data.SeasonNumber == season && data.EpisodeNumber == episode {
+			return data.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf(
+		"episode S%02dE%02d not found on imdb", season, episode,
+	)
+}
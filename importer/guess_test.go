@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/library"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchConfidence(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.InDelta(1, matchConfidence("Stalker", 1979, "Stalker", 1979), 0.001)
+	assert.InDelta(1, matchConfidence("stalker", 0, "Stalker", 1979), 0.001)
+	assert.InDelta(0.9, matchConfidence("Stalker", 1980, "Stalker", 1979), 0.001)
+	assert.InDelta(0.5, matchConfidence("Stalker", 2016, "Stalker", 1979), 0.001)
+	assert.InDelta(
+		1, matchConfidence("Law and Order", 0, "Law & Order", 1990), 0.001,
+	)
+	assert.InDelta(
+		1, matchConfidence("Oceans Eleven", 2001, "Ocean's Eleven", 2001), 0.001,
+	)
+	assert.True(
+		matchConfidence("Star Wars", 1977, "Star Wars: The Last Jedi", 2017) <
+			minGuessConfidence,
+	)
+	assert.Equal(0.0, matchConfidence("", 0, "Stalker", 1979))
+}
+
+func TestBestMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	item := func(id int, title string, year int) *imdb.ShortItem {
+		return &imdb.ShortItem{ID: id, Title: title, Year: year, Type: imdb.Movie}
+	}
+
+	items := []*imdb.ShortItem{
+		item(1, "Solaris", 2002),
+		item(2, "Solaris", 1972),
+		item(3, "Solaris: The Making Of", 2002),
+	}
+
+	// without a year, both films are equally likely
+	best, confidence, runnerUp := bestMatch(items, "Solaris", 0)
+	assert.Equal(1, best.ID)
+	assert.InDelta(1, confidence, 0.001)
+	assert.True(confidence-runnerUp < minGuessMargin)
+
+	// the year tells them apart
+	best, confidence, runnerUp = bestMatch(items, "Solaris", 1972)
+	assert.Equal(2, best.ID)
+	assert.InDelta(1, confidence, 0.001)
+	assert.True(confidence-runnerUp >= minGuessMargin)
+
+	// the same show listed twice isn't ambiguous
+	best, confidence, runnerUp = bestMatch(
+		[]*imdb.ShortItem{item(2, "Solaris", 1972), item(2, "Solaris", 1972)}, "Solaris", 0,
+	)
+	assert.Equal(2, best.ID)
+	assert.True(confidence-runnerUp >= minGuessMargin)
+
+	best, _, _ = bestMatch(nil, "Solaris", 0)
+	assert.Nil(best)
+}
+
+func TestGuessShowIDStaticProvider(t *testing.T) {
+	c := testContext(t)
+
+	provider, err := LoadStaticProvider("fixtures/metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Metadata = provider
+
+	assert := assert.New(t)
+
+	file := &library.VideoFile{FilenameData: library.FilenameData{
+		GuessedTitle:   "The Wire",
+		GuessedSeason:  1,
+		GuessedEpisode: 3,
+	}}
+
+	id, err := c.guessShowID(context.Background(), file)
+	assert.Nil(err)
+	assert.Equal(749453, id)
+
+	suggestions, err := c.Suggestions(context.Background(), file, "wire")
+	assert.Nil(err)
+	if assert.Equal(1, len(suggestions)) {
+		assert.Equal(306414, suggestions[0].ID)
+		assert.Equal(imdb.Series, suggestions[0].Type)
+
+		id, err = c.SuggestionID(context.Background(), file, suggestions[0])
+		assert.Nil(err)
+		assert.Equal(749453, id)
+	}
+
+	file.GuessedEpisode = 9
+	_, err = c.guessShowID(context.Background(), file)
+	assert.NotNil(err)
+
+	file.GuessedTitle = "The Sopranos"
+	_, err = c.guessShowID(context.Background(), file)
+	assert.NotNil(err)
+}
@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/DexterLB/mvm/imdb"
+)
+
+// contextGetter is implemented by clients which can cancel requests
+// with a context, such as the imdb page cache
+type contextGetter interface {
+	GetContext(ctx context.Context, url string) (*http.Response, error)
+}
+
+// contextClient makes all requests of a client cancellable with ctx.
+// It implements imdb.HttpGetPoster.
+type contextClient struct {
+	ctx    context.Context
+	client imdb.HttpGetter
+}
+
+// withContext returns a client whose requests are cancelled with ctx
+func withContext(ctx context.Context, client imdb.HttpGetter) *contextClient {
+	if client, ok := client.(*contextClient); ok {
+		return &contextClient{ctx: ctx, client: client.client}
+	}
+	return &contextClient{ctx: ctx, client: client}
+}
+
+// Get performs a GET request
+func (c *contextClient) Get(url string) (*http.Response, error) {
+	if getter, ok := c.client.(contextGetter); ok {
+		return getter.GetContext(c.ctx, url)
+	}
+
+	request, err := http.NewRequestWithContext(c.ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(request)
+}
+
+// Post performs a POST request
+func (c *contextClient) Post(url string, bodyType string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(c.ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", bodyType)
+	return c.do(request)
+}
+
+func (c *contextClient) do(request *http.Request) (*http.Response, error) {
+	switch client := c.client.(type) {
+	case nil:
+		return http.DefaultClient.Do(request)
+	case *http.Client:
+		return client.Do(request)
+	default:
+		// the client doesn't support cancellation, so at least don't
+		// start new requests after the context is done
+		if err := c.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if request.Method == "POST" {
+			if poster, ok := client.(imdb.HttpPoster); ok {
+				return poster.Post(
+					request.URL.String(), request.Header.Get("Content-Type"),
+					request.Body,
+				)
+			}
+		}
+		return client.Get(request.URL.String())
+	}
+}
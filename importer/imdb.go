@@ -1,6 +1,7 @@
 package importer
 
 import (
+	"context"
 	"sync"
 
 	"golang.org/x/text/language"
@@ -12,8 +13,10 @@ import (
 
 // ImdbIdentifier fetches data from imdb for the given shows (they must
 // have an ImdbID). For shows which are episodes, it fetches the respective
-// series.
+// series. Shows and series whose data has already been fetched are
+// skipped, unless Refresh is set.
 func (c *Context) ImdbIdentifier(
+	ctx context.Context,
 	shows <-chan library.ShowWithFile,
 	doneSeries chan<- *library.Series,
 	done chan<- library.ShowWithFile,
@@ -22,6 +25,7 @@ func (c *Context) ImdbIdentifier(
 	defer close(doneSeries)
 
 	cache := makeSeriesCache()
+	metadata := providerWithContext(ctx, c.Metadata)
 
 	maxRequests := c.Config.Importer.Imdb.MaxRequests
 
@@ -30,109 +34,216 @@ func (c *Context) ImdbIdentifier(
 	for i := 0; i < maxRequests; i++ {
 		go func() {
 			defer wg.Done()
-			c.imdbIdentifierWorker(shows, doneSeries, done, cache)
+			c.imdbIdentifierWorker(ctx, metadata, shows, doneSeries, done, cache)
 		}()
 	}
 	wg.Wait()
 }
 
 func (c *Context) imdbIdentifierWorker(
+	ctx context.Context,
+	metadata MetadataProvider,
 	shows <-chan library.ShowWithFile,
 	doneSeries chan<- *library.Series,
 	done chan<- library.ShowWithFile,
 	cache *seriesCache,
 ) {
-	for {
-		select {
-		case show, ok := <-shows:
-			if !ok {
-				return
-			}
+	progress := c.stageProgress(ImdbStage)
+	for show := range shows {
+		progress.AddTotal(1)
 
-			seriesData := c.imdbProcessShow(show.Show)
-
-			var (
-				series    *library.Series
-				newSeries bool
-				err       error
-			)
-
-			if seriesData != nil {
-				id := seriesData.ID()
-				cache.Lock()
-				if series, ok = cache.PrevSeries[id]; !ok {
-					series, err = c.Library.GetSeriesByImdbID(id)
-					if err != nil {
-						show.Show.ImdbError = types.Errorf(
-							"Unable to get series from library: %s", err,
-						)
-						cache.Unlock()
-						continue
-					}
-					cache.PrevSeries[seriesData.ID()] = series
-					newSeries = true
-				}
-				cache.Unlock()
+		if ctx.Err() != nil || !c.shouldRun(show.Show.Step(library.ImdbStep)) {
+			// pass the show on without data, so that it's saved
+			done <- show
+			progress.Add(1)
+			continue
+		}
+
+		seriesID := c.imdbProcessShow(ctx, metadata, show.Show)
 
-				if newSeries {
-					c.imdbProcessSeries(series, seriesData)
+		var (
+			series    *library.Series
+			newSeries bool
+			ok        bool
+			err       error
+		)
+
+		if seriesID != 0 {
+			cache.Lock()
+			if series, ok = cache.PrevSeries[seriesID]; !ok {
+				series, err = c.Library.GetSeriesByImdbID(seriesID)
+				if err != nil {
+					show.Show.Step(library.ImdbStep).Errorf(
+						"Unable to get series from library: %s", err,
+					)
+					cache.Unlock()
+					done <- show
+					progress.Add(1)
+					continue
 				}
+				cache.PrevSeries[seriesID] = series
+				newSeries = true
 
+				// other episodes of the series wait until it's processed
+				series.Lock()
+				cache.Unlock()
+			} else {
+				cache.Unlock()
 				series.Lock()
-				// fixme: what if the show appears twice for some reason?
-				series.Episodes = append(series.Episodes, show.Show)
-				series.Unlock()
 			}
 
-			done <- show
-			if newSeries {
-				doneSeries <- series
+			if newSeries && c.shouldRun(series.Step(library.ImdbStep)) {
+				c.imdbProcessSeries(ctx, metadata, series)
 			}
-		case <-c.Stop:
-			return
+			seriesStatus := series.Step(library.ImdbStep)
+			switch {
+			case seriesStatus.Status == types.Error:
+				show.Show.Step(library.ImdbStep).Errorf(
+					"Error getting series data: %s", seriesStatus.Message,
+				)
+			case !seriesStatus.Done():
+				// the series will be processed along with the episode
+				// during the next import
+				show.Show.Step(library.ImdbStep).Reset()
+			}
+			show.Show.SeriesID = series.ID
+			addEpisode(series, show.Show)
+			series.Unlock()
+		}
+
+		done <- show
+		progress.Add(1)
+		if newSeries {
+			doneSeries <- series
 		}
 	}
 }
 
-func (c *Context) imdbProcessShow(show *library.Show) *imdb.Item {
-	connection := imdb.New(show.ImdbID)
-	defer connection.Free()
-
-	data, err := connection.AllData()
+// imdbProcessShow sets the show's data and returns the imdb id of its
+// series (or 0 if it isn't an episode)
+func (c *Context) imdbProcessShow(ctx context.Context, metadata MetadataProvider, show *library.Show) int {
+	data, err := metadata.Item(show.ImdbID)
+	if ctx.Err() != nil {
+		return 0
+	}
 	if err != nil {
-		show.ImdbError = types.Errorf(
+		show.Step(library.ImdbStep).Errorf(
 			"Error getting data from imdb: %s", err,
 		)
-		return nil
+		return 0
 	}
 
 	imdbSetCommonData(&show.CommonData, data)
 	show.ReleaseDate = data.ReleaseDate
 	show.Tagline = data.Tagline
 
-	show.ImdbError = nil
+	show.Step(library.ImdbStep).Succeed()
 
-	if data.Type == imdb.Episode {
+	if data.Type == imdb.Episode && data.Series != nil {
 		show.Season = data.SeasonNumber
 		show.Episode = data.EpisodeNumber
-		return data.Series
+		return data.Series.ID()
 	}
 
-	return nil
+	return 0
 }
 
-func (c *Context) imdbProcessSeries(series *library.Series, imdbSeries *imdb.Item) {
-	defer imdbSeries.Free()
-
-	data, err := imdbSeries.AllData()
+func (c *Context) imdbProcessSeries(ctx context.Context, metadata MetadataProvider, series *library.Series) {
+	data, err := metadata.Series(series.ImdbID)
+	if ctx.Err() != nil {
+		return
+	}
 	if err != nil {
-		series.ImdbError = types.Errorf(
+		series.Step(library.ImdbStep).Errorf(
 			"Error getting data from imdb: %s", err,
 		)
 		return
 	}
 
 	imdbSetCommonData(&series.CommonData, data)
+	series.Step(library.ImdbStep).Succeed()
+
+	if !c.Config.Importer.Imdb.SkipCatalog {
+		c.imdbProcessCatalog(ctx, metadata, series)
+	}
+}
+
+// imdbProcessCatalog adds all episodes of the series to it. Episodes which
+// aren't in the library yet are added as placeholders without files.
+func (c *Context) imdbProcessCatalog(ctx context.Context, metadata MetadataProvider, series *library.Series) {
+	items, err := metadata.Episodes(series.ImdbID)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		c.Errorf("unable to get episodes of %s: %s", series.Title, err)
+		return
+	}
+
+	known := make(map[int]*library.Show)
+	for _, episode := range series.Episodes {
+		known[episode.ImdbID] = episode
+	}
+
+	for _, item := range items {
+		episode, ok := known[item.ID]
+		if !ok {
+			inLibrary, err := c.Library.HasShowWithImdbID(item.ID)
+			if err != nil {
+				c.Errorf("unable to get episode from library: %s", err)
+				continue
+			}
+			if inLibrary {
+				// the episode is being imported right now,
+				// and will be added to the series when it's processed
+				continue
+			}
+
+			episode, err = c.Library.GetShowByImdbID(item.ID)
+			if err != nil {
+				c.Errorf("unable to get episode from library: %s", err)
+				continue
+			}
+			known[item.ID] = episode
+			series.Episodes = append(series.Episodes, episode)
+		}
+
+		imdbSetCatalogData(episode, item)
+		episode.SeriesID = series.ID
+	}
+}
+
+// imdbSetCatalogData sets the data of an episode which is available
+// from its series' episode list
+func imdbSetCatalogData(episode *library.Show, data *imdb.ItemData) {
+	if data.Title != "" {
+		episode.Title = data.Title
+	}
+	if data.SeasonNumber != 0 || data.EpisodeNumber != 0 {
+		episode.Season = data.SeasonNumber
+		episode.Episode = data.EpisodeNumber
+	}
+	if !data.ReleaseDate.IsZero() {
+		episode.ReleaseDate = data.ReleaseDate
+		if episode.Year == 0 {
+			episode.Year = data.ReleaseDate.Year()
+		}
+	}
+	if episode.Year == 0 {
+		episode.Year = data.Year
+	}
+}
+
+// addEpisode adds the episode to the series, replacing any episode with
+// the same imdb id (such as its placeholder)
+func addEpisode(series *library.Series, episode *library.Show) {
+	for i := range series.Episodes {
+		if series.Episodes[i].ImdbID == episode.ImdbID {
+			series.Episodes[i] = episode
+			return
+		}
+	}
+	series.Episodes = append(series.Episodes, episode)
 }
 
 func imdbSetCommonData(commonData *library.CommonData, data *imdb.ItemData) {
@@ -1,22 +1,24 @@
 package importer
 
 import (
+	"context"
 	"testing"
 
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/types"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestImdbIdentifier(t *testing.T) {
-	context := testContext(t)
+	c := testContext(t)
 
 	shows := make(chan library.ShowWithFile, 5)
 	done := make(chan library.ShowWithFile, 5)
 	doneSeries := make(chan *library.Series, 5)
 
-	go context.ImdbIdentifier(shows, doneSeries, done)
+	go c.ImdbIdentifier(context.Background(), shows, doneSeries, done)
 
-	movie, err := context.Library.GetShowByImdbID(403358)
+	movie, err := c.Library.GetShowByImdbID(403358)
 	if err != nil {
 		t.Errorf("Library error: %s", err)
 	}
@@ -37,8 +39,8 @@ func TestImdbIdentifier(t *testing.T) {
 		t.Errorf("Wrong movie is done")
 	}
 
-	if doneMovie.Show.ImdbError != nil {
-		t.Fatalf("Imdb error: %s", *doneMovie.Show.ImdbError)
+	if status := doneMovie.Show.Step(library.ImdbStep); status.Status != types.Success {
+		t.Fatalf("Imdb error: %s", status.Message)
 	}
 
 	if _, ok := <-doneSeries; ok {
@@ -53,20 +55,20 @@ func TestImdbIdentifier(t *testing.T) {
 }
 
 func TestImdbIdentifierMultipleShows(t *testing.T) {
-	context := testContext(t)
+	c := testContext(t)
 
 	shows := make(chan library.ShowWithFile, 5)
 	done := make(chan library.ShowWithFile, 5)
 	doneSeries := make(chan *library.Series, 5)
 
-	go context.ImdbIdentifier(shows, doneSeries, done)
+	go c.ImdbIdentifier(context.Background(), shows, doneSeries, done)
 
-	movie, err := context.Library.GetShowByImdbID(403358)
+	movie, err := c.Library.GetShowByImdbID(403358)
 	if err != nil {
 		t.Errorf("Library error: %s", err)
 	}
 
-	episode, err := context.Library.GetShowByImdbID(2816136)
+	episode, err := c.Library.GetShowByImdbID(2816136)
 	if err != nil {
 		t.Errorf("Library error: %s", err)
 	}
@@ -132,3 +134,29 @@ func TestImdbIdentifierMultipleShows(t *testing.T) {
 		t.Errorf("Movie not present in identifier output")
 	}
 }
+
+func TestAddEpisode(t *testing.T) {
+	placeholder := &library.Show{}
+	placeholder.ImdbID = 2816136
+	other := &library.Show{}
+	other.ImdbID = 2816138
+
+	series := &library.Series{Episodes: []*library.Show{placeholder, other}}
+
+	episode := &library.Show{}
+	episode.ImdbID = 2816136
+	addEpisode(series, episode)
+
+	assert := assert.New(t)
+
+	assert.Equal(2, len(series.Episodes))
+	assert.True(series.Episodes[0] == episode, "placeholder not replaced")
+	assert.True(series.Episodes[1] == other)
+
+	newEpisode := &library.Show{}
+	newEpisode.ImdbID = 2816140
+	addEpisode(series, newEpisode)
+
+	assert.Equal(3, len(series.Episodes))
+	assert.True(series.Episodes[2] == newEpisode)
+}
@@ -2,47 +2,190 @@ package importer
 
 import (
 	"fmt"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/DexterLB/mvm/config"
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/imdb/httpcache"
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/progress"
+	"github.com/DexterLB/mvm/throttle"
+	"github.com/DexterLB/mvm/types"
 	"github.com/DexterLB/osdb"
 )
 
-// Context contains common data for all importers
+// Context contains common data for all importers. The importers
+// themselves take a context.Context, and when it's cancelled they stop
+// making requests and pass the data they've already got to the next stage,
+// so that it can be saved.
 type Context struct {
-	// Close this channel to stop all import workers
-	Stop chan struct{}
-
 	Library *library.Library
 	Config  *config.Config
 
-	// Channel for unrecoverable pipeline errors, to be read by a human
+	// Channel for unrecoverable pipeline errors, to be read by a human.
+	// It is never closed.
 	Errors chan error
 
 	// Files which have failed to identify correctly during import
 	FilesWithErrors []*library.VideoFile
 
-	osdbClient *osdb.Client
-	osdbLock   sync.Mutex
+	// Refresh makes the importer run all import steps again, even those
+	// which have already succeeded for files which are unchanged on disk
+	Refresh bool
+
+	// Metadata is used to get data about the identified shows
+	Metadata MetadataProvider
+
+	// ImdbClient is used for all requests to imdb.com
+	ImdbClient imdb.HttpGetter
+
+	// Progress receives the progress of each stage (the keys are the
+	// names in Stages). Stages are run by many workers at once, so the
+	// progress objects must be safe for concurrent use.
+	Progress map[string]progress.Progress
+
+	osdbClient  *osdb.Client
+	osdbLock    sync.Mutex
+	osdbLimiter *throttle.Limiter
+
+	speech speechCache
+}
+
+// Stages of the import pipeline whose progress can be reported
+const (
+	WalkStage      = "walk"
+	HashStage      = "hash"
+	OsdbStage      = "osdb"
+	ImdbStage      = "imdb"
+	SubtitlesStage = "subtitles"
+	SaveStage      = "save"
+)
+
+// Stages lists the import stages in the order in which files pass
+// through them
+var Stages = []string{
+	WalkStage, HashStage, OsdbStage, ImdbStage, SubtitlesStage, SaveStage,
 }
 
 // NewContext initializes a context with the given library and config
 func NewContext(library *library.Library, config *config.Config) *Context {
-	context := &Context{
-		Stop:    make(chan struct{}),
+	imdbClient := newImdbClient(&config.Importer.Imdb)
+
+	return &Context{
 		Library: library,
 		Config:  config,
 		Errors:  make(chan error),
+
+		Metadata:   NewMetadataProvider(&config.Importer.Imdb, imdbClient),
+		ImdbClient: imdbClient,
+
+		osdbLimiter: throttle.NewLimiter(
+			throttlePolicy(&config.Importer.Osdb.RateLimit, defaultOsdbRate),
+		),
+	}
+}
+
+// defaultCacheSize is the maximum size of the imdb cache if none is
+// configured
+const defaultCacheSize = 200 * 1024 * 1024
+
+// default maximum rates of requests to imdb and opensubtitles.org
+const (
+	defaultImdbRate = 10
+	defaultOsdbRate = 4
+)
+
+// newImdbClient creates a rate limited client for imdb, which stores the
+// pages in an on-disk cache. If the cache is disabled or can't be
+// created, all requests are made directly.
+func newImdbClient(config *config.Imdb) imdb.HttpGetter {
+	client := &http.Client{
+		Transport: &throttle.Transport{
+			Limiter: throttle.NewLimiter(
+				throttlePolicy(&config.RateLimit, defaultImdbRate),
+			),
+		},
+	}
+
+	if config.Cache.Disabled {
+		return client
+	}
+
+	dir := config.Cache.Directory
+	if dir == "" {
+		var err error
+		dir, err = httpcache.DefaultDirectory()
+		if err != nil {
+			return client
+		}
 	}
-	go func() {
-		<-context.Stop
-		close(context.Errors)
-	}()
-	return context
+
+	cache, err := httpcache.New(dir)
+	if err != nil {
+		return client
+	}
+	cache.Client = client
+
+	cache.MaxSize = defaultCacheSize
+	if config.Cache.MaxSize != 0 {
+		cache.MaxSize = config.Cache.MaxSize * 1024 * 1024
+	}
+	if config.Cache.OldMovieTTL != 0 {
+		cache.Policy.OldMovieTTL = time.Duration(config.Cache.OldMovieTTL)
+	}
+	if config.Cache.RunningSeriesTTL != 0 {
+		cache.Policy.RunningSeriesTTL = time.Duration(config.Cache.RunningSeriesTTL)
+	}
+	if config.Cache.DefaultTTL != 0 {
+		cache.Policy.DefaultTTL = time.Duration(config.Cache.DefaultTTL)
+	}
+
+	return cache
+}
+
+// throttlePolicy makes a policy from the configuration, using the
+// defaults for unset values
+func throttlePolicy(config *config.RateLimit, defaultRate float64) throttle.Policy {
+	policy := throttle.DefaultPolicy
+	policy.RequestsPerSecond = defaultRate
+
+	if config.RequestsPerSecond != 0 {
+		policy.RequestsPerSecond = config.RequestsPerSecond
+	}
+	if config.MaxRetries != 0 {
+		policy.MaxRetries = config.MaxRetries
+	}
+	if config.InitialBackoff != 0 {
+		policy.InitialBackoff = time.Duration(config.InitialBackoff)
+	}
+	if config.MaxBackoff != 0 {
+		policy.MaxBackoff = time.Duration(config.MaxBackoff)
+	}
+	if config.QuotaPause != 0 {
+		policy.QuotaPause = time.Duration(config.QuotaPause)
+	}
+
+	return policy
+}
+
+// stageProgress returns the progress of the given stage, or a progress
+// which does nothing if the stage's progress isn't reported
+func (c *Context) stageProgress(stage string) progress.Progress {
+	if p := c.Progress[stage]; p != nil {
+		return p
+	}
+	return &progress.DummyProgress{}
+}
+
+// shouldRun tells whether an import step needs to be run: when it hasn't
+// been done yet or has failed, or when refreshing
+func (c *Context) shouldRun(status *types.StepStatus) bool {
+	return c.Refresh || !status.Done()
 }
 
 // Errorf sends an error message to the Errors channel
 func (c *Context) Errorf(message string, arguments ...interface{}) {
-	c.Errors <- fmt.Errorf(message, arguments)
+	c.Errors <- fmt.Errorf(message, arguments...)
 }
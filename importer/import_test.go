@@ -1,16 +1,22 @@
 package importer
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/DexterLB/mvm/config"
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/progress"
+	"github.com/DexterLB/mvm/throttle"
 	"github.com/DexterLB/mvm/types"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
 )
 
 func testContext(t *testing.T) *Context {
@@ -19,7 +25,7 @@ func testContext(t *testing.T) *Context {
 		t.Fatalf("unable to initialize library: %s\n", err)
 	}
 
-	context := NewContext(lib, &config.Config{
+	c := NewContext(lib, &config.Config{
 		FileRoot: "./fixtures",
 		Importer: config.Importer{
 			Osdb: config.Osdb{
@@ -29,6 +35,8 @@ func testContext(t *testing.T) *Context {
 			},
 			Imdb: config.Imdb{
 				MaxRequests: 8,
+				SkipCatalog: true,
+				Cache:       config.HttpCache{Disabled: true},
 			},
 			Subtitles: config.Subtitles{
 				Languages: types.MustParseLanguages("en bg"),
@@ -41,12 +49,12 @@ func testContext(t *testing.T) *Context {
 	})
 
 	go func() {
-		for err := range context.Errors {
+		for err := range c.Errors {
 			t.Fatalf("context error: %s\n", err)
 		}
 	}()
 
-	return context
+	return c
 }
 
 func md5File(t *testing.T, filename string) (hash string) {
@@ -64,3 +72,153 @@ func md5File(t *testing.T, filename string) (hash string) {
 	}
 	return hex.EncodeToString(hasher.Sum(nil))
 }
+
+func TestThrottlePolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := throttlePolicy(&config.RateLimit{}, defaultOsdbRate)
+	assert.Equal(float64(defaultOsdbRate), policy.RequestsPerSecond)
+	assert.Equal(throttle.DefaultPolicy.MaxRetries, policy.MaxRetries)
+	assert.Equal(throttle.DefaultPolicy.QuotaPause, policy.QuotaPause)
+
+	policy = throttlePolicy(&config.RateLimit{
+		RequestsPerSecond: 1.5,
+		QuotaPause:        types.Duration(time.Hour),
+	}, defaultOsdbRate)
+	assert.Equal(1.5, policy.RequestsPerSecond)
+	assert.Equal(time.Hour, policy.QuotaPause)
+}
+
+func TestOsdbErrorClass(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(throttle.QuotaExceeded, osdbErrorClass(fmt.Errorf("407 Download limit reached")))
+	assert.Equal(throttle.QuotaExceeded, osdbErrorClass(fmt.Errorf("429 Too many requests")))
+	assert.Equal(throttle.Temporary, osdbErrorClass(fmt.Errorf("503 Service Unavailable")))
+	assert.Equal(throttle.Temporary, osdbErrorClass(fmt.Errorf("506 Server under maintenance")))
+	assert.Equal(throttle.Permanent, osdbErrorClass(fmt.Errorf("401 Unauthorized")))
+}
+
+func TestCancelledImport(t *testing.T) {
+	c := testContext(t)
+	c.Metadata = &errorProvider{err: fmt.Errorf("imdb shouldn't be accessed")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert := assert.New(t)
+
+	files := make(chan *library.VideoFile, 1)
+	probedFiles := make(chan *library.VideoFile, 1)
+	go c.MediaProber(ctx, files, probedFiles)
+
+	files <- &library.VideoFile{Path: "drop.avi"}
+	close(files)
+
+	file := <-probedFiles
+	if _, ok := <-probedFiles; ok {
+		t.Errorf("done channel not closed after reading all files")
+	}
+	assert.Equal(uint(0), file.ResolutionX)
+
+	shows := make(chan library.ShowWithFile, 1)
+	doneSeries := make(chan *library.Series, 1)
+	done := make(chan library.ShowWithFile, 1)
+	go c.ImdbIdentifier(ctx, shows, doneSeries, done)
+
+	movie, err := c.Library.GetShowByImdbID(403358)
+	if err != nil {
+		t.Errorf("Library error: %s", err)
+	}
+
+	shows <- library.ShowWithFile{
+		Show: movie,
+		File: file,
+	}
+	close(shows)
+
+	show := <-done
+	if _, ok := <-done; ok {
+		t.Errorf("done channel not closed after reading all shows")
+	}
+	if _, ok := <-doneSeries; ok {
+		t.Errorf("series channel not closed after reading all shows")
+	}
+	assert.Equal(403358, show.Show.ImdbID)
+	assert.Equal(types.Incomplete, show.Show.Step(library.ImdbStep).Status)
+	assert.Equal("", show.Show.Title)
+
+	c.Import(ctx, []string{"fixtures"})
+	isin, err := c.Library.HasFileWithPath("drop.avi")
+	assert.Nil(err)
+	assert.False(isin)
+}
+
+func TestOsdbForwardFile(t *testing.T) {
+	c := testContext(t)
+
+	show, err := c.Library.GetShowByImdbID(403358)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identified := &library.VideoFile{Path: "identified.avi"}
+	identified.Step(library.OsdbStep).Succeed()
+	show.Files = []*library.VideoFile{identified}
+	if err := c.Library.Save(show); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := &library.VideoFile{Path: "failed.avi"}
+	failed.Step(library.OsdbStep).Errorf("some error")
+
+	shows := make(chan library.ShowWithFile, 2)
+	done := make(chan *library.VideoFile, 2)
+	c.osdbForwardFile(identified, shows, done)
+	c.osdbForwardFile(failed, shows, done)
+	close(shows)
+	close(done)
+
+	assert := assert.New(t)
+
+	forwarded := <-shows
+	assert.Equal(show.ID, forwarded.Show.ID)
+	assert.Equal(identified, forwarded.File)
+	_, ok := <-shows
+	assert.False(ok, "unidentified file forwarded with a show")
+
+	assert.Equal(identified, <-done)
+	assert.Equal(failed, <-done)
+}
+
+func TestStageProgress(t *testing.T) {
+	c := testContext(t)
+
+	counters := make(map[string]*progress.Counter)
+	c.Progress = make(map[string]progress.Progress)
+	for _, stage := range Stages {
+		counters[stage] = progress.NewCounter()
+		c.Progress[stage] = counters[stage]
+	}
+
+	filenames := make(chan string, 5)
+	files := make(chan *library.VideoFile, 5)
+	go c.WalkPaths(context.Background(), []string{"fixtures"}, filenames)
+	go c.FileInfo(context.Background(), filenames, files)
+	c.saveAll(files)
+
+	assert := assert.New(t)
+
+	value, total := counters[WalkStage].Get()
+	assert.Equal(1, value)
+	assert.Equal(0, total)
+
+	for _, stage := range []string{HashStage, SaveStage} {
+		value, total = counters[stage].Get()
+		assert.Equal(1, value, stage)
+		assert.Equal(1, total, stage)
+	}
+
+	value, total = counters[OsdbStage].Get()
+	assert.Equal(0, value)
+	assert.Equal(0, total)
+}
@@ -1,25 +1,37 @@
 package importer
 
 import (
+	"context"
+	"fmt"
 	"sync"
 
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/types"
 	"github.com/eapache/channels"
 )
 
-// Import imports and processes all shows from the given paths into the library
-func (c *Context) Import(paths []string) {
+// Import imports and processes all shows from the given paths into the library.
+// When ctx is cancelled, no more files are read and the files which have
+// already been read are saved with whatever data was found for them.
+// All stages' progress is marked as done when the import finishes.
+func (c *Context) Import(ctx context.Context, paths []string) {
 	bufSize := c.Config.Importer.BufferSize
 
 	filenames := make(chan string, bufSize)
-	go c.WalkPaths(paths, filenames)
+	go c.WalkPaths(ctx, paths, filenames)
 
 	files := make(chan *library.VideoFile, bufSize)
-	go c.FileInfo(filenames, files)
+	go c.FileInfo(ctx, filenames, files)
+
+	probedFiles := make(chan *library.VideoFile, bufSize)
+	go c.MediaProber(ctx, files, probedFiles)
+
+	subtitledFiles := make(chan *library.VideoFile, bufSize)
+	go c.SidecarSubtitles(ctx, probedFiles, subtitledFiles)
 
 	shows := make(chan library.ShowWithFile, bufSize)
 	identifiedFiles := make(chan *library.VideoFile, bufSize)
-	go c.OsdbIdentifier(files, shows, identifiedFiles)
+	go c.OsdbIdentifier(ctx, subtitledFiles, shows, identifiedFiles)
 
 	wg := sync.WaitGroup{}
 	wg.Add(2)
@@ -28,23 +40,46 @@ func (c *Context) Import(paths []string) {
 		wg.Done()
 	}()
 	go func() {
-		c.ProcessShows(shows)
+		c.ProcessShows(ctx, shows)
 		wg.Done()
 	}()
 	wg.Wait()
+
+	for _, stage := range Stages {
+		c.stageProgress(stage).Done()
+	}
+}
+
+// RetryFailed imports again all files in the library for which an import
+// step has failed. Only the failed steps (and the steps which haven't been
+// done yet) are run.
+func (c *Context) RetryFailed(ctx context.Context) error {
+	files, err := c.Library.FailedFiles()
+	if err != nil {
+		return fmt.Errorf("unable to get failed files from library: %s", err)
+	}
+
+	paths := make([]string, len(files))
+	for i := range files {
+		paths[i] = c.absolute(files[i].Path)
+	}
+
+	c.Import(ctx, paths)
+	return nil
 }
 
-// ProcessShows fetches data for each show from online sources
-func (c *Context) ProcessShows(shows <-chan library.ShowWithFile) {
+// ProcessShows fetches data for each show from online sources. When ctx
+// is cancelled, the remaining shows are saved without fetching anything.
+func (c *Context) ProcessShows(ctx context.Context, shows <-chan library.ShowWithFile) {
 	bufSize := c.Config.Importer.BufferSize
 
 	identifiedSeries := make(chan *library.Series, bufSize)
 	identifiedShows := make(chan library.ShowWithFile, bufSize)
-	go c.ImdbIdentifier(shows, identifiedSeries, identifiedShows)
+	go c.ImdbIdentifier(ctx, shows, identifiedSeries, identifiedShows)
 
 	subtitledShows := make(chan library.ShowWithFile, bufSize)
 	subtitles := make(chan *library.Subtitle, bufSize)
-	go c.SubtitleDownloader(identifiedShows, subtitles, subtitledShows)
+	go c.SubtitleDownloader(ctx, identifiedShows, subtitles, subtitledShows)
 
 	wg := sync.WaitGroup{}
 	wg.Add(3)
@@ -65,11 +100,14 @@ func (c *Context) ProcessShows(shows <-chan library.ShowWithFile) {
 
 func (c *Context) saveAll(genericChannel interface{}) {
 	channel := channels.Wrap(genericChannel).Out()
+	progress := c.stageProgress(SaveStage)
 	for item := range channel {
+		progress.AddTotal(1)
 		err := c.Library.Save(item)
 		if err != nil {
 			c.Errors <- err
 		}
+		progress.Add(1)
 	}
 }
 
@@ -81,7 +119,8 @@ func (c *Context) filterFilesWithErrors(files <-chan *library.VideoFile) <-chan
 
 		for file := range files {
 			out <- file
-			if file.ImportError != nil || file.OsdbError != nil {
+			if file.Step(library.HashStep).Status == types.Error ||
+				file.Step(library.OsdbStep).Status == types.Error {
 				c.FilesWithErrors = append(c.FilesWithErrors, file)
 			}
 		}
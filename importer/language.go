@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/DexterLB/mvm/types"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// commonWords are some of the most frequent words in subtitles for each
+// language which detectLanguage can recognise
+var commonWords = map[string][]string{
+	"en": {
+		"the", "you", "and", "to", "is", "it", "that", "what", "of", "this",
+		"have", "are", "not", "we", "your", "he", "me", "my", "was", "know",
+	},
+	"de": {
+		"ich", "du", "nicht", "das", "ist", "und", "der", "sie", "es", "wir",
+		"ein", "zu", "was", "mit", "den", "sich", "auf", "mir", "dich", "bin",
+	},
+	"fr": {
+		"je", "est", "pas", "le", "vous", "la", "tu", "que", "et", "les",
+		"il", "ce", "qui", "ne", "suis", "des", "moi", "une", "c'est", "oui",
+	},
+	"es": {
+		"que", "no", "el", "es", "y", "en", "lo", "por", "qué", "me",
+		"una", "te", "los", "se", "con", "para", "está", "sí", "pero", "yo",
+	},
+	"it": {
+		"che", "non", "di", "il", "è", "la", "un", "per", "mi", "sono",
+		"ti", "ho", "una", "lo", "ma", "cosa", "con", "questo", "sei", "io",
+	},
+	"pt": {
+		"que", "não", "de", "o", "é", "um", "para", "você", "uma", "eu",
+		"com", "se", "do", "da", "isso", "está", "mas", "meu", "ele", "sim",
+	},
+	"nl": {
+		"ik", "je", "het", "de", "een", "niet", "dat", "is", "en", "van",
+		"we", "wat", "zijn", "hij", "er", "maar", "jij", "mij", "ben", "zo",
+	},
+	"bg": {
+		"не", "да", "се", "на", "и", "е", "какво", "това", "ли", "си",
+		"ще", "за", "ти", "ме", "аз", "съм", "той", "но", "ни", "тук",
+	},
+	"ru": {
+		"не", "я", "что", "ты", "в", "и", "на", "это", "он", "с",
+		"мы", "как", "вы", "да", "так", "все", "меня", "тебя", "нет", "был",
+	},
+	"pl": {
+		"nie", "to", "się", "że", "jest", "na", "co", "ja", "tak", "mi",
+		"jak", "ty", "w", "z", "mnie", "ale", "czy", "jestem", "go", "już",
+	},
+}
+
+// minLanguageMatches is the minimum number of common words a text must
+// contain in order for its language to be detected
+const minLanguageMatches = 10
+
+var markupRegex = regexp.MustCompile(`<[^>]*>|\{[^}]*\}`)
+
+// detectLanguage guesses the language of a subtitle's text by counting
+// the common words of each known language in it. Returns false if the
+// language can't be determined.
+func detectLanguage(text string) (types.Language, bool) {
+	words := make(map[string]int)
+	for _, line := range strings.Split(text, "\n") {
+		if strings.Contains(line, "-->") {
+			continue
+		}
+		line = markupRegex.ReplaceAllString(line, " ")
+
+		for _, word := range strings.FieldsFunc(line, func(r rune) bool {
+			return !unicode.IsLetter(r) && r != '\''
+		}) {
+			words[strings.ToLower(word)]++
+		}
+	}
+
+	var best, secondBest int
+	var bestCode string
+	for code, common := range commonWords {
+		score := 0
+		for _, word := range common {
+			score += words[word]
+		}
+
+		switch {
+		case score > best:
+			best, secondBest, bestCode = score, best, code
+		case score > secondBest:
+			secondBest = score
+		}
+	}
+
+	if best < minLanguageMatches || best == secondBest {
+		return types.Language{}, false
+	}
+	return types.MustParseLanguage(bestCode), true
+}
+
+// hearingImpairedTags mark subtitles for the hearing impaired
+var hearingImpairedTags = map[string]bool{
+	"sdh": true,
+	"hi":  true,
+	"cc":  true,
+}
+
+// parseSubtitleTags reads the language and hearing impaired flag from
+// the tags in a subtitle's filename (e.g. "en.sdh" or "2_English").
+// Language names are recognised for the given languages, and for the
+// ones detectLanguage knows. Returns false if there's no language tag.
+func parseSubtitleTags(
+	tags string,
+	languages types.Languages,
+) (
+	lang types.Language,
+	hearingImpaired bool,
+	ok bool,
+) {
+	names := languageNames(languages)
+
+	fields := strings.FieldsFunc(tags, func(r rune) bool {
+		return r == '.' || r == '_' || r == '-' || r == ' ' ||
+			r == '[' || r == ']' || r == '(' || r == ')'
+	})
+
+	for _, field := range fields {
+		field = strings.ToLower(field)
+
+		switch {
+		case hearingImpairedTags[field]:
+			hearingImpaired = true
+		case len(field) == 2 || len(field) == 3:
+			if parsed, found := parseLanguageCode(field); found {
+				lang, ok = parsed, true
+			}
+		default:
+			if parsed, found := names[field]; found {
+				lang, ok = parsed, true
+			}
+		}
+	}
+
+	return
+}
+
+// bibliographicCodes are the ISO 639-2/B codes which differ from the
+// terminology ones, and are often used in filenames
+var bibliographicCodes = map[string]string{
+	"alb": "sq", "arm": "hy", "baq": "eu", "bur": "my", "chi": "zh",
+	"cze": "cs", "dut": "nl", "fre": "fr", "geo": "ka", "ger": "de",
+	"gre": "el", "ice": "is", "mac": "mk", "mao": "mi", "may": "ms",
+	"per": "fa", "rum": "ro", "slo": "sk", "tib": "bo", "wel": "cy",
+}
+
+// parseLanguageCode parses a language code from a filename. Only languages
+// which have 2-letter codes are recognised, since many words in filenames
+// happen to be valid 3-letter codes of obscure languages.
+func parseLanguageCode(code string) (types.Language, bool) {
+	if iso2, ok := bibliographicCodes[code]; ok {
+		code = iso2
+	}
+
+	lang, err := types.ParseLanguage(code)
+	if err != nil || len(lang.ISO2()) != 2 {
+		return types.Language{}, false
+	}
+	return lang, true
+}
+
+// languageNames maps the lowercase English names of the given languages and
+// the languages known to detectLanguage to the languages themselves
+func languageNames(languages types.Languages) map[string]types.Language {
+	all := make(types.Languages, 0, len(languages)+len(commonWords))
+	all = append(all, languages...)
+	for code := range commonWords {
+		all = append(all, types.MustParseLanguage(code))
+	}
+
+	namer := display.English.Languages()
+	names := make(map[string]types.Language)
+	for i := range all {
+		name := namer.Name(language.Make(all[i].ISO2()))
+		if name != "" {
+			names[strings.ToLower(name)] = all[i]
+		}
+	}
+	return names
+}
@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"context"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/probe"
+	"github.com/DexterLB/mvm/types"
+)
+
+// MediaProber reads technical data (resolution, codecs, tracks etc) from
+// the headers of each video file
+func (c *Context) MediaProber(ctx context.Context, files <-chan *library.VideoFile, done chan<- *library.VideoFile) {
+	defer close(done)
+
+	for file := range files {
+		if ctx.Err() == nil && c.shouldRun(file.Step(library.ProbeStep)) {
+			c.probeFile(file)
+		}
+		done <- file
+	}
+}
+
+func (c *Context) probeFile(file *library.VideoFile) {
+	info, err := probe.File(c.absolute(file.Path))
+	if err != nil {
+		file.Step(library.ProbeStep).Errorf("unable to read media info: %s", err)
+		return
+	}
+
+	file.ResolutionX = info.Width
+	file.ResolutionY = info.Height
+	file.VideoFormat = info.VideoCodec
+	file.AudioFormat = info.AudioCodec
+	file.Framerate = info.Framerate
+	file.VideoBitrate = info.VideoBitrate
+	file.AudioBitrate = info.AudioBitrate
+	file.Duration = types.Duration(info.Duration)
+	file.AudioTracks = probeTracks(info.AudioTracks)
+	file.SubtitleTracks = probeTracks(info.SubtitleTracks)
+
+	file.Step(library.ProbeStep).Succeed()
+}
+
+func probeTracks(tracks []probe.Track) types.Tracks {
+	result := make(types.Tracks, len(tracks))
+	for i := range tracks {
+		result[i] = types.Track{
+			Codec:    tracks[i].Codec,
+			Language: tracks[i].Language,
+			Name:     tracks[i].Name,
+		}
+	}
+	return result
+}
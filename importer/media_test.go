@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaProber(t *testing.T) {
+	c := testContext(t)
+
+	files := make(chan *library.VideoFile, 5)
+	done := make(chan *library.VideoFile, 5)
+
+	go c.MediaProber(context.Background(), files, done)
+
+	file := &library.VideoFile{Path: "drop.avi"}
+	files <- file
+	close(files)
+
+	dropFile := <-done
+	if _, ok := <-done; ok {
+		t.Errorf("done channel not closed after reading all files")
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(types.Success, dropFile.Step(library.ProbeStep).Status)
+	assert.Equal(uint(256), dropFile.ResolutionX)
+	assert.Equal(uint(240), dropFile.ResolutionY)
+	assert.Equal("indeo4", dropFile.VideoFormat)
+	assert.InDelta(30, dropFile.Framerate, 0.01)
+	assert.InDelta(887, dropFile.VideoBitrate, 10)
+	assert.InDelta(
+		6.07, time.Duration(dropFile.Duration).Seconds(), 0.01,
+	)
+}
+
+func TestMediaProberResume(t *testing.T) {
+	c := testContext(t)
+
+	probe := func(file *library.VideoFile) *library.VideoFile {
+		files := make(chan *library.VideoFile, 1)
+		done := make(chan *library.VideoFile, 1)
+		go c.MediaProber(context.Background(), files, done)
+
+		files <- file
+		close(files)
+		return <-done
+	}
+
+	file := &library.VideoFile{Path: "drop.avi"}
+	file.Step(library.ProbeStep).Succeed()
+
+	assert := assert.New(t)
+
+	assert.Equal(uint(0), probe(file).ResolutionX, "probed again")
+
+	c.Refresh = true
+	assert.Equal(uint(256), probe(file).ResolutionX, "not probed with refresh")
+}
@@ -0,0 +1,379 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/DexterLB/mvm/config"
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/imdb/dataset"
+	"github.com/DexterLB/mvm/imdb/jsonapi"
+	"github.com/DexterLB/mvm/release"
+)
+
+// MetadataProvider fetches data about movies, series and episodes by
+// their imdb id, and finds them by title
+type MetadataProvider interface {
+	// Search returns the movies and series matching the query, most
+	// relevant first. Their type is Unknown if the provider can't tell.
+	Search(query *imdb.SearchQuery) ([]*imdb.ShortItem, error)
+	// Item returns the data of a movie or an episode
+	Item(id int) (*imdb.ItemData, error)
+	// Series returns the data of a series
+	Series(id int) (*imdb.ItemData, error)
+	// Episodes returns all episodes of a series, ordered by season and
+	// episode number. Only their ids, titles, season and episode numbers
+	// and release dates need to be set.
+	Episodes(seriesID int) ([]*imdb.ItemData, error)
+}
+
+var (
+	_ MetadataProvider = &ScraperProvider{}
+	_ MetadataProvider = &StaticProvider{}
+	_ MetadataProvider = FallbackProvider{}
+	_ MetadataProvider = &jsonapi.Client{}
+	_ MetadataProvider = &dataset.Index{}
+)
+
+// NewMetadataProvider creates the providers listed in the configuration
+// (by default the api client if an api address is set, and the scraper
+// otherwise), which will be tried in order. Providers which can't be
+// created return an error for every request. The scraper uses the given
+// client to access imdb.
+func NewMetadataProvider(config *config.Imdb, client imdb.HttpGetter) MetadataProvider {
+	names := config.Providers
+	if len(names) == 0 && config.APIAddress != "" {
+		names = []string{"api"}
+	}
+	if len(names) == 0 {
+		names = []string{"scraper"}
+	}
+
+	providers := make(FallbackProvider, len(names))
+	for i, name := range names {
+		provider, err := newNamedProvider(name, config, client)
+		if err != nil {
+			provider = &errorProvider{
+				err: fmt.Errorf("unable to create %s provider: %s", name, err),
+			}
+		}
+		providers[i] = provider
+	}
+
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	return providers
+}
+
+func newNamedProvider(name string, config *config.Imdb, client imdb.HttpGetter) (MetadataProvider, error) {
+	switch name {
+	case "scraper":
+		return &ScraperProvider{Client: client}, nil
+	case "api":
+		if config.APIAddress == "" {
+			return nil, fmt.Errorf("no api address configured")
+		}
+		return &jsonapi.Client{
+			HttpClient: http.DefaultClient,
+			Address:    strings.TrimSuffix(config.APIAddress, "/"),
+		}, nil
+	case "dataset":
+		if config.DatasetIndex == "" {
+			return nil, fmt.Errorf("no dataset index configured")
+		}
+		return dataset.Open("sqlite3", config.DatasetIndex)
+	case "static":
+		if config.StaticFile == "" {
+			return nil, fmt.Errorf("no static file configured")
+		}
+		return LoadStaticProvider(config.StaticFile)
+	default:
+		return nil, fmt.Errorf("unknown metadata provider")
+	}
+}
+
+// providerWithContext returns a provider whose requests are cancelled
+// with ctx. Local providers are returned as they are.
+func providerWithContext(ctx context.Context, provider MetadataProvider) MetadataProvider {
+	switch provider := provider.(type) {
+	case *ScraperProvider:
+		return &ScraperProvider{Client: withContext(ctx, provider.Client)}
+	case *jsonapi.Client:
+		return &jsonapi.Client{
+			HttpClient: withContext(ctx, provider.HttpClient),
+			Address:    provider.Address,
+		}
+	case FallbackProvider:
+		providers := make(FallbackProvider, len(provider))
+		for i := range provider {
+			providers[i] = providerWithContext(ctx, provider[i])
+		}
+		return providers
+	default:
+		return provider
+	}
+}
+
+// ScraperProvider gets data by scraping the imdb website
+type ScraperProvider struct {
+	// Client is used for all requests to imdb (nil means http.DefaultClient)
+	Client imdb.HttpGetter
+}
+
+// Search searches imdb for the query
+func (s *ScraperProvider) Search(query *imdb.SearchQuery) ([]*imdb.ShortItem, error) {
+	items, err := imdb.SearchWithClient(query, s.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	itemType := query.Category
+	if itemType == imdb.Any {
+		// finding out would take a request for each result
+		itemType = imdb.Unknown
+	}
+
+	results := make([]*imdb.ShortItem, 0, len(items))
+	for _, item := range items {
+		// the title and year are already known from the search page
+		title, err := item.Title()
+		if err != nil {
+			return nil, err
+		}
+		year, err := item.Year()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &imdb.ShortItem{
+			ID:    item.ID(),
+			Title: title,
+			Type:  itemType,
+			Year:  year,
+		})
+	}
+	return results, nil
+}
+
+// Item returns the data of a movie or an episode
+func (s *ScraperProvider) Item(id int) (*imdb.ItemData, error) {
+	item := imdb.NewWithClient(id, s.Client)
+	defer item.Free()
+
+	return item.AllData()
+}
+
+// Series returns the data of a series
+func (s *ScraperProvider) Series(id int) (*imdb.ItemData, error) {
+	return s.Item(id)
+}
+
+// Episodes returns all episodes of a series, ordered by season and
+// episode number
+func (s *ScraperProvider) Episodes(seriesID int) ([]*imdb.ItemData, error) {
+	series := imdb.NewWithClient(seriesID, s.Client)
+	defer series.Free()
+
+	seasons, err := series.Seasons()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get seasons: %s", err)
+	}
+
+	var episodes []*imdb.ItemData
+	for _, season := range seasons {
+		data, err := season.EpisodesData()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get episodes: %s", err)
+		}
+		episodes = append(episodes, data...)
+	}
+
+	return episodes, nil
+}
+
+// StaticProvider returns predefined data, e.g. for tests
+type StaticProvider struct {
+	// Items contains the data of movies, series and episodes by their id
+	Items map[int]*imdb.ItemData `json:"items"`
+	// SeriesEpisodes contains the episodes of series by the series' id
+	SeriesEpisodes map[int][]*imdb.ItemData `json:"episodes"`
+}
+
+// LoadStaticProvider loads a static provider's data from a JSON file
+func LoadStaticProvider(filename string) (*StaticProvider, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	provider := &StaticProvider{}
+	err = json.NewDecoder(f).Decode(provider)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", filename, err)
+	}
+	return provider, nil
+}
+
+// Search returns the movies and series whose titles contain all words of
+// the query, ordered by id
+func (s *StaticProvider) Search(query *imdb.SearchQuery) ([]*imdb.ShortItem, error) {
+	words := release.TitleWords(query.Query)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty search query")
+	}
+
+	var results []*imdb.ShortItem
+	for _, data := range s.Items {
+		if data.Type != imdb.Movie && data.Type != imdb.Series {
+			continue
+		}
+		if query.Category != imdb.Unknown && query.Category != imdb.Any && data.Type != query.Category {
+			continue
+		}
+		if query.Year != 0 && (data.Year < query.Year-1 || data.Year > query.Year+1) {
+			continue
+		}
+		if !containsWords(release.TitleWords(data.Title), words) {
+			continue
+		}
+		results = append(results, &imdb.ShortItem{
+			ID:    data.ID,
+			Title: data.Title,
+			Type:  data.Type,
+			Year:  data.Year,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+	return results, nil
+}
+
+func containsWords(words []string, wanted []string) bool {
+	present := make(map[string]bool, len(words))
+	for _, word := range words {
+		present[word] = true
+	}
+	for _, word := range wanted {
+		if !present[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// Item returns the data of a movie or an episode
+func (s *StaticProvider) Item(id int) (*imdb.ItemData, error) {
+	data, ok := s.Items[id]
+	if !ok {
+		return nil, fmt.Errorf("no data for tt%07d", id)
+	}
+	return data, nil
+}
+
+// Series returns the data of a series
+func (s *StaticProvider) Series(id int) (*imdb.ItemData, error) {
+	return s.Item(id)
+}
+
+// Episodes returns all episodes of a series
+func (s *StaticProvider) Episodes(seriesID int) ([]*imdb.ItemData, error) {
+	episodes, ok := s.SeriesEpisodes[seriesID]
+	if !ok {
+		return nil, fmt.Errorf("no episodes for tt%07d", seriesID)
+	}
+	return episodes, nil
+}
+
+// FallbackProvider asks each of its providers in order, until one of them
+// succeeds
+type FallbackProvider []MetadataProvider
+
+// Search returns the results of the first provider which can search
+func (f FallbackProvider) Search(query *imdb.SearchQuery) ([]*imdb.ShortItem, error) {
+	var errors []string
+	for _, provider := range f {
+		results, err := provider.Search(query)
+		if err == nil {
+			return results, nil
+		}
+		errors = append(errors, err.Error())
+	}
+	return nil, fallbackError(errors)
+}
+
+// Item returns the data of a movie or an episode
+func (f FallbackProvider) Item(id int) (*imdb.ItemData, error) {
+	var errors []string
+	for _, provider := range f {
+		data, err := provider.Item(id)
+		if err == nil {
+			return data, nil
+		}
+		errors = append(errors, err.Error())
+	}
+	return nil, fallbackError(errors)
+}
+
+// Series returns the data of a series
+func (f FallbackProvider) Series(id int) (*imdb.ItemData, error) {
+	var errors []string
+	for _, provider := range f {
+		data, err := provider.Series(id)
+		if err == nil {
+			return data, nil
+		}
+		errors = append(errors, err.Error())
+	}
+	return nil, fallbackError(errors)
+}
+
+// Episodes returns all episodes of a series
+func (f FallbackProvider) Episodes(seriesID int) ([]*imdb.ItemData, error) {
+	var errors []string
+	for _, provider := range f {
+		episodes, err := provider.Episodes(seriesID)
+		if err == nil {
+			return episodes, nil
+		}
+		errors = append(errors, err.Error())
+	}
+	return nil, fallbackError(errors)
+}
+
+func fallbackError(errors []string) error {
+	if len(errors) == 0 {
+		return fmt.Errorf("no metadata providers")
+	}
+	return fmt.Errorf("%s", strings.Join(errors, "; "))
+}
+
+// errorProvider returns the same error for all requests
+type errorProvider struct {
+	err error
+}
+
+func (e *errorProvider) Search(query *imdb.SearchQuery) ([]*imdb.ShortItem, error) {
+	return nil, e.err
+}
+
+func (e *errorProvider) Item(id int) (*imdb.ItemData, error) {
+	return nil, e.err
+}
+
+func (e *errorProvider) Series(id int) (*imdb.ItemData, error) {
+	return nil, e.err
+}
+
+func (e *errorProvider) Episodes(seriesID int) ([]*imdb.ItemData, error) {
+	return nil, e.err
+}
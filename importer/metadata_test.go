@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DexterLB/mvm/config"
+	"github.com/DexterLB/mvm/imdb"
+	"github.com/DexterLB/mvm/imdb/jsonapi"
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImdbIdentifierStaticProvider(t *testing.T) {
+	c := testContext(t)
+	c.Config.Importer.Imdb.SkipCatalog = false
+
+	provider, err := LoadStaticProvider("fixtures/metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Metadata = provider
+
+	shows := make(chan library.ShowWithFile, 5)
+	done := make(chan library.ShowWithFile, 5)
+	doneSeries := make(chan *library.Series, 5)
+
+	go c.ImdbIdentifier(context.Background(), shows, doneSeries, done)
+
+	episode, err := c.Library.GetShowByImdbID(749451)
+	if err != nil {
+		t.Fatalf("Library error: %s", err)
+	}
+
+	shows <- library.ShowWithFile{Show: episode}
+	close(shows)
+
+	doneEpisode := <-done
+	series := <-doneSeries
+
+	if status := doneEpisode.Show.Step(library.ImdbStep); status.Status != types.Success {
+		t.Fatalf("Imdb error: %s", status.Message)
+	}
+	if series == nil {
+		t.Fatalf("Series is nil")
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal("The Target", episode.Title)
+	assert.Equal(1, episode.Season)
+	assert.Equal(1, episode.Episode)
+	assert.InDelta(8.7, episode.ImdbRating, 0.01)
+	assert.Equal(series.ID, episode.SeriesID)
+
+	assert.Equal(306414, series.ImdbID)
+	assert.Equal("The Wire", series.Title)
+
+	titles := make(map[int]string)
+	for _, show := range series.Episodes {
+		titles[show.ImdbID] = show.Title
+	}
+	assert.Equal(map[int]string{
+		749451: "The Target",
+		749452: "The Detail",
+		749453: "The Buys",
+	}, titles)
+
+	for _, show := range series.Episodes {
+		if show.ImdbID == 749453 {
+			assert.Equal(3, show.Episode)
+			assert.Equal(2002, show.Year)
+			assert.Equal(
+				time.Date(2002, time.June, 16, 0, 0, 0, 0, time.UTC),
+				show.ReleaseDate,
+			)
+		}
+	}
+	assert.True(series.Episodes[0] == episode, "episode not in series")
+}
+
+func TestFallbackProvider(t *testing.T) {
+	provider := FallbackProvider{
+		&errorProvider{err: fmt.Errorf("first failed")},
+		&StaticProvider{Items: map[int]*imdb.ItemData{
+			403358: {ID: 403358, Title: "Nochnoy dozor"},
+		}},
+	}
+
+	assert := assert.New(t)
+
+	data, err := provider.Item(403358)
+	assert.Nil(err)
+	assert.Equal("Nochnoy dozor", data.Title)
+
+	_, err = provider.Series(403358)
+	assert.Nil(err)
+
+	_, err = provider.Item(1)
+	assert.Equal("first failed; no data for tt0000001", err.Error())
+
+	_, err = provider.Episodes(403358)
+	assert.NotNil(err)
+}
+
+func TestNewMetadataProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(&ScraperProvider{}, NewMetadataProvider(&config.Imdb{}, nil))
+
+	provider := NewMetadataProvider(&config.Imdb{
+		Providers:  []string{"static", "scraper"},
+		StaticFile: "fixtures/metadata.json",
+	}, nil)
+	if assert.IsType(FallbackProvider{}, provider) {
+		providers := provider.(FallbackProvider)
+		assert.IsType(&StaticProvider{}, providers[0])
+		assert.IsType(&ScraperProvider{}, providers[1])
+	}
+
+	assert.Equal(&jsonapi.Client{
+		HttpClient: http.DefaultClient,
+		Address:    "http://localhost:8088",
+	}, NewMetadataProvider(&config.Imdb{
+		APIAddress: "http://localhost:8088/",
+	}, nil))
+
+	_, err := NewMetadataProvider(&config.Imdb{
+		Providers: []string{"foo"},
+	}, nil).Item(403358)
+	assert.Equal("unable to create foo provider: unknown metadata provider", err.Error())
+}
@@ -1,17 +1,20 @@
 package importer
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"strconv"
 	"sync"
 
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/throttle"
 	"github.com/DexterLB/mvm/types"
 	"github.com/DexterLB/osdb"
 )
 
 // OsdbClient returns a logged in Osdb client
-func (c *Context) OsdbClient() (*osdb.Client, error) {
+func (c *Context) OsdbClient(ctx context.Context) (*osdb.Client, error) {
 	c.osdbLock.Lock()
 	defer c.osdbLock.Unlock()
 
@@ -25,7 +28,9 @@ func (c *Context) OsdbClient() (*osdb.Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Can't initialize osdb client: %s", err)
 	}
-	err = client.LogIn(config.Username, config.Password, "")
+	err = c.osdbCall(ctx, func() error {
+		return client.LogIn(config.Username, config.Password, "")
+	})
 	if err != nil {
 		return nil, fmt.Errorf("Can't login to osdb: %s", err)
 	}
@@ -34,9 +39,50 @@ func (c *Context) OsdbClient() (*osdb.Client, error) {
 	return client, nil
 }
 
+var (
+	osdbQuotaError     = regexp.MustCompile(`\b(407|429)\b|[Dd]ownload limit`)
+	osdbTemporaryError = regexp.MustCompile(`\b5\d\d\b`)
+)
+
+// osdbCall makes a request to opensubtitles.org, respecting the rate limit
+// and retrying it if it fails temporarily. When our quota is exhausted,
+// all requests wait until it's restored instead of failing.
+//
+// The osdb client can't cancel requests, so when ctx is done, the request
+// is abandoned and its result is ignored.
+func (c *Context) osdbCall(ctx context.Context, request func() error) error {
+	return c.osdbLimiter.Do(ctx.Done(), osdbErrorClass, func() error {
+		result := make(chan error, 1)
+		go func() {
+			result <- request()
+		}()
+
+		select {
+		case err := <-result:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// osdbErrorClass tells which opensubtitles.org errors are worth retrying
+func osdbErrorClass(err error) throttle.Class {
+	message := err.Error()
+	switch {
+	case osdbQuotaError.MatchString(message):
+		return throttle.QuotaExceeded
+	case osdbTemporaryError.MatchString(message):
+		return throttle.Temporary
+	default:
+		return throttle.Classify(err)
+	}
+}
+
 // OsdbIdentifier identifies the video files (matching them to shows) using
 // the opensubtitles.org database
 func (c *Context) OsdbIdentifier(
+	ctx context.Context,
 	files <-chan *library.VideoFile, shows chan<- library.ShowWithFile,
 	done chan<- *library.VideoFile,
 ) {
@@ -45,9 +91,17 @@ func (c *Context) OsdbIdentifier(
 
 	config := &c.Config.Importer.Osdb
 
-	client, err := c.OsdbClient()
+	client, err := c.OsdbClient(ctx)
 	if err != nil {
-		c.Errorf("%s", err)
+		if ctx.Err() == nil {
+			c.Errorf("%s", err)
+		}
+		progress := c.stageProgress(OsdbStage)
+		for file := range files {
+			progress.AddTotal(1)
+			c.osdbForwardFile(file, shows, done)
+			progress.Add(1)
+		}
 		return
 	}
 
@@ -55,7 +109,7 @@ func (c *Context) OsdbIdentifier(
 	wg.Add(config.MaxRequests)
 	for i := 0; i < config.MaxRequests; i++ {
 		go func() {
-			c.osdbIdentifierWorker(files, shows, done, client)
+			c.osdbIdentifierWorker(ctx, files, shows, done, client)
 			wg.Done()
 		}()
 	}
@@ -63,32 +117,45 @@ func (c *Context) OsdbIdentifier(
 }
 
 func (c *Context) osdbIdentifierWorker(
+	ctx context.Context,
 	files <-chan *library.VideoFile, shows chan<- library.ShowWithFile,
 	done chan<- *library.VideoFile,
 	client *osdb.Client,
 ) {
 	var currentFiles []*library.VideoFile
 	maxFiles := c.Config.Importer.Osdb.MaxMoviesPerRequest
+	progress := c.stageProgress(OsdbStage)
 
-	for {
-		select {
-		case file, ok := <-files:
-			if !ok {
-				c.osdbProcessFiles(currentFiles, shows, done, client)
-				return
-			}
-			currentFiles = append(currentFiles, file)
-			if len(currentFiles) >= maxFiles {
-				c.osdbProcessFiles(currentFiles, shows, done, client)
-				currentFiles = currentFiles[0:0]
-			}
-		case <-c.Stop:
-			return
+	for file := range files {
+		progress.AddTotal(1)
+
+		if ctx.Err() != nil {
+			// pass the file on without identifying it, so that it's saved
+			done <- file
+			progress.Add(1)
+			continue
+		}
+
+		if !c.shouldRun(file.Step(library.OsdbStep)) ||
+			file.Step(library.HashStep).Status != types.Success {
+			c.osdbForwardFile(file, shows, done)
+			progress.Add(1)
+			continue
+		}
+
+		currentFiles = append(currentFiles, file)
+		if len(currentFiles) >= maxFiles {
+			c.osdbProcessFiles(ctx, currentFiles, shows, done, client)
+			progress.Add(len(currentFiles))
+			currentFiles = currentFiles[0:0]
 		}
 	}
+	c.osdbProcessFiles(ctx, currentFiles, shows, done, client)
+	progress.Add(len(currentFiles))
 }
 
 func (c *Context) osdbProcessFiles(
+	ctx context.Context,
 	files []*library.VideoFile, shows chan<- library.ShowWithFile,
 	done chan<- *library.VideoFile,
 	client *osdb.Client,
@@ -101,10 +168,20 @@ func (c *Context) osdbProcessFiles(
 	for i := range files {
 		hashes[i] = uint64(files[i].OsdbHash)
 	}
-	movies, err := client.BestMoviesByHashes(hashes)
+	var movies []*osdb.Movie
+	err := c.osdbCall(ctx, func() (err error) {
+		movies, err = client.BestMoviesByHashes(hashes)
+		return err
+	})
+	if ctx.Err() != nil {
+		for i := range files {
+			done <- files[i]
+		}
+		return
+	}
 	if err != nil {
 		for i := range files {
-			files[i].OsdbError = types.Errorf(
+			files[i].Step(library.OsdbStep).Errorf(
 				"Opensubtitles.org error: %s", err,
 			)
 			done <- files[i]
@@ -118,7 +195,17 @@ func (c *Context) osdbProcessFiles(
 			id  int
 		)
 		if movies[i] == nil {
-			err = fmt.Errorf("show not found in opensubtitles.org database")
+			id, err = c.guessShowID(ctx, files[i])
+			if ctx.Err() != nil {
+				done <- files[i]
+				continue
+			}
+			if err != nil {
+				err = fmt.Errorf(
+					"show not found in opensubtitles.org database or by file name: %s",
+					err,
+				)
+			}
 		} else {
 			id, err = strconv.Atoi(movies[i].ID)
 			if err != nil {
@@ -127,20 +214,23 @@ func (c *Context) osdbProcessFiles(
 		}
 
 		if err != nil {
-			files[i].OsdbError = types.Errorf(
+			files[i].Step(library.OsdbStep).Errorf(
 				"can't identify show: %s", err,
 			)
 		} else {
 			show, err := c.Library.GetShowByImdbID(id)
 			if err != nil {
-				files[i].OsdbError = types.Errorf(
+				files[i].Step(library.OsdbStep).Errorf(
 					"Can't find show's imdb ID: %s", err,
 				)
 			} else {
+				files[i].Step(library.OsdbStep).Succeed()
 				// TODO: episode data
 				show.Files = append(show.Files, files[i])
-				show.Title = movies[i].Title
-				show.Year, _ = strconv.Atoi(movies[i].Year) // FIXME: check error
+				if movies[i] != nil {
+					show.Title = movies[i].Title
+					show.Year, _ = strconv.Atoi(movies[i].Year) // FIXME: check error
+				}
 				shows <- library.ShowWithFile{
 					Show: show,
 					File: files[i],
@@ -150,3 +240,28 @@ func (c *Context) osdbProcessFiles(
 		done <- files[i]
 	}
 }
+
+// osdbForwardFile passes on a file without identifying it. If it has
+// already been identified, it's passed along with its show, so that the
+// show's remaining import steps can be resumed.
+func (c *Context) osdbForwardFile(
+	file *library.VideoFile, shows chan<- library.ShowWithFile,
+	done chan<- *library.VideoFile,
+) {
+	if !file.ImportStatus.Done(library.OsdbStep) {
+		done <- file
+		return
+	}
+
+	show, err := c.Library.GetShowByFile(file)
+	if err != nil {
+		c.Errorf("Library error while looking up show: %s", err)
+	} else if show != nil {
+		show.Files = append(show.Files, file)
+		shows <- library.ShowWithFile{
+			Show: show,
+			File: file,
+		}
+	}
+	done <- file
+}
@@ -3,6 +3,7 @@
 package importer
 
 import (
+	"context"
 	"testing"
 
 	"github.com/DexterLB/mvm/library"
@@ -10,15 +11,15 @@ import (
 )
 
 func TestOsdbIdentifier(t *testing.T) {
-	context := testContext(t)
+	c := testContext(t)
 
 	files := make(chan *library.VideoFile, 5)
 	done := make(chan *library.VideoFile, 5)
 	shows := make(chan *library.Show, 5)
 
-	go context.OsdbIdentifier(files, shows, done)
+	go c.OsdbIdentifier(context.Background(), files, shows, done)
 
-	file, err := context.Library.GetFileByPath("foo/bar")
+	file, err := c.Library.GetFileByPath("foo/bar")
 	if err != nil {
 		t.Errorf("Library error: %s", err)
 	}
@@ -57,25 +58,25 @@ func TestOsdbIdentifierMultipleRequests(t *testing.T) {
 }
 
 func testOsdbIdentifierParallel(t *testing.T, maxRequests int, maxPerRequest int) {
-	context := testContext(t)
+	c := testContext(t)
 
-	context.Config.Importer.Osdb.MaxRequests = maxRequests
-	context.Config.Importer.Osdb.MaxMoviesPerRequest = maxPerRequest
+	c.Config.Importer.Osdb.MaxRequests = maxRequests
+	c.Config.Importer.Osdb.MaxMoviesPerRequest = maxPerRequest
 
 	files := make(chan *library.VideoFile, 5)
 	done := make(chan *library.VideoFile, 5)
 	shows := make(chan *library.Show, 5)
 
-	go context.OsdbIdentifier(files, shows, done)
+	go c.OsdbIdentifier(context.Background(), files, shows, done)
 
-	file1, err := context.Library.GetFileByPath("foo/bar")
+	file1, err := c.Library.GetFileByPath("foo/bar")
 	if err != nil {
 		t.Errorf("Library error: %s", err)
 	}
 
 	file1.OsdbHash = 0x09a2c497663259cb
 
-	file2, err := context.Library.GetFileByPath("foo/baz")
+	file2, err := c.Library.GetFileByPath("foo/baz")
 	if err != nil {
 		t.Errorf("Library error: %s", err)
 	}
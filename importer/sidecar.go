@@ -0,0 +1,257 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/subtitles"
+)
+
+// defaultSubtitleExtensions is used when the config doesn't specify any
+var defaultSubtitleExtensions = []string{"ass", "srt", "ssa", "sub", "vtt"}
+
+// defaultSubtitleDirectories is used when the config doesn't specify any
+var defaultSubtitleDirectories = []string{"subs", "subtitles"}
+
+// maxDetectionSize is how much of a subtitle file is read in order
+// to detect its language and encoding
+const maxDetectionSize = 64 * 1024
+
+// SidecarSubtitles finds the subtitle files which already exist next to
+// each video file (e.g. Movie.en.srt, Subs/Movie.srt or Subs/English.srt)
+// and adds them to the file's subtitles. The language of each subtitle is
+// taken from its filename or, failing that, detected from its contents.
+func (c *Context) SidecarSubtitles(ctx context.Context, files <-chan *library.VideoFile, done chan<- *library.VideoFile) {
+	defer close(done)
+
+	finder := newSidecarFinder(c)
+	for file := range files {
+		if ctx.Err() == nil && c.shouldRun(file.Step(library.SidecarStep)) {
+			c.findSidecarSubtitles(finder, file)
+		}
+		done <- file
+	}
+}
+
+func (c *Context) findSidecarSubtitles(finder *sidecarFinder, file *library.VideoFile) {
+	status := file.Step(library.SidecarStep)
+
+	sidecars, err := finder.find(file.Path)
+	if err != nil {
+		status.Errorf("unable to search for subtitle files: %s", err)
+		return
+	}
+
+	// a broken subtitle file doesn't keep the others from being added
+	var errors []string
+	for _, sidecar := range sidecars {
+		if hasSubtitle(file, sidecar.filename) {
+			continue
+		}
+
+		subtitle, err := c.sidecarSubtitle(sidecar)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", sidecar.filename, err))
+			continue
+		}
+		file.Subtitles = append(file.Subtitles, subtitle)
+	}
+
+	if len(errors) > 0 {
+		status.Errorf("unable to add subtitles: %s", strings.Join(errors, "; "))
+		return
+	}
+	status.Succeed()
+}
+
+func (c *Context) sidecarSubtitle(sidecar *sidecarFile) (*library.Subtitle, error) {
+	head, err := readHead(c.absolute(sidecar.filename), maxDetectionSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read subtitle: %s", err)
+	}
+
+	subtitle, err := c.Library.GetSubtitleByFilename(sidecar.filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create subtitle in library: %s", err)
+	}
+
+	language, hearingImpaired, ok := parseSubtitleTags(
+		sidecar.tags, c.Config.Importer.Subtitles.Languages,
+	)
+	encoding := subtitles.DetectEncoding(head, language)
+	if !ok {
+		// a wrong encoding only affects the non-ASCII words, so the
+		// language can usually still be detected
+		text, _ := subtitles.ToUTF8(head, encoding)
+		language, ok = detectLanguage(string(text))
+	}
+
+	if ok {
+		subtitle.Language = language
+	}
+	subtitle.HearingImpaired = hearingImpaired
+	subtitle.Encoding = encoding
+
+	return subtitle, nil
+}
+
+// hasSubtitle tells whether the file already has a subtitle with this filename
+func hasSubtitle(file *library.VideoFile, filename string) bool {
+	for i := range file.Subtitles {
+		if file.Subtitles[i].Filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// readHead reads at most size bytes from the beginning of a file
+func readHead(filename string, size int64) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return ioutil.ReadAll(io.LimitReader(f, size))
+}
+
+// sidecarFile is a subtitle file found next to a video
+type sidecarFile struct {
+	// filename of the subtitle, relative to the library's file root
+	// just like the video's path
+	filename string
+	// tags are the part of the name which isn't the video's name
+	// (e.g. "en.sdh" for Movie.en.sdh.srt)
+	tags string
+}
+
+type sidecarFinder struct {
+	context     *Context
+	extensions  map[string]bool
+	videos      map[string]bool
+	directories map[string]bool
+}
+
+func newSidecarFinder(c *Context) *sidecarFinder {
+	directories := c.Config.Importer.Subtitles.SidecarDirectories
+	if len(directories) == 0 {
+		directories = defaultSubtitleDirectories
+	}
+
+	f := &sidecarFinder{
+		context: c,
+		extensions: extensionSet(
+			c.Config.Importer.Subtitles.SidecarExtensions,
+			defaultSubtitleExtensions,
+		),
+		videos: extensionSet(
+			c.Config.Importer.Walker.VideoExtensions,
+			defaultVideoExtensions,
+		),
+		directories: make(map[string]bool),
+	}
+	for i := range directories {
+		f.directories[strings.ToLower(directories[i])] = true
+	}
+	return f
+}
+
+// find returns the subtitles for the video with this path. Those are
+// the subtitles in the video's directory whose names start with the
+// video's name, and the ones in its subtitle directories whose names start
+// with the video's name, or which are in a directory with the video's name.
+// If the video is the only one in its directory, all subtitles in the
+// subtitle directories belong to it.
+func (f *sidecarFinder) find(path string) ([]*sidecarFile, error) {
+	dir := filepath.Dir(path)
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	entries, err := ioutil.ReadDir(f.context.absolute(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sidecars       []*sidecarFile
+		subtitleDirs   []string
+		numberOfVideos int
+	)
+
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir():
+			if f.directories[strings.ToLower(entry.Name())] {
+				subtitleDirs = append(subtitleDirs, entry.Name())
+			}
+		case hasExtension(entry.Name(), f.videos):
+			numberOfVideos++
+		default:
+			sidecars = f.appendMatching(sidecars, dir, entry, name, false)
+		}
+	}
+
+	for _, subtitleDir := range subtitleDirs {
+		subtitleDir = filepath.Join(dir, subtitleDir)
+		entries, err := ioutil.ReadDir(f.context.absolute(subtitleDir))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() && entry.Name() == name {
+				nameDir := filepath.Join(subtitleDir, entry.Name())
+				nameEntries, err := ioutil.ReadDir(f.context.absolute(nameDir))
+				if err != nil {
+					return nil, err
+				}
+				for _, nameEntry := range nameEntries {
+					sidecars = f.appendMatching(sidecars, nameDir, nameEntry, name, true)
+				}
+				continue
+			}
+
+			sidecars = f.appendMatching(sidecars, subtitleDir, entry, name, numberOfVideos == 1)
+		}
+	}
+
+	return sidecars, nil
+}
+
+// appendMatching appends the entry to sidecars if it's a subtitle file
+// whose name starts with the video's name. If any is true, subtitles with
+// other names are appended too.
+func (f *sidecarFinder) appendMatching(
+	sidecars []*sidecarFile,
+	dir string,
+	entry os.FileInfo,
+	name string,
+	any bool,
+) []*sidecarFile {
+	if entry.IsDir() || !hasExtension(entry.Name(), f.extensions) {
+		return sidecars
+	}
+
+	tags := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+	switch {
+	case tags == name:
+		tags = ""
+	case strings.HasPrefix(tags, name+"."):
+		tags = strings.TrimPrefix(tags, name+".")
+	case !any:
+		return sidecars
+	}
+
+	return append(sidecars, &sidecarFile{
+		filename: filepath.Join(dir, entry.Name()),
+		tags:     tags,
+	})
+}
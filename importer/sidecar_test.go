@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const germanSubtitle = `1
+00:00:01,000 --> 00:00:03,000
+Ich weiß nicht, was du willst.
+
+2
+00:00:04,000 --> 00:00:06,000
+<i>Das ist nicht mein Problem, und es ist mir egal.</i>
+
+3
+00:00:07,000 --> 00:00:09,000
+Wir sind zu spät. Ich bin mit dir, wenn du mich brauchst.
+`
+
+func TestSidecarSubtitles(t *testing.T) {
+	c := testContext(t)
+
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+	c.Config.FileRoot = tempdir
+
+	bulgarian, err := charmap.Windows1251.NewEncoder().String(
+		"Какво правиш тук? Не знам какво да ти кажа.\n",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string]string{
+		"movie/Movie.mkv":                   "",
+		"movie/Movie.en.srt":                "",
+		"movie/Movie.eng.sdh.ass":           "",
+		"movie/Movie.txt":                   "",
+		"movie/Other.srt":                   "",
+		"movie/Subs/2_Bulgarian.srt":        "",
+		"movie/Subs/Movie.vtt":              germanSubtitle,
+		"show/S01E01.mkv":                   "",
+		"show/S01E02.mkv":                   "",
+		"show/S01E01.srt":                   "",
+		"show/S01E01.bulgarian.srt":         bulgarian,
+		"show/subtitles/S01E01/Spanish.srt": "",
+		"show/subtitles/S01E01.de.sub":      "",
+		"show/subtitles/S01E02.fr.sub":      "",
+		"show/subtitles/English.srt":        "",
+	} {
+		path := filepath.Join(tempdir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// unreadable, but the other subtitles are still added
+	err = os.Symlink(filepath.Join(tempdir, "missing.srt"), filepath.Join(tempdir, "movie/Movie.broken.srt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(chan *library.VideoFile, 5)
+	done := make(chan *library.VideoFile, 5)
+
+	go c.SidecarSubtitles(context.Background(), files, done)
+
+	existing, err := c.Library.GetSubtitleByFilename("movie/Movie.en.srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing.Language = types.MustParseLanguage("en")
+	movie := &library.VideoFile{
+		Path:      "movie/Movie.mkv",
+		Subtitles: []*library.Subtitle{existing},
+	}
+	episode := &library.VideoFile{Path: "show/S01E01.mkv"}
+
+	files <- movie
+	files <- episode
+	close(files)
+
+	assert := assert.New(t)
+
+	assert.Equal(movie, <-done)
+	assert.Equal(episode, <-done)
+	if _, ok := <-done; ok {
+		t.Errorf("done channel not closed after reading all files")
+	}
+
+	subtitles := func(file *library.VideoFile) []string {
+		var result []string
+		for _, subtitle := range file.Subtitles {
+			result = append(result, strings.Join([]string{
+				subtitle.Filename,
+				subtitle.Language.String(),
+				map[bool]string{true: "hi", false: ""}[subtitle.HearingImpaired],
+			}, " "))
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	assert.Equal(types.Error, movie.Step(library.SidecarStep).Status)
+	assert.Contains(movie.Step(library.SidecarStep).Message, "movie/Movie.broken.srt")
+	assert.Equal([]string{
+		"movie/Movie.en.srt en ",
+		"movie/Movie.eng.sdh.ass en hi",
+		"movie/Subs/2_Bulgarian.srt bg ",
+		"movie/Subs/Movie.vtt de ",
+	}, subtitles(movie))
+
+	assert.Equal(types.Success, episode.Step(library.SidecarStep).Status)
+	assert.Equal([]string{
+		"show/S01E01.bulgarian.srt bg ",
+		"show/S01E01.srt und ",
+		"show/subtitles/S01E01.de.sub de ",
+		"show/subtitles/S01E01/Spanish.srt es ",
+	}, subtitles(episode))
+
+	for _, subtitle := range append(movie.Subtitles, episode.Subtitles...) {
+		if subtitle.Filename == "show/S01E01.bulgarian.srt" {
+			assert.Equal("windows-1251", subtitle.Encoding)
+		} else if subtitle != existing {
+			assert.Equal("utf-8", subtitle.Encoding)
+		}
+	}
+}
+
+func TestParseSubtitleTags(t *testing.T) {
+	assert := assert.New(t)
+
+	languages := types.MustParseLanguages("ja")
+
+	for tags, expected := range map[string]string{
+		"en":           "en",
+		"forced.ger":   "de",
+		"2_English":    "en",
+		"Japanese.SDH": "ja hi",
+		"sdh":          "- hi",
+		"foo.bar":      "-",
+		"":             "-",
+	} {
+		language, hearingImpaired, ok := parseSubtitleTags(tags, languages)
+
+		result := "-"
+		if ok {
+			result = language.String()
+		}
+		if hearingImpaired {
+			result += " hi"
+		}
+		assert.Equal(expected, result, tags)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	assert := assert.New(t)
+
+	language, ok := detectLanguage(germanSubtitle)
+	assert.True(ok)
+	assert.Equal("de", language.String())
+
+	language, ok = detectLanguage(`1
+00:00:01,000 --> 00:00:03,000
+Какво правиш тук? Не знам какво да ти кажа.
+
+2
+00:00:04,000 --> 00:00:06,000
+Аз съм тук, но той не е. Това не е за мен, ще се видим.
+`)
+	assert.True(ok)
+	assert.Equal("bg", language.String())
+
+	_, ok = detectLanguage("1\n00:00:01,000 --> 00:00:03,000\nHello!\n")
+	assert.False(ok)
+}
@@ -1,16 +1,17 @@
 package importer
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/subtitles"
 	"github.com/DexterLB/mvm/types"
 	"github.com/DexterLB/osdb"
 )
@@ -18,6 +19,7 @@ import (
 // SubtitleDownloader downloads subtitles for each file, using information
 // from its associated show.
 func (c *Context) SubtitleDownloader(
+	ctx context.Context,
 	files <-chan library.ShowWithFile,
 	subtitles chan<- *library.Subtitle,
 	done chan<- library.ShowWithFile,
@@ -38,7 +40,7 @@ func (c *Context) SubtitleDownloader(
 		for i := 0; i < maxRequests; i++ {
 			go func() {
 				defer wg.Done()
-				c.subtitleSearcherWorker(files, undownloaded, done, undownloadedCounts)
+				c.subtitleSearcherWorker(ctx, files, undownloaded, done, undownloadedCounts)
 			}()
 		}
 		wg.Wait()
@@ -49,13 +51,14 @@ func (c *Context) SubtitleDownloader(
 	for i := 0; i < maxRequests; i++ {
 		go func() {
 			defer wg.Done()
-			c.subtitleDownloaderWorker(undownloaded, subtitles, done, undownloadedCounts)
+			c.subtitleDownloaderWorker(ctx, undownloaded, subtitles, done, undownloadedCounts)
 		}()
 	}
 	wg.Wait()
 }
 
 func (c *Context) subtitleDownloaderWorker(
+	ctx context.Context,
 	undownloaded <-chan *subtitleInfo,
 	subtitles chan<- *library.Subtitle,
 	done chan<- library.ShowWithFile,
@@ -63,61 +66,61 @@ func (c *Context) subtitleDownloaderWorker(
 ) {
 	var currentSubtitles []*subtitleInfo
 	maxSubtitles := c.Config.Importer.Osdb.MaxSubtitlesPerRequest
-	for {
-		select {
-		case us, ok := <-undownloaded:
-			if !ok {
-				c.downloadSubtitles(currentSubtitles, subtitles, done, undownloadedCounts)
-				return
-			}
 
-			currentSubtitles = append(currentSubtitles, us)
-			if len(currentSubtitles) >= maxSubtitles {
-				c.downloadSubtitles(currentSubtitles, subtitles, done, undownloadedCounts)
-				currentSubtitles = currentSubtitles[0:0]
-			}
-		case <-c.Stop:
-			return
+	for us := range undownloaded {
+		currentSubtitles = append(currentSubtitles, us)
+		if len(currentSubtitles) >= maxSubtitles {
+			c.downloadSubtitles(ctx, currentSubtitles, subtitles, done, undownloadedCounts)
+			currentSubtitles = currentSubtitles[0:0]
 		}
 	}
+	c.downloadSubtitles(ctx, currentSubtitles, subtitles, done, undownloadedCounts)
 }
 
 func (c *Context) subtitleSearcherWorker(
+	ctx context.Context,
 	files <-chan library.ShowWithFile,
 	undownloaded chan<- *subtitleInfo,
 	filesWithNoSubtitles chan<- library.ShowWithFile,
 	undownloadedCounts *subtitleCounts,
 ) {
-	for {
-		select {
-		case file, ok := <-files:
-			if !ok {
-				return
-			}
-
-			subtitles, err := c.searchForSubtitles(file)
-			if err != nil {
-				file.File.SubtitlesError = types.Errorf("%s", err)
+	progress := c.stageProgress(SubtitlesStage)
+	for file := range files {
+		progress.AddTotal(1)
+
+		var subtitles []*osdb.Subtitle
+		status := file.File.Step(library.SubtitlesStep)
+		if ctx.Err() == nil && c.shouldRun(status) {
+			var err error
+			subtitles, err = c.searchForSubtitles(ctx, file)
+			switch {
+			case ctx.Err() != nil:
+				// the search will be done again during the next import
+				subtitles = nil
+			case err != nil:
+				status.Errorf("%s", err)
+			default:
+				status.Succeed()
 			}
+		}
 
-			if len(subtitles) == 0 && undownloadedCounts.Done(file.File.ID) {
-				filesWithNoSubtitles <- file
-			}
+		if len(subtitles) == 0 && undownloadedCounts.Done(file.File.ID) {
+			filesWithNoSubtitles <- file
+			progress.Add(1)
+		}
 
-			for i := range subtitles {
-				undownloadedCounts.Push(file.File.ID)
-				undownloaded <- &subtitleInfo{
-					ShowWithFile: file,
-					Subtitle:     subtitles[i],
-				}
+		for i := range subtitles {
+			undownloadedCounts.Push(file.File.ID)
+			undownloaded <- &subtitleInfo{
+				ShowWithFile: file,
+				Subtitle:     subtitles[i],
 			}
-		case <-c.Stop:
-			return
 		}
 	}
 }
 
 func (c *Context) downloadSubtitles(
+	ctx context.Context,
 	undownloaded []*subtitleInfo,
 	subtitles chan<- *library.Subtitle,
 	done chan<- library.ShowWithFile,
@@ -127,11 +130,21 @@ func (c *Context) downloadSubtitles(
 		return
 	}
 
+	interrupted := false
 	defer func() {
+		progress := c.stageProgress(SubtitlesStage)
 		for i := range undownloaded {
+			if interrupted {
+				undownloaded[i].File.Lock()
+				undownloaded[i].File.Step(library.SubtitlesStep).Reset()
+				undownloaded[i].File.Unlock()
+			}
+
 			undownloadedCounts.Pop(undownloaded[i].File.ID)
 			if undownloadedCounts.Done(undownloaded[i].File.ID) {
+				c.forgetSpeech(undownloaded[i].File)
 				done <- undownloaded[i].ShowWithFile
+				progress.Add(1)
 			}
 		}
 	}()
@@ -141,17 +154,29 @@ func (c *Context) downloadSubtitles(
 		toDownload[i] = *undownloaded[i].Subtitle
 	}
 
+	if ctx.Err() != nil {
+		interrupted = true
+		return
+	}
+
 	var data []osdb.SubtitleFile
 
-	client, err := c.OsdbClient()
+	client, err := c.OsdbClient(ctx)
 	if err == nil {
-		data, err = client.DownloadSubtitles(toDownload)
+		err = c.osdbCall(ctx, func() (err error) {
+			data, err = client.DownloadSubtitles(toDownload)
+			return err
+		})
 	}
 
+	if ctx.Err() != nil {
+		interrupted = true
+		return
+	}
 	if err != nil {
 		for i := range undownloaded {
 			undownloaded[i].File.Lock()
-			undownloaded[i].File.SubtitlesError = types.Errorf(
+			undownloaded[i].File.Step(library.SubtitlesStep).Errorf(
 				"unable to download subtitles: %s", // FIXME: what if there's already another error?
 				err,
 			)
@@ -161,10 +186,10 @@ func (c *Context) downloadSubtitles(
 	}
 
 	for i := range data {
-		subtitle, err := c.saveSubtitle(&data[i], undownloaded[i])
+		subtitle, err := c.saveSubtitle(ctx, &data[i], undownloaded[i])
 		if err != nil {
 			undownloaded[i].File.Lock()
-			undownloaded[i].File.SubtitlesError = types.Errorf(
+			undownloaded[i].File.Step(library.SubtitlesStep).Errorf(
 				"unable to save subtitles: %s",
 				err,
 			)
@@ -176,6 +201,7 @@ func (c *Context) downloadSubtitles(
 }
 
 func (c *Context) saveSubtitle(
+	ctx context.Context,
 	data *osdb.SubtitleFile,
 	info *subtitleInfo,
 ) (
@@ -187,11 +213,46 @@ func (c *Context) saveSubtitle(
 		return nil, fmt.Errorf("unknown subtitle language")
 	}
 
-	score, err := strconv.Atoi(info.Subtitle.SubDownloadsCnt)
+	downloads, err := strconv.Atoi(info.Subtitle.SubDownloadsCnt)
 	if err != nil {
 		return nil, fmt.Errorf("cannot determine subtitle score: %s", err)
 	}
-	score = 99999999 - score
+	score := downloadScore(downloads)
+
+	reader, err := data.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read subtitle data: %s", err)
+	}
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read subtitle data: %s", err)
+	}
+
+	contents, encoding, format, err := c.prepareSubtitle(
+		contents, language, info.Subtitle.SubFormat, info.File.Framerate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var alignment *subtitles.Alignment
+	if c.Config.Importer.Subtitles.Sync {
+		if speech := c.fileSpeech(ctx, info.File); speech != nil {
+			// subtitles which can't be aligned are kept, but come last
+			alignment, _ = c.alignSubtitle(contents, format, info.File.Framerate, speech)
+		}
+	}
+
+	if alignment != nil {
+		score = alignmentScore(alignment, downloads)
+		contents, err = adjustTiming(
+			contents, format, info.File.Framerate, alignment.Factor, alignment.Offset,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to synchronise subtitle: %s", err)
+		}
+	}
 
 	description := &struct {
 		NoExtPath string
@@ -202,7 +263,7 @@ func (c *Context) saveSubtitle(
 		NoExtPath: strings.TrimSuffix(info.File.Path, filepath.Ext(info.File.Path)),
 		Language:  language.ISO2(),
 		Score:     fmt.Sprintf("%08d", score),
-		Format:    info.Subtitle.SubFormat,
+		Format:    format,
 	}
 
 	filename, err := c.Config.Importer.Subtitles.Filename.On(description)
@@ -210,16 +271,37 @@ func (c *Context) saveSubtitle(
 		return nil, fmt.Errorf("unable to determine subtitle filename: %s", err)
 	}
 
-	absoluteFilename := filename
-	if !strings.HasPrefix(filename, "/") {
-		absoluteFilename = path.Join(c.Config.FileRoot, filename)
+	// the file name can change with the score, so a subtitle downloaded
+	// before is found by its hash, to keep its timing corrections
+	subtitle, err := c.Library.GetFileSubtitleByHash(info.File, info.Subtitle.SubHash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find subtitle in library: %s", err)
+	}
+	var oldFilename string
+	if subtitle == nil {
+		subtitle, err = c.Library.GetSubtitleByFilename(filename)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create subtitle in library: %s", err)
+		}
+	} else if subtitle.Filename != filename {
+		oldFilename, subtitle.Filename = subtitle.Filename, filename
 	}
 
-	reader, err := data.Reader()
-	if err != nil {
-		return nil, fmt.Errorf("unable to read subtitle data: %s", err)
+	if alignment != nil {
+		subtitle.TimeFactor = alignment.Factor
+		subtitle.Offset = types.Duration(alignment.Offset)
+	} else if subtitle.Retimed() {
+		// the subtitle is downloaded again, so the timing corrections
+		// made to it before have to be applied again
+		factor, offset := subtitle.Timing()
+		contents, err = adjustTiming(contents, format, info.File.Framerate, factor, offset)
+		if err != nil {
+			return nil, fmt.Errorf("unable to adjust subtitle timing: %s", err)
+		}
 	}
 
+	absoluteFilename := c.absolute(filename)
+
 	f, err := os.Create(absoluteFilename)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open subtitle file for writing: %s", err)
@@ -229,33 +311,99 @@ func (c *Context) saveSubtitle(
 		_ = f.Close()
 	}()
 
-	_, err = io.Copy(f, reader)
+	_, err = f.Write(contents)
 	if err != nil {
 		return nil, fmt.Errorf("unble to write subtitle data: %s", err)
 	}
 
-	subtitle, err := c.Library.GetSubtitleByFilename(filename)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create subtitle in library: %s", err)
+	if oldFilename != "" {
+		err = os.Remove(c.absolute(oldFilename))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to remove previous subtitle file: %s", err)
+		}
 	}
 
 	subtitle.Hash = info.Subtitle.SubHash
 	subtitle.Language = language
 	subtitle.HearingImpaired = (info.Subtitle.SubHearingImpaired == "true")
 	subtitle.Score = score
+	subtitle.Encoding = encoding
 
 	info.File.Lock()
-	info.File.Subtitles = append(info.File.Subtitles, subtitle)
+	replaced := false
+	for i := range info.File.Subtitles {
+		if info.File.Subtitles[i].ID == subtitle.ID {
+			info.File.Subtitles[i] = subtitle
+			replaced = true
+		}
+	}
+	if !replaced {
+		info.File.Subtitles = append(info.File.Subtitles, subtitle)
+	}
 	info.File.Unlock()
 
 	return subtitle, nil
 }
 
+// prepareSubtitle converts a downloaded subtitle to UTF-8 and to the
+// configured format, as needed. It returns the subtitle, the encoding in
+// which it came, and its format.
+func (c *Context) prepareSubtitle(
+	contents []byte,
+	language types.Language,
+	format string,
+	framerate float32,
+) ([]byte, string, string, error) {
+	encoding := subtitles.DetectEncoding(contents, language)
+	target := c.Config.Importer.Subtitles.Format
+
+	// converted subtitles are always in UTF-8, which e.g. WebVTT requires
+	if !c.Config.Importer.Subtitles.KeepOriginalEncoding || target != "" {
+		var err error
+		contents, err = subtitles.ToUTF8(contents, encoding)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	if target != "" {
+		converted, err := convertSubtitle(contents, format, framerate, target)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("unable to convert subtitle to %s: %s", target, err)
+		}
+		contents, format = converted, string(target)
+	}
+
+	return contents, encoding, format, nil
+}
+
+// convertSubtitle converts a downloaded subtitle to the target format.
+// The subtitle's format is detected from its contents, since opensubtitles
+// isn't always right about it. The frame rate of the video is needed for
+// frame-based formats.
+func convertSubtitle(
+	contents []byte,
+	format string,
+	framerate float32,
+	target subtitles.Format,
+) ([]byte, error) {
+	from, err := detectFormat(contents, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == target {
+		return contents, nil
+	}
+	return subtitles.Convert(contents, from, target, float64(framerate))
+}
+
 // searchForSubtitles searches for subtitles for all languages specified
 // in the config, and returns the matched subtitle objects. It might
 // return valid subtitles _and_ an error if some, but not all of the languages
 // fail to execute.
 func (c *Context) searchForSubtitles(
+	ctx context.Context,
 	pair library.ShowWithFile,
 ) (
 	[]*osdb.Subtitle,
@@ -282,7 +430,7 @@ func (c *Context) searchForSubtitles(
 	for i := range languages {
 		go func(errors *[]string, i int) {
 			defer wg.Done()
-			err := c.searchForSubtitlesWithLanguage(pair, languages[i], results)
+			err := c.searchForSubtitlesWithLanguage(ctx, pair, languages[i], results)
 			if err != nil {
 				errorLock.Lock()
 				*errors = append(*errors, fmt.Sprintf("%s", err))
@@ -308,11 +456,12 @@ func (c *Context) searchForSubtitles(
 }
 
 func (c *Context) searchForSubtitlesWithLanguage(
+	ctx context.Context,
 	pair library.ShowWithFile,
 	language types.Language,
 	results chan<- *osdb.Subtitle,
 ) error {
-	client, err := c.OsdbClient()
+	client, err := c.OsdbClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -366,7 +515,11 @@ func (c *Context) searchForSubtitlesWithLanguage(
 	}
 
 	// FIXME: this is even more retarded. Modify the osdb library.
-	monolithicSubtitles, err := client.SearchSubtitles(&params)
+	var monolithicSubtitles osdb.Subtitles
+	err = c.osdbCall(ctx, func() (err error) {
+		monolithicSubtitles, err = client.SearchSubtitles(&params)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -1,25 +1,28 @@
 package importer
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/subtitles"
+	"github.com/DexterLB/mvm/types"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestSubtitleDownloader(t *testing.T) {
-	context := testContext(t)
+	c := testContext(t)
 
 	files := make(chan library.ShowWithFile, 5)
 	done := make(chan library.ShowWithFile, 5)
 	subtitles := make(chan *library.Subtitle, 10)
 
-	go context.SubtitleDownloader(files, subtitles, done)
+	go c.SubtitleDownloader(context.Background(), files, subtitles, done)
 
-	movie, err := context.Library.GetShowByImdbID(76759)
+	movie, err := c.Library.GetShowByImdbID(76759)
 	if err != nil {
 		t.Errorf("Library error: %s", err)
 	}
@@ -32,7 +35,7 @@ func TestSubtitleDownloader(t *testing.T) {
 	}
 	defer os.RemoveAll(tempdir)
 
-	file, err := context.Library.GetFileByPath(
+	file, err := c.Library.GetFileByPath(
 		tempdir + "/Star.Wars.Episode.4.A.New.Hope.1977.1080p.BrRip.x264.BOKUTOX.YIFY.mp4",
 	)
 	if err != nil {
@@ -59,8 +62,8 @@ func TestSubtitleDownloader(t *testing.T) {
 		t.Errorf("Wrong movie is done")
 	}
 
-	if doneFile.File.SubtitlesError != nil {
-		t.Fatalf("Subtitles error: %s", *doneFile.File.SubtitlesError)
+	if status := doneFile.File.Step(library.SubtitlesStep); status.Status != types.Success {
+		t.Fatalf("Subtitles error: %s", status.Message)
 	}
 
 	var allSubtitles []*library.Subtitle
@@ -129,3 +132,55 @@ func TestSubtitleDownloader(t *testing.T) {
 		}
 	}
 }
+
+func TestConvertSubtitle(t *testing.T) {
+	assert := assert.New(t)
+
+	srt := []byte("1\n00:00:01,000 --> 00:00:02,000\n<i>Hello!</i>\n\n")
+
+	converted, err := convertSubtitle(srt, "sub", 25, subtitles.WebVTT)
+	assert.Nil(err)
+	assert.Equal(
+		"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n<i>Hello!</i>\n\n",
+		string(converted),
+	)
+
+	converted, err = convertSubtitle(srt, "srt", 25, subtitles.MicroDVD)
+	assert.Nil(err)
+	assert.Equal("{1}{1}25\n{25}{50}{y:i}Hello!\n", string(converted))
+
+	converted, err = convertSubtitle(srt, "srt", 0, subtitles.SRT)
+	assert.Nil(err)
+	assert.Equal(srt, converted)
+
+	_, err = convertSubtitle(srt, "srt", 0, subtitles.MicroDVD)
+	assert.NotNil(err)
+
+	_, err = convertSubtitle([]byte("foo"), "smi", 25, subtitles.WebVTT)
+	assert.NotNil(err)
+}
+
+func TestPrepareSubtitle(t *testing.T) {
+	c := testContext(t)
+	c.Config.Importer.Subtitles.KeepOriginalEncoding = true
+
+	assert := assert.New(t)
+
+	// "Здравей!" in windows-1251
+	srt := []byte("1\n00:00:01,000 --> 00:00:02,000\n\xc7\xe4\xf0\xe0\xe2\xe5\xe9!\n\n")
+
+	contents, encoding, format, err := c.prepareSubtitle(srt, types.MustParseLanguage("bg"), "srt", 25)
+	assert.Nil(err)
+	assert.Equal(srt, contents)
+	assert.Equal("srt", format)
+
+	c.Config.Importer.Subtitles.Format = subtitles.WebVTT
+	contents, converted, format, err := c.prepareSubtitle(srt, types.MustParseLanguage("bg"), "srt", 25)
+	assert.Nil(err)
+	assert.Equal(encoding, converted)
+	assert.Equal("vtt", format)
+	assert.Equal(
+		"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nЗдравей!\n\n",
+		string(contents),
+	)
+}
@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DexterLB/mvm/audio"
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/subtitles"
+)
+
+// defaultSyncMaxOffset is how far subtitles can be shifted when
+// synchronising them if the config doesn't say
+const defaultSyncMaxOffset = time.Minute
+
+// Subtitles are ordered by their score, lowest first. Synchronised
+// subtitles are scored below alignmentScoreRange: mainly by how well they
+// match the speech, and then by their download count (up to
+// downloadScoreRange). Unsynchronised subtitles are scored from
+// alignmentScoreRange up to maxScore by their download count, so they
+// always come after the synchronised ones.
+const (
+	maxScore            = 99999999
+	alignmentScoreRange = 50000000
+	downloadScoreRange  = 100000
+)
+
+func downloadScore(downloads int) int {
+	if downloads > maxScore-alignmentScoreRange {
+		downloads = maxScore - alignmentScoreRange
+	}
+	return maxScore - downloads
+}
+
+func alignmentScore(alignment *subtitles.Alignment, downloads int) int {
+	if downloads >= downloadScoreRange {
+		downloads = downloadScoreRange - 1
+	}
+	quality := math.Max(0, math.Min(1, alignment.Score))
+	mismatch := int(math.Round((1 - quality) * (alignmentScoreRange/downloadScoreRange - 1)))
+	return mismatch*downloadScoreRange + downloadScoreRange - 1 - downloads
+}
+
+// SyncSubtitle synchronises the file's subtitle to the speech in the given
+// audio (a WAV file, by default the one next to the video), and records
+// the correction in the library
+func (c *Context) SyncSubtitle(
+	ctx context.Context,
+	file *library.VideoFile,
+	subtitle *library.Subtitle,
+	audioFilename string,
+) (*subtitles.Alignment, error) {
+	if audioFilename == "" {
+		audioFilename = c.audioFilename(file)
+	}
+
+	speech, err := detectSpeech(ctx, audioFilename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to detect speech: %s", err)
+	}
+
+	contents, err := ioutil.ReadFile(c.absolute(subtitle.Filename))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read subtitle: %s", err)
+	}
+
+	alignment, err := c.alignSubtitle(contents, filepath.Ext(subtitle.Filename), file.Framerate, speech)
+	if err != nil {
+		return nil, err
+	}
+
+	return alignment, c.adjustSubtitle(file, subtitle, alignment.Factor, alignment.Offset)
+}
+
+// alignSubtitle finds how the subtitle has to be adjusted to match the
+// speech. The format is detected from the contents, falling back to the
+// given one.
+func (c *Context) alignSubtitle(
+	contents []byte,
+	format string,
+	framerate float32,
+	speech []bool,
+) (*subtitles.Alignment, error) {
+	detected, err := detectFormat(contents, format)
+	if err != nil {
+		return nil, err
+	}
+
+	subtitle, err := subtitles.Parse(contents, detected, float64(framerate))
+	if err != nil {
+		return nil, err
+	}
+
+	maxOffset := time.Duration(c.Config.Importer.Subtitles.SyncMaxOffset)
+	if maxOffset == 0 {
+		maxOffset = defaultSyncMaxOffset
+	}
+
+	return subtitles.Align(subtitle, speech, audio.FrameDuration, maxOffset)
+}
+
+// audioFilename returns the name of the WAV file with the video's audio
+func (c *Context) audioFilename(file *library.VideoFile) string {
+	return c.absolute(strings.TrimSuffix(file.Path, filepath.Ext(file.Path)) + ".wav")
+}
+
+func detectSpeech(ctx context.Context, filename string) ([]bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	r, err := audio.NewWAVReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return audio.DetectSpeech(ctx, r)
+}
+
+// speechCache keeps the speech detected in each file's audio while its
+// subtitles are being downloaded, since it takes a while to detect
+type speechCache struct {
+	lock  sync.Mutex
+	files map[uint]*fileSpeech
+}
+
+type fileSpeech struct {
+	once   sync.Once
+	speech []bool
+}
+
+// fileSpeech returns the speech in the file's audio, or nil if there's no
+// usable WAV file with the audio next to it. Subtitles are still saved
+// without it, so errors with the WAV file are only reported.
+func (c *Context) fileSpeech(ctx context.Context, file *library.VideoFile) []bool {
+	c.speech.lock.Lock()
+	if c.speech.files == nil {
+		c.speech.files = make(map[uint]*fileSpeech)
+	}
+	entry, ok := c.speech.files[file.ID]
+	if !ok {
+		entry = &fileSpeech{}
+		c.speech.files[file.ID] = entry
+	}
+	c.speech.lock.Unlock()
+
+	entry.once.Do(func() {
+		filename := c.audioFilename(file)
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return
+		}
+
+		speech, err := detectSpeech(ctx, filename)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.Errorf("unable to detect speech in %s: %s", filename, err)
+			}
+			return
+		}
+		entry.speech = speech
+	})
+	return entry.speech
+}
+
+// forgetSpeech removes the file's speech from the cache
+func (c *Context) forgetSpeech(file *library.VideoFile) {
+	c.speech.lock.Lock()
+	delete(c.speech.files, file.ID)
+	c.speech.lock.Unlock()
+}
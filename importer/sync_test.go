@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DexterLB/mvm/config"
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/subtitles"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSpeechWAV writes a mono WAV file with a tone during each of the
+// given intervals, and silence otherwise
+func writeSpeechWAV(t *testing.T, filename string, length time.Duration, speech [][2]time.Duration) {
+	const sampleRate = 8000
+
+	samples := make([]int16, int(length.Seconds()*sampleRate))
+	for _, interval := range speech {
+		for i := int(interval[0].Seconds() * sampleRate); i < int(interval[1].Seconds()*sampleRate); i++ {
+			samples[i] = int16(10000 * math.Sin(2*math.Pi*800*float64(i)/sampleRate))
+		}
+	}
+
+	wav := &bytes.Buffer{}
+	for _, value := range []interface{}{
+		[]byte("RIFF"), uint32(36 + 2*len(samples)), []byte("WAVEfmt "),
+		uint32(16), uint16(1), uint16(1), uint32(sampleRate), uint32(2 * sampleRate), uint16(2), uint16(16),
+		[]byte("data"), uint32(2 * len(samples)), samples,
+	} {
+		if err := binary.Write(wav, binary.LittleEndian, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filename, wav.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncSubtitle(t *testing.T) {
+	c := testContext(t)
+
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	// lines of different lengths, with the subtitle 2 seconds early
+	var speech [][2]time.Duration
+	srt := &bytes.Buffer{}
+	for i := 0; i < 20; i++ {
+		start := time.Duration(3*i+3) * time.Second
+		end := start + time.Duration(500+100*(i%7))*time.Millisecond
+		speech = append(speech, [2]time.Duration{start, end})
+		fmt.Fprintf(
+			srt, "%d\n00:00:%02d,000 --> 00:00:%02d,%03d\nLine %d\n\n",
+			i+1, (start-2*time.Second)/time.Second,
+			(end-2*time.Second)/time.Second, (end%time.Second)/time.Millisecond, i+1,
+		)
+	}
+
+	writeSpeechWAV(t, filepath.Join(tempdir, "synced.wav"), 70*time.Second, speech)
+	filename := filepath.Join(tempdir, "synced.en.srt")
+	if err := ioutil.WriteFile(filename, srt.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := c.Library.GetFileByPath(filepath.Join(tempdir, "synced.mkv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtitle, err := c.Library.GetSubtitleByFilename(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	alignment, err := c.SyncSubtitle(context.Background(), file, subtitle, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1.0, alignment.Factor)
+	assert.InDelta(2*time.Second, alignment.Offset, float64(20*time.Millisecond))
+	assert.True(alignment.Score > 0.9, "score is %f", alignment.Score)
+
+	synced, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(string(synced), "\n00:00:03,000 --> 00:00:03,500\n")
+
+	_, offset := subtitle.Timing()
+	assert.Equal(alignment.Offset, offset)
+
+	_, err = c.SyncSubtitle(context.Background(), file, subtitle, filepath.Join(tempdir, "missing.wav"))
+	assert.NotNil(err)
+}
+
+func TestFileSpeech(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	c := &Context{
+		Config: &config.Config{FileRoot: tempdir},
+		Errors: make(chan error, 1),
+	}
+	file := &library.VideoFile{Path: "video.mkv"}
+
+	assert := assert.New(t)
+
+	// no audio
+	assert.Nil(c.fileSpeech(context.Background(), file))
+	c.forgetSpeech(file)
+
+	// audio which can't be read is reported, but isn't fatal
+	err = ioutil.WriteFile(filepath.Join(tempdir, "video.wav"), []byte("RIFF\x04\x00\x00\x00WAVE"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(c.fileSpeech(context.Background(), file))
+	assert.NotNil(<-c.Errors)
+	c.forgetSpeech(file)
+
+	writeSpeechWAV(t, filepath.Join(tempdir, "video.wav"), 10*time.Second, [][2]time.Duration{{time.Second, 2 * time.Second}})
+	assert.Equal(1000, len(c.fileSpeech(context.Background(), file)))
+	c.forgetSpeech(file)
+
+	// interruptions aren't reported
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Nil(c.fileSpeech(ctx, file))
+	assert.Equal(0, len(c.Errors))
+}
+
+func TestAlignmentScore(t *testing.T) {
+	assert := assert.New(t)
+
+	good := alignmentScore(&subtitles.Alignment{Score: 0.9}, 10)
+	popular := alignmentScore(&subtitles.Alignment{Score: 0.9}, 1000)
+	bad := alignmentScore(&subtitles.Alignment{Score: 0.2}, 1000000)
+	worst := alignmentScore(&subtitles.Alignment{Score: 0}, 0)
+
+	// alignment quality matters more than downloads
+	assert.True(popular < good)
+	assert.True(good < bad)
+	assert.True(alignmentScore(&subtitles.Alignment{Score: 0.95}, 0) < popular)
+
+	// synchronised subtitles come before all others
+	assert.True(worst < alignmentScoreRange)
+	assert.True(worst < downloadScore(1000000000))
+	assert.Equal(alignmentScoreRange, downloadScore(1000000000))
+	assert.Equal(maxScore, downloadScore(0))
+	assert.True(downloadScore(1000) < downloadScore(10))
+	assert.Equal(0, alignmentScore(&subtitles.Alignment{Score: 1}, 1000000))
+}
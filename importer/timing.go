@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/DexterLB/mvm/subtitles"
+	"github.com/DexterLB/mvm/types"
+)
+
+// ShiftSubtitle moves the cues of the file's subtitle by the offset (which
+// can be negative), and records it in the library
+func (c *Context) ShiftSubtitle(
+	file *library.VideoFile,
+	subtitle *library.Subtitle,
+	offset time.Duration,
+) error {
+	return c.adjustSubtitle(file, subtitle, 1, offset)
+}
+
+// RetimeSubtitle converts the file's subtitle from one frame rate to
+// another (e.g. from a 25 fps release to the file's 23.976 fps), and
+// records the change in the library
+func (c *Context) RetimeSubtitle(
+	file *library.VideoFile,
+	subtitle *library.Subtitle,
+	from float64,
+	to float64,
+) error {
+	if from <= 0 || to <= 0 {
+		return fmt.Errorf("invalid frame rates: %g to %g", from, to)
+	}
+	return c.adjustSubtitle(file, subtitle, from/to, 0)
+}
+
+// adjustSubtitle scales the times in the subtitle's file by the factor and
+// then shifts them by the offset, combining the change with the one already
+// recorded in the library. Adjustments which would move cues before the
+// beginning are refused, since those cues would be lost from the file.
+func (c *Context) adjustSubtitle(
+	file *library.VideoFile,
+	subtitle *library.Subtitle,
+	factor float64,
+	offset time.Duration,
+) error {
+	filename := c.absolute(subtitle.Filename)
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("unable to read subtitle: %s", err)
+	}
+
+	err = checkTiming(contents, filepath.Ext(filename), file.Framerate, factor, offset)
+	if err != nil {
+		return err
+	}
+
+	contents, err = adjustTiming(
+		contents, filepath.Ext(filename), file.Framerate, factor, offset,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to adjust subtitle timing: %s", err)
+	}
+
+	err = ioutil.WriteFile(filename, contents, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write subtitle: %s", err)
+	}
+
+	subtitle.Lock()
+	defer subtitle.Unlock()
+
+	oldFactor, oldOffset := subtitle.Timing()
+	subtitle.TimeFactor = oldFactor * factor
+	subtitle.Offset = types.Duration(
+		time.Duration(float64(oldOffset)*factor).Round(time.Millisecond) + offset,
+	)
+
+	return c.Library.Save(subtitle)
+}
+
+// adjustTiming scales and shifts the times in a subtitle, keeping its
+// format. The format is detected from the contents, falling back to the
+// given one. The frame rate of the video is needed for frame-based formats.
+func adjustTiming(
+	contents []byte,
+	format string,
+	framerate float32,
+	factor float64,
+	offset time.Duration,
+) ([]byte, error) {
+	detected, err := detectFormat(contents, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return subtitles.AdjustTiming(contents, detected, float64(framerate), factor, offset)
+}
+
+// checkTiming returns an error if scaling and shifting the times in a
+// subtitle would move any of its cues before the beginning
+func checkTiming(
+	contents []byte,
+	format string,
+	framerate float32,
+	factor float64,
+	offset time.Duration,
+) error {
+	detected, err := detectFormat(contents, format)
+	if err != nil {
+		return err
+	}
+
+	subtitle, err := subtitles.Parse(contents, detected, float64(framerate))
+	if err != nil {
+		return fmt.Errorf("unable to parse subtitle: %s", err)
+	}
+
+	for _, cue := range subtitle.Cues {
+		if time.Duration(float64(cue.Start)*factor)+offset < 0 {
+			return fmt.Errorf(
+				"the cue at %s would be moved before the beginning", cue.Start,
+			)
+		}
+	}
+	return nil
+}
+
+// detectFormat detects a subtitle's format from its contents, falling
+// back to the given one
+func detectFormat(contents []byte, format string) (subtitles.Format, error) {
+	detected, err := subtitles.DetectFormat(contents)
+	if err != nil {
+		return subtitles.ParseFormat(format)
+	}
+	return detected, nil
+}
@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DexterLB/mvm/library"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShiftSubtitle(t *testing.T) {
+	c := testContext(t)
+
+	tempdir, err := ioutil.TempDir("", "mvm_test")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "shifted.en.srt")
+	err = ioutil.WriteFile(
+		filename, []byte("1\n00:00:01,000 --> 00:00:02,000\nHello!\n\n"), 0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := c.Library.GetFileByPath(filepath.Join(tempdir, "shifted.mkv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Framerate = 25
+	subtitle, err := c.Library.GetSubtitleByFilename(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	assert.Nil(c.ShiftSubtitle(file, subtitle, 1500*time.Millisecond))
+	assert.Nil(c.RetimeSubtitle(file, subtitle, 50, 25))
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("1\n00:00:05,000 --> 00:00:07,000\nHello!\n\n", string(contents))
+
+	saved, err := c.Library.GetSubtitleByFilename(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factor, offset := saved.Timing()
+	assert.Equal(2.0, factor)
+	assert.Equal(3*time.Second, offset)
+	assert.True(saved.Retimed())
+
+	assert.NotNil(c.RetimeSubtitle(file, subtitle, 25, 0))
+
+	// shifting the cue before the beginning would lose it
+	assert.NotNil(c.ShiftSubtitle(file, subtitle, -10*time.Second))
+	contents, err = ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("1\n00:00:05,000 --> 00:00:07,000\nHello!\n\n", string(contents))
+	factor, offset = subtitle.Timing()
+	assert.Equal(3*time.Second, offset)
+
+	missing := &library.Subtitle{Filename: filepath.Join(tempdir, "missing.srt")}
+	assert.NotNil(c.ShiftSubtitle(file, missing, time.Second))
+}
@@ -0,0 +1,39 @@
+package library
+
+import "time"
+
+const (
+	// missingCondition matches episodes which have aired before a given
+	// time but have no files. Specials (season 0) are ignored.
+	missingCondition = "series_id != 0 AND season > 0 AND " +
+		"release_date > ? AND release_date <= ? AND " +
+		"id NOT IN (SELECT show_id FROM video_files)"
+
+	// upcomingCondition matches episodes which air after a given time
+	upcomingCondition = "series_id != 0 AND release_date > ?"
+)
+
+// MissingEpisodes returns all episodes (except specials) which have aired
+// before the given time, but have no files. They are ordered by series,
+// season and episode.
+func (lib *Library) MissingEpisodes(before time.Time) ([]*Show, error) {
+	var episodes []*Show
+	err := lib.db.Where(missingCondition, time.Time{}, before).
+		Order("series_id, season, episode").Find(&episodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+// UpcomingEpisodes returns all episodes which air after the given time,
+// ordered by their air date
+func (lib *Library) UpcomingEpisodes(after time.Time) ([]*Show, error) {
+	var episodes []*Show
+	err := lib.db.Where(upcomingCondition, after).
+		Order("release_date, series_id, season, episode").Find(&episodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
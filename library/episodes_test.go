@@ -0,0 +1,100 @@
+package library
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingAndUpcomingEpisodes(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2016, time.June, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	series, err := lib.GetSeriesByImdbID(944947)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	episodes := []struct {
+		imdbID      int
+		season      int
+		episode     int
+		releaseDate time.Time
+		hasFile     bool
+	}{
+		{4283016, 6, 1, now.Add(-30 * day), true},
+		{4283028, 6, 2, now.Add(-20 * day), false},
+		{4283054, 6, 3, now.Add(-10 * day), false},
+		{4283050, 0, 1, now.Add(-5 * day), false},
+		{4283060, 6, 7, now.Add(2 * day), false},
+		{4283074, 6, 8, now.Add(9 * day), false},
+		{4283088, 6, 9, time.Time{}, false},
+	}
+
+	for _, e := range episodes {
+		episode, err := lib.GetShowByImdbID(e.imdbID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		episode.Season = e.season
+		episode.Episode = e.episode
+		episode.ReleaseDate = e.releaseDate
+
+		if e.hasFile {
+			file, err := lib.GetFileByPath("/got/s06e01.mkv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			episode.Files = []*VideoFile{file}
+		}
+
+		series.Episodes = append(series.Episodes, episode)
+	}
+	if err := lib.Save(series); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(shows []*Show) []int {
+		var result []int
+		for _, show := range shows {
+			result = append(result, show.ImdbID)
+		}
+		return result
+	}
+
+	assert := assert.New(t)
+
+	missing, err := lib.MissingEpisodes(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{4283028, 4283054}, ids(missing))
+
+	upcoming, err := lib.UpcomingEpisodes(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{4283060, 4283074}, ids(upcoming))
+
+	upcoming, err = lib.UpcomingEpisodes(now.Add(5 * day))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{4283074}, ids(upcoming))
+
+	// the query keywords are relative to the current time, so everything
+	// which has aired is missing
+	queried, err := lib.Query("missing season:6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(
+		[]int{4283028, 4283054, 4283060, 4283074}, ids(queried),
+	)
+}
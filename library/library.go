@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/DexterLB/mvm/types"
 	"github.com/jinzhu/gorm"
 )
 
@@ -24,7 +25,10 @@ func New(dbDriver string, arguments ...interface{}) (*Library, error) {
 		db.DB().SetMaxOpenConns(1) // sqlite doesn't like multithreadedness
 	}
 
-	db.AutoMigrate(&Show{}, &EpisodeData{}, &Series{}, &VideoFile{}, &Subtitle{})
+	db.AutoMigrate(
+		&Show{}, &EpisodeData{}, &Series{}, &VideoFile{}, &Subtitle{},
+		&PlaybackEvent{},
+	)
 
 	return &Library{
 		db: db,
@@ -74,6 +78,24 @@ func (lib *Library) GetSeriesByEpisode(episode *Show) (*Series, error) {
 	return series, nil
 }
 
+// GetShowByFile returns the show this file belongs to, or nil if the file
+// hasn't been identified
+func (lib *Library) GetShowByFile(file *VideoFile) (*Show, error) {
+	if file.ShowID == 0 {
+		return nil, nil
+	}
+
+	show := &Show{}
+	err := lib.db.Where("id = ?", file.ShowID).First(show).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return show, nil
+}
+
 // HasShowWithImdbID checks if there exists a show with this id in the library
 func (lib *Library) HasShowWithImdbID(id int) (bool, error) {
 	err := lib.db.Where("imdb_id = ?", id).First(&Show{}).Error
@@ -141,6 +163,71 @@ func (lib *Library) GetFileByPath(path string) (*VideoFile, error) {
 	return file, err
 }
 
+// GetFilesByHash returns all files with the given opensubtitles hash and size
+func (lib *Library) GetFilesByHash(hash types.BigUint64, size uint64) ([]*VideoFile, error) {
+	var files []*VideoFile
+	err := lib.db.Where("osdb_hash = ? AND size = ?", hash, size).Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		err = lib.db.Model(file).Association("Subtitles").Find(&file.Subtitles).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// FailedFiles returns all files for which an import step has failed,
+// either for the file itself, or for its show or the show's series
+func (lib *Library) FailedFiles() ([]*VideoFile, error) {
+	var allSeries []*Series
+	err := lib.db.Find(&allSeries).Error
+	if err != nil {
+		return nil, err
+	}
+	failedSeries := make(map[uint]bool)
+	for _, series := range allSeries {
+		if series.ImportStatus.Failed() {
+			failedSeries[series.ID] = true
+		}
+	}
+
+	var shows []*Show
+	err = lib.db.Where("id IN (SELECT show_id FROM video_files)").Find(&shows).Error
+	if err != nil {
+		return nil, err
+	}
+	failedShows := make(map[uint]bool)
+	for _, show := range shows {
+		if show.ImportStatus.Failed() || failedSeries[show.SeriesID] {
+			failedShows[show.ID] = true
+		}
+	}
+
+	var files []*VideoFile
+	err = lib.db.Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []*VideoFile
+	for _, file := range files {
+		if !file.ImportStatus.Failed() && !failedShows[file.ShowID] {
+			continue
+		}
+
+		err = lib.db.Model(file).Association("Subtitles").Find(&file.Subtitles).Error
+		if err != nil {
+			return nil, err
+		}
+		failed = append(failed, file)
+	}
+	return failed, nil
+}
+
 // GetSubtitleByHash finds the subtitle by its hash, creating it if it doesn't exist
 func (lib *Library) GetSubtitleByHash(hash string) (*Subtitle, error) {
 	subtitle := &Subtitle{}
@@ -163,6 +250,157 @@ func (lib *Library) GetSubtitleByFilename(filename string) (*Subtitle, error) {
 	return subtitle, err
 }
 
+// GetFileSubtitleByHash finds the file's subtitle with the given hash. It
+// returns nil if the file doesn't have such a subtitle.
+func (lib *Library) GetFileSubtitleByHash(file *VideoFile, hash string) (*Subtitle, error) {
+	if file.ID == 0 {
+		return nil, nil
+	}
+
+	subtitle := &Subtitle{}
+	err := lib.db.Where("video_file_id = ? AND hash = ?", file.ID, hash).First(subtitle).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subtitle, nil
+}
+
+// GetSubtitlesByFile returns the subtitles of the file
+func (lib *Library) GetSubtitlesByFile(file *VideoFile) ([]*Subtitle, error) {
+	var subtitles []*Subtitle
+	err := lib.db.Where("video_file_id = ?", file.ID).Order("score").Find(&subtitles).Error
+	return subtitles, err
+}
+
+// RemoveFile removes the file and its subtitles from the library (but
+// not from disk). If the file's show (or the show's series) is left without
+// any files, it is removed as well, unless it is marked as wanted. The
+// playback history of removed shows is removed with them, while that of
+// kept shows stays, without a reference to the file.
+func (lib *Library) RemoveFile(file *VideoFile) error {
+	if file.ID == 0 {
+		return fmt.Errorf("file is not in the library")
+	}
+
+	tx := lib.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	err := removeFile(tx, file)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		return err
+	}
+	file.Subtitles = nil
+	return nil
+}
+
+func removeFile(db *gorm.DB, file *VideoFile) error {
+	err := db.Unscoped().Where("video_file_id = ?", file.ID).Delete(&Subtitle{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Model(&PlaybackEvent{}).Where("video_file_id = ?", file.ID).
+		Update("video_file_id", 0).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Unscoped().Delete(file).Error
+	if err != nil {
+		return err
+	}
+
+	if file.ShowID == 0 {
+		return nil
+	}
+	return removeShowIfUnused(db, file.ShowID)
+}
+
+// removeShowIfUnused removes the show with this id if it has no files and
+// isn't wanted. Episodes are kept as placeholders in their series' catalog
+// until the whole series is unused.
+func removeShowIfUnused(db *gorm.DB, id uint) error {
+	show := &Show{}
+	err := db.Where("id = ?", id).First(show).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if show.SeriesID != 0 {
+		return removeSeriesIfUnused(db, show.SeriesID)
+	}
+
+	var files int
+	err = db.Model(&VideoFile{}).Where("show_id = ?", id).Count(&files).Error
+	if err != nil {
+		return err
+	}
+	if files > 0 || show.Wanted {
+		return nil
+	}
+
+	err = db.Unscoped().Where("show_id = ?", id).Delete(&PlaybackEvent{}).Error
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Delete(show).Error
+}
+
+// removeSeriesIfUnused removes the series with this id, along with all of
+// its episodes, if none of the episodes have files and neither the series
+// nor any of the episodes are wanted
+func removeSeriesIfUnused(db *gorm.DB, id uint) error {
+	series := &Series{}
+	err := db.Where("id = ?", id).First(series).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+	if series.Wanted {
+		return nil
+	}
+
+	var used int
+	err = db.Model(&Show{}).Where(
+		"series_id = ? AND (wanted = ? OR id IN (SELECT show_id FROM video_files))",
+		id, true,
+	).Count(&used).Error
+	if err != nil {
+		return err
+	}
+	if used > 0 {
+		return nil
+	}
+
+	err = db.Unscoped().Where(
+		"show_id IN (SELECT id FROM shows WHERE series_id = ?)", id,
+	).Delete(&PlaybackEvent{}).Error
+	if err != nil {
+		return err
+	}
+	err = db.Unscoped().Where("series_id = ?", id).Delete(&Show{}).Error
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Delete(series).Error
+}
+
 // JustShows extracts just the shows from a ShowWithFile channel
 func JustShows(showsWithFiles <-chan ShowWithFile) chan *Show {
 	shows := make(chan *Show)
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/DexterLB/mvm/types"
+	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/stretchr/testify/assert"
 )
@@ -67,7 +68,7 @@ lorem neque auctor velit, id pretium dui dolor ut ex. Sed quis augue.`,
 
 	assert := assert.New(t)
 
-	series.ImdbError = types.Errorf("some error")
+	series.Step(ImdbStep).Errorf("some error")
 
 	err = lib.Save(series)
 	if err != nil {
@@ -103,7 +104,8 @@ lorem neque auctor velit, id pretium dui dolor ut ex. Sed quis augue.`,
 	assert.InDelta(3.14, series2.ImdbRating, 0.0001)
 	assert.Equal(42, series2.ImdbVotes)
 	assert.Equal(languages, series2.Languages)
-	assert.Equal("some error", *series.ImdbError)
+	assert.Equal(types.Error, series2.Step(ImdbStep).Status)
+	assert.Equal("some error", series2.Step(ImdbStep).Message)
 }
 
 func TestShow(t *testing.T) {
@@ -165,7 +167,7 @@ lorem neque auctor velit, id pretium dui dolor ut ex. Sed quis augue.`,
 
 	movie.Tagline = "foo!"
 
-	movie.ImdbError = types.Errorf("some error")
+	movie.Step(ImdbStep).Errorf("some error")
 
 	err = lib.Save(movie)
 	if err != nil {
@@ -209,7 +211,9 @@ lorem neque auctor velit, id pretium dui dolor ut ex. Sed quis augue.`,
 	)
 
 	assert.Equal("foo!", movie2.Tagline)
-	assert.Equal("some error", *movie2.ImdbError)
+	assert.Equal("some error", movie2.Step(ImdbStep).Message)
+	assert.True(movie2.ImportStatus.Failed())
+	assert.False(movie2.ImportStatus.Done(ImdbStep))
 }
 
 func TestVideoFile(t *testing.T) {
@@ -233,6 +237,7 @@ func TestVideoFile(t *testing.T) {
 	}
 
 	file.Size = 98765432
+	file.ModTime = time.Date(2011, time.March, 4, 12, 30, 0, 0, time.UTC)
 	file.ResolutionX = 1920
 	file.ResolutionY = 1080
 	file.OsdbHash = 123456789
@@ -245,11 +250,19 @@ func TestVideoFile(t *testing.T) {
 
 	file.LastPlayed = time.Date(2012, time.February, 10, 23, 15, 32, 5, time.UTC)
 	file.LastPosition = types.Duration(time.Minute*12 + time.Second*38)
+	file.AudioTracks = types.Tracks{
+		{Codec: "ac3", Language: "ger"},
+		{Codec: "dts", Language: "eng", Name: "commentary"},
+	}
+	file.GuessedTitle = "Show Name"
+	file.GuessedSeason = 2
+	file.GuessedEpisode = 5
+	file.GuessedGroup = "LOL"
 
 	assert := assert.New(t)
 
-	file.ImportError = types.Errorf("some error")
-	file.OsdbError = types.Errorf("some other error")
+	file.Step(HashStep).Succeed()
+	file.Step(OsdbStep).Errorf("some error")
 
 	err = lib.Save(file)
 	if err != nil {
@@ -272,6 +285,10 @@ func TestVideoFile(t *testing.T) {
 
 	assert.Equal("/foo/bar", file2.Path)
 	assert.Equal(uint64(98765432), file2.Size)
+	assert.Equal(
+		time.Date(2011, time.March, 4, 12, 30, 0, 0, time.UTC).Unix(),
+		file2.ModTime.Unix(),
+	)
 	assert.Equal(uint(1920), file2.ResolutionX)
 	assert.Equal(uint(1080), file2.ResolutionY)
 	assert.Equal(uint64(123456789), uint64(file2.OsdbHash))
@@ -288,8 +305,115 @@ func TestVideoFile(t *testing.T) {
 	assert.Equal(
 		time.Duration(file2.LastPosition), time.Minute*12+time.Second*38,
 	)
-	assert.Equal("some error", *file2.ImportError)
-	assert.Equal("some other error", *file2.OsdbError)
+	assert.Equal(types.Tracks{
+		{Codec: "ac3", Language: "ger"},
+		{Codec: "dts", Language: "eng", Name: "commentary"},
+	}, file2.AudioTracks)
+	assert.Equal("Show Name", file2.GuessedTitle)
+	assert.Equal(2, file2.GuessedSeason)
+	assert.Equal(5, file2.GuessedEpisode)
+	assert.Equal("LOL", file2.GuessedGroup)
+	assert.True(file2.ImportStatus.Done(HashStep))
+	assert.False(file2.ImportStatus.Done(HashStep, OsdbStep))
+	assert.Equal(types.Error, file2.Step(OsdbStep).Status)
+	assert.Equal("some error", file2.Step(OsdbStep).Message)
+	assert.Equal(types.Incomplete, file2.Step(ProbeStep).Status)
+}
+
+func TestFilesByHash(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/foo/bar", "/baz/qux", "/qux/quux"} {
+		file, err := lib.GetFileByPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.OsdbHash = 0x450f3f0c98a1f11d
+		file.Size = 42
+		if path == "/qux/quux" {
+			file.Size = 56
+		}
+		err = lib.Save(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := lib.GetFilesByHash(0x450f3f0c98a1f11d, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(2, len(files))
+	assert.Equal("/foo/bar", files[0].Path)
+	assert.Equal("/baz/qux", files[1].Path)
+}
+
+func TestFailedFiles(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]*VideoFile)
+	for _, path := range []string{"/movie", "/failed_movie", "/episode", "/failed_file"} {
+		files[path], err = lib.GetFileByPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[path].Step(OsdbStep).Succeed()
+	}
+	files["/failed_file"].Step(OsdbStep).Errorf("some error")
+	if err := lib.Save(files["/failed_file"]); err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := lib.GetSeriesByImdbID(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series.Step(ImdbStep).Errorf("some error")
+	if err := lib.Save(series); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, path := range map[int]string{
+		101: "/movie", 102: "/failed_movie", 103: "/episode",
+	} {
+		show, err := lib.GetShowByImdbID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		show.Step(ImdbStep).Succeed()
+		if path == "/failed_movie" {
+			show.Step(ImdbStep).Errorf("some error")
+		}
+		if path == "/episode" {
+			show.SeriesID = series.ID
+		}
+		show.Files = []*VideoFile{files[path]}
+		if err := lib.Save(show); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := lib.FailedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, file := range failed {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(
+		t, []string{"/failed_movie", "/episode", "/failed_file"}, paths,
+	)
 }
 
 func TestFileWithSubtitles(t *testing.T) {
@@ -349,6 +473,24 @@ func TestFileWithSubtitles(t *testing.T) {
 	assert.Equal("/baz/qux", file2.Subtitles[1].Filename)
 	assert.Equal("en", file2.Subtitles[0].Language.String())
 	assert.Equal("de", file2.Subtitles[1].Language.String())
+
+	subtitle, err := lib.GetFileSubtitleByHash(file2, "456")
+	assert.Nil(err)
+	if assert.NotNil(subtitle) {
+		assert.Equal(subtitleB.ID, subtitle.ID)
+	}
+
+	subtitle, err = lib.GetFileSubtitleByHash(file2, "789")
+	assert.Nil(err)
+	assert.Nil(subtitle)
+
+	other, err := lib.GetFileByPath("/c/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtitle, err = lib.GetFileSubtitleByHash(other, "456")
+	assert.Nil(err)
+	assert.Nil(subtitle)
 }
 
 func TestShowWithFiles(t *testing.T) {
@@ -401,6 +543,22 @@ func TestShowWithFiles(t *testing.T) {
 	assert.Equal("/baz/qux", show2.Files[1].Path)
 	assert.Equal(uint64(42), show2.Files[0].Size)
 	assert.Equal(uint64(56), show2.Files[1].Size)
+
+	show3, err := lib.GetShowByFile(show2.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(show2.ID, show3.ID)
+
+	fileC, err := lib.GetFileByPath("/qux/quux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	show4, err := lib.GetShowByFile(fileC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(show4)
 }
 
 func TestSeriesWithEpisodes(t *testing.T) {
@@ -481,3 +639,184 @@ func TestSeriesWithEpisodes(t *testing.T) {
 	assert.Equal(series3.ID, series30.ID)
 	assert.Equal(series3.ID, series31.ID)
 }
+
+func TestRemoveFile(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := lib.GetSeriesByImdbID(555555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epA, err := lib.GetShowByImdbID(999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epB, err := lib.GetShowByImdbID(888888)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epB.Wanted = true
+
+	fileA, err := lib.GetFileByPath("/foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileB, err := lib.GetFileByPath("/baz/qux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtitle, err := lib.GetSubtitleByFilename("/foo/bar.srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileA.Subtitles = []*Subtitle{subtitle}
+	epA.Files = []*VideoFile{fileA}
+	epB.Files = []*VideoFile{fileB}
+	series.Episodes = []*Show{epA, epB}
+
+	for _, item := range []interface{}{series, epA, epB, fileA, fileB, subtitle} {
+		err = lib.Save(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, event := range []*PlaybackEvent{
+		{ShowID: epA.ID, VideoFileID: fileA.ID, Started: time.Now()},
+		{ShowID: epB.ID, VideoFileID: fileB.ID, Started: time.Now()},
+	} {
+		err = lib.AddPlaybackEvent(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert := assert.New(t)
+
+	err = lib.RemoveFile(fileA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := lib.GetPlaybackHistory(epA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(1, len(history), "history of kept shows should be kept") {
+		assert.Equal(uint(0), history[0].VideoFileID)
+	}
+
+	isin, err := lib.HasFileWithPath("/foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(isin)
+
+	err = lib.db.Where("filename = ?", "/foo/bar.srt").First(&Subtitle{}).Error
+	assert.Equal(gorm.ErrRecordNotFound, err, "subtitle should be removed")
+
+	isin, err = lib.HasShowWithImdbID(999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(isin, "episode should be kept in the series' catalog")
+
+	isin, err = lib.HasSeriesWithImdbID(555555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(isin, "series still has a wanted episode")
+
+	err = lib.RemoveFile(fileB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isin, err = lib.HasShowWithImdbID(888888)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(isin, "wanted show should be kept")
+
+	isin, err = lib.HasSeriesWithImdbID(555555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(isin)
+
+	fileD, err := lib.GetFileByPath("/baz/quux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	epB.Wanted = false
+	epB.Files = []*VideoFile{fileD}
+	err = lib.Save(epB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lib.RemoveFile(fileD)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{999999, 888888} {
+		isin, err = lib.HasShowWithImdbID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(isin, "episodes of unused series should be removed")
+	}
+
+	isin, err = lib.HasSeriesWithImdbID(555555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(isin, "unused series should be removed")
+
+	var events int
+	err = lib.db.Model(&PlaybackEvent{}).Count(&events).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(0, events, "history of removed episodes should be removed")
+
+	movie, err := lib.GetShowByImdbID(777777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileC, err := lib.GetFileByPath("/qux/quux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie.Files = []*VideoFile{fileC}
+	err = lib.Save(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lib.AddPlaybackEvent(&PlaybackEvent{
+		ShowID: movie.ID, VideoFileID: fileC.ID, Started: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lib.RemoveFile(fileC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isin, err = lib.HasShowWithImdbID(777777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(isin, "movie without files should be removed")
+
+	err = lib.db.Model(&PlaybackEvent{}).Count(&events).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(0, events, "history of removed movies should be removed")
+}
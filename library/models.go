@@ -8,6 +8,23 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+// Import steps, which are the keys of the ImportStatus maps
+const (
+	HashStep      = "hash"
+	ProbeStep     = "media_probe"
+	OsdbStep      = "osdb"
+	SubtitlesStep = "subtitles"
+	SidecarStep   = "sidecar_subtitles"
+	ImdbStep      = "imdb"
+)
+
+var (
+	// FileSteps are the import steps of a video file
+	FileSteps = []string{HashStep, ProbeStep, SidecarStep, OsdbStep, SubtitlesStep}
+	// ShowSteps are the import steps of a show or a series
+	ShowSteps = []string{ImdbStep}
+)
+
 // Show is a movie or an episode of a series
 type Show struct {
 	gorm.Model
@@ -18,6 +35,9 @@ type Show struct {
 	ReleaseDate time.Time `json:"release_date"`
 	Tagline     string    `json:"tagline"`
 
+	// Watched is derived from the show's latest playback event
+	Watched bool `json:"watched"`
+
 	Files []*VideoFile `json:"files",gorm:"ForeignKey:ShowID"`
 }
 
@@ -36,7 +56,10 @@ type CommonData struct {
 	ImdbVotes   int                   `json:"imdb_votes"`
 	Languages   types.Languages       `gorm:"type:text",json:"languages"`
 
-	ImdbError *string `json:"imdb_error"`
+	// Wanted items are kept in the library even when they have no files
+	Wanted bool `json:"wanted"`
+
+	ImportStatus types.MapStringStepStatus `gorm:"type:blob" json:"import_status"`
 }
 
 // EpisodeData contains episode-specific keys
@@ -46,6 +69,17 @@ type EpisodeData struct {
 	SeriesID uint
 }
 
+// FilenameData contains information guessed from a video file's name
+type FilenameData struct {
+	GuessedTitle      string `json:"guessed_title"`
+	GuessedYear       int    `json:"guessed_year"`
+	GuessedSeason     int    `json:"guessed_season"`
+	GuessedEpisode    int    `json:"guessed_episode"`
+	GuessedResolution string `json:"guessed_resolution"`
+	GuessedSource     string `json:"guessed_source"`
+	GuessedGroup      string `json:"guessed_group"`
+}
+
 // Series represents a series
 type Series struct {
 	gorm.Model
@@ -63,6 +97,7 @@ type VideoFile struct {
 	Path             string          `json:"filename",sql:"unique"`
 	OriginalBasename string          `json:"original_basename"`
 	Size             uint64          `json:"filesize"`
+	ModTime          time.Time       `json:"mod_time"`
 	ResolutionX      uint            `json:"resolution"`
 	ResolutionY      uint            `json:"resolution"`
 	OsdbHash         types.BigUint64 `gorm:"type:varchar(16)",json:"osdb_hash"`
@@ -73,16 +108,19 @@ type VideoFile struct {
 	AudioBitrate     float32         `json:"audio_bitrate"`
 	Duration         types.Duration  `json:"duration"`
 
+	AudioTracks    types.Tracks `gorm:"type:blob" json:"audio_tracks"`
+	SubtitleTracks types.Tracks `gorm:"type:blob" json:"subtitle_tracks"`
+
 	LastPlayed   time.Time      `json:"last_played"`
 	LastPosition types.Duration `json:"last_position"`
 
+	FilenameData
+
 	ShowID uint
 
 	Subtitles []*Subtitle `json:"subtitles",gorm:"ForeignKey:VideoFileID"`
 
-	ImportError    *string `json:"import_error"`
-	OsdbError      *string `json:"osdb_error"`
-	SubtitlesError *string `json:"subtitles_error"`
+	ImportStatus types.MapStringStepStatus `gorm:"type:blob" json:"import_status"`
 }
 
 // Subtitle represents a subtitle file
@@ -94,9 +132,47 @@ type Subtitle struct {
 	Language        types.Language `gorm:"type:varchar(3)",json:"language"`
 	HearingImpaired bool           `json:"hearing_impaired"`
 	Filename        string         `json:"filename",sql:"unique"`
-	Score           int            `json:"score"`
+	// Score orders the subtitles of a file: the lower, the better
+	Score int `json:"score"`
+	// Encoding is the character encoding in which the subtitle came
+	// (it is converted to UTF-8 on import, unless configured otherwise)
+	Encoding string `json:"encoding"`
+	// Offset and TimeFactor are the timing corrections made to the
+	// subtitle after it was saved: each time t in the original is now at
+	// t*TimeFactor + Offset. They're applied again if it's re-downloaded.
+	Offset     types.Duration `json:"offset"`
+	TimeFactor float64        `json:"time_factor"`
+
+	VideoFileID uint
+}
+
+// Timing returns the factor and the offset by which the subtitle's times
+// have been adjusted (a zero TimeFactor means that they haven't been scaled)
+func (s *Subtitle) Timing() (float64, time.Duration) {
+	factor := s.TimeFactor
+	if factor == 0 {
+		factor = 1
+	}
+	return factor, time.Duration(s.Offset)
+}
+
+// Retimed tells whether the subtitle's times have been adjusted
+func (s *Subtitle) Retimed() bool {
+	factor, offset := s.Timing()
+	return factor != 1 || offset != 0
+}
+
+// PlaybackEvent records a single playback of a show, or the user
+// manually marking it as watched or unwatched
+type PlaybackEvent struct {
+	gorm.Model
 
+	ShowID      uint
 	VideoFileID uint
+
+	Started   time.Time      `json:"started"`
+	Position  types.Duration `json:"position"`
+	Completed bool           `json:"completed"`
 }
 
 // ShowWithFile is a pair of a Show and a VideoFile
@@ -107,6 +183,24 @@ type ShowWithFile struct {
 	File *VideoFile
 }
 
+// Step returns the import status of the given step, creating it if
+// needed
+func (d *CommonData) Step(name string) *types.StepStatus {
+	if d.ImportStatus == nil {
+		d.ImportStatus = make(types.MapStringStepStatus)
+	}
+	return d.ImportStatus.For(name)
+}
+
+// Step returns the import status of the given step, creating it if
+// needed
+func (v *VideoFile) Step(name string) *types.StepStatus {
+	if v.ImportStatus == nil {
+		v.ImportStatus = make(types.MapStringStepStatus)
+	}
+	return v.ImportStatus.For(name)
+}
+
 // AfterCreate initializes values on an empty series
 func (s *Series) AfterCreate() error {
 	// nothing! FIXME: remove this function
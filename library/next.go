@@ -0,0 +1,101 @@
+package library
+
+// Next is the episode of a series which should be watched next, along
+// with the episodes which were passed over when finding it
+type Next struct {
+	// Episode is nil if there's no episode to watch next
+	Episode *Show
+	// Missing are the unwatched episodes between the last watched one and
+	// Episode which have no files (gaps in the series), in order. Episodes
+	// without files after the last one with a file aren't gaps, since
+	// they're usually yet to be downloaded or aired.
+	Missing []*Show
+	// Specials are the unwatched specials (season 0) which have files.
+	// They aren't part of the order, since they can air at any time.
+	Specials []*Show
+}
+
+// NextEpisode finds the episode of the series which should be watched
+// next: the first unwatched episode after the last watched one which has
+// a file. Specials are reported separately, and episodes without files are
+// skipped and reported as missing.
+func (lib *Library) NextEpisode(series *Series) (*Next, error) {
+	var episodes []*Show
+	err := lib.db.Where("series_id = ?", series.ID).
+		Order("season, episode").Find(&episodes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	next := &Next{}
+
+	start := 0
+	for i, episode := range episodes {
+		if episode.Season == 0 && !episode.Watched {
+			err = lib.db.Model(episode).Association("Files").Find(&episode.Files).Error
+			if err != nil {
+				return nil, err
+			}
+			if len(episode.Files) > 0 {
+				next.Specials = append(next.Specials, episode)
+			}
+		}
+		if episode.Season > 0 && episode.Watched {
+			start = i + 1
+		}
+	}
+
+	var missing []*Show
+	for _, episode := range episodes[start:] {
+		if episode.Season == 0 || episode.Watched {
+			continue
+		}
+
+		err = lib.db.Model(episode).Association("Files").Find(&episode.Files).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(episode.Files) > 0 {
+			next.Episode = episode
+			next.Missing = missing
+			break
+		}
+		missing = append(missing, episode)
+	}
+
+	return next, nil
+}
+
+// SeriesInProgress returns all series which have at least one watched
+// episode (including specials)
+func (lib *Library) SeriesInProgress() ([]*Series, error) {
+	var series []*Series
+	err := lib.db.Where(
+		"id IN (SELECT series_id FROM shows WHERE watched = ?)", true,
+	).Order("title").Find(&series).Error
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// BestFile returns the show's file with the highest resolution (or the
+// largest one if resolutions are equal), or nil if the show has no files
+func (s *Show) BestFile() *VideoFile {
+	var best *VideoFile
+	for _, file := range s.Files {
+		if best == nil || betterFile(file, best) {
+			best = file
+		}
+	}
+	return best
+}
+
+func betterFile(a *VideoFile, b *VideoFile) bool {
+	pixelsA := a.ResolutionX * a.ResolutionY
+	pixelsB := b.ResolutionX * b.ResolutionY
+	if pixelsA != pixelsB {
+		return pixelsA > pixelsB
+	}
+	return a.Size > b.Size
+}
@@ -0,0 +1,136 @@
+package library
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextEpisode(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := lib.GetSeriesByImdbID(555555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series.Title = "Some Series"
+
+	// S00E01, S01E01, S01E02, S01E04 (no S01E03), S02E01, S02E02
+	numbers := [][2]int{{0, 1}, {1, 1}, {1, 2}, {1, 4}, {2, 1}, {2, 2}}
+	episodes := make(map[[2]int]*Show)
+	for i, number := range numbers {
+		episode, err := lib.GetShowByImdbID(999900 + i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		episode.Season = number[0]
+		episode.Episode = number[1]
+
+		// S02E01 has no file
+		if number != [2]int{2, 1} {
+			file, err := lib.GetFileByPath(
+				fmt.Sprintf("/series/s%02de%02d.mkv", number[0], number[1]),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			episode.Files = []*VideoFile{file}
+		}
+
+		episodes[number] = episode
+		series.Episodes = append(series.Episodes, episode)
+	}
+	if err := lib.Save(series); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	var missing, specials []string
+	next := func() string {
+		next, err := lib.NextEpisode(series)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		missing, specials = nil, nil
+		for _, episode := range next.Missing {
+			missing = append(missing, fmt.Sprintf("S%02dE%02d", episode.Season, episode.Episode))
+		}
+		for _, episode := range next.Specials {
+			specials = append(specials, episode.BestFile().Path)
+		}
+
+		if next.Episode == nil {
+			return "none"
+		}
+		return next.Episode.BestFile().Path
+	}
+
+	inProgress := func() int {
+		series, err := lib.SeriesInProgress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(series)
+	}
+
+	assert.Equal("/series/s01e01.mkv", next())
+	assert.Equal([]string{"/series/s00e01.mkv"}, specials)
+	assert.Equal(0, inProgress())
+
+	// specials aren't part of the order, but count as progress
+	assert.Nil(lib.MarkWatched(episodes[[2]int{0, 1}]))
+	assert.Equal("/series/s01e01.mkv", next())
+	assert.Empty(specials)
+	assert.Equal(1, inProgress())
+
+	assert.Nil(lib.MarkWatched(episodes[[2]int{1, 1}]))
+	assert.Equal("/series/s01e02.mkv", next())
+	assert.Equal(1, inProgress())
+
+	assert.Nil(lib.MarkWatched(episodes[[2]int{1, 2}]))
+	assert.Equal("/series/s01e04.mkv", next())
+
+	assert.Empty(missing)
+
+	// S02E01 has no file, so it's skipped and reported
+	assert.Nil(lib.MarkWatched(episodes[[2]int{1, 4}]))
+	assert.Equal("/series/s02e02.mkv", next())
+	assert.Equal([]string{"S02E01"}, missing)
+
+	// the last watched episode decides, even if there are earlier
+	// unwatched ones
+	assert.Nil(lib.MarkUnwatched(episodes[[2]int{1, 2}]))
+	assert.Equal("/series/s02e02.mkv", next())
+
+	assert.Nil(lib.MarkWatched(episodes[[2]int{2, 2}]))
+	assert.Equal("none", next())
+	assert.Empty(missing)
+
+	// episodes without files after the last one with a file aren't gaps
+	assert.Nil(lib.MarkUnwatched(episodes[[2]int{2, 2}]))
+	assert.Nil(lib.MarkUnwatched(episodes[[2]int{1, 4}]))
+	assert.Nil(lib.MarkWatched(episodes[[2]int{1, 2}]))
+	assert.Nil(lib.db.Unscoped().Where("path = ?", "/series/s02e02.mkv").Delete(&VideoFile{}).Error)
+	assert.Equal("/series/s01e04.mkv", next())
+	assert.Nil(lib.MarkWatched(episodes[[2]int{1, 4}]))
+	assert.Equal("none", next())
+	assert.Empty(missing)
+}
+
+func TestBestFile(t *testing.T) {
+	show := &Show{}
+	assert.Nil(t, show.BestFile())
+
+	show.Files = []*VideoFile{
+		{Path: "small", ResolutionX: 640, ResolutionY: 480, Size: 100},
+		{Path: "hd", ResolutionX: 1280, ResolutionY: 720, Size: 50},
+		{Path: "hd-large", ResolutionX: 1280, ResolutionY: 720, Size: 80},
+	}
+	assert.Equal(t, "hd-large", show.BestFile().Path)
+}
@@ -0,0 +1,127 @@
+package library
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AddPlaybackEvent records the event in the playback history. The position
+// of the played file and the watched state of the show are updated.
+func (lib *Library) AddPlaybackEvent(event *PlaybackEvent) error {
+	err := lib.db.Create(event).Error
+	if err != nil {
+		return err
+	}
+
+	if event.VideoFileID != 0 {
+		err = lib.db.Model(&VideoFile{}).Where("id = ?", event.VideoFileID).Updates(
+			map[string]interface{}{
+				"last_played":   event.Started,
+				"last_position": event.Position,
+			},
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return lib.updateWatched(event.ShowID)
+}
+
+// GetPlaybackHistory returns the playback events of the show, oldest first
+func (lib *Library) GetPlaybackHistory(show *Show) ([]*PlaybackEvent, error) {
+	var events []*PlaybackEvent
+	err := lib.db.Where("show_id = ?", show.ID).Order("started, id").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkWatched marks the show as watched
+func (lib *Library) MarkWatched(show *Show) error {
+	return lib.setWatched(show, true)
+}
+
+// MarkUnwatched marks the show as unwatched
+func (lib *Library) MarkUnwatched(show *Show) error {
+	return lib.setWatched(show, false)
+}
+
+// MarkSeasonWatched marks all episodes of a season of the series as watched
+func (lib *Library) MarkSeasonWatched(series *Series, season int) error {
+	return lib.setSeasonWatched(series, season, true)
+}
+
+// MarkSeasonUnwatched marks all episodes of a season of the series as unwatched
+func (lib *Library) MarkSeasonUnwatched(series *Series, season int) error {
+	return lib.setSeasonWatched(series, season, false)
+}
+
+// MarkSeriesWatched marks all episodes of the series as watched
+func (lib *Library) MarkSeriesWatched(series *Series) error {
+	return lib.setEpisodesWatched(lib.db.Where("series_id = ?", series.ID), true)
+}
+
+// MarkSeriesUnwatched marks all episodes of the series as unwatched
+func (lib *Library) MarkSeriesUnwatched(series *Series) error {
+	return lib.setEpisodesWatched(lib.db.Where("series_id = ?", series.ID), false)
+}
+
+func (lib *Library) setSeasonWatched(series *Series, season int, watched bool) error {
+	return lib.setEpisodesWatched(
+		lib.db.Where("series_id = ? AND season = ?", series.ID, season),
+		watched,
+	)
+}
+
+func (lib *Library) setEpisodesWatched(query *gorm.DB, watched bool) error {
+	var episodes []*Show
+	err := query.Find(&episodes).Error
+	if err != nil {
+		return err
+	}
+
+	for _, episode := range episodes {
+		err = lib.setWatched(episode, watched)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setWatched records a manual playback event which marks the show
+// as watched or unwatched
+func (lib *Library) setWatched(show *Show, watched bool) error {
+	event := &PlaybackEvent{
+		ShowID:    show.ID,
+		Started:   time.Now(),
+		Completed: watched,
+	}
+	if watched {
+		event.Position = show.Duration
+	}
+
+	err := lib.AddPlaybackEvent(event)
+	if err != nil {
+		return err
+	}
+
+	show.Watched = watched
+	return nil
+}
+
+// updateWatched sets the show's watched flag from its latest playback event
+func (lib *Library) updateWatched(showID uint) error {
+	event := &PlaybackEvent{}
+	err := lib.db.Where("show_id = ?", showID).Order("started DESC, id DESC").First(event).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	return lib.db.Model(&Show{}).Where("id = ?", showID).Update(
+		"watched", err == nil && event.Completed,
+	).Error
+}
@@ -0,0 +1,153 @@
+package library
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DexterLB/mvm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaybackEvents(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	show, err := lib.GetShowByImdbID(999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	show.Duration = types.Duration(42 * time.Minute)
+	file, err := lib.GetFileByPath("/foo/bar.mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	show.Files = []*VideoFile{file}
+	if err := lib.Save(show); err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Date(2016, time.May, 1, 20, 0, 0, 0, time.UTC)
+
+	err = lib.AddPlaybackEvent(&PlaybackEvent{
+		ShowID:      show.ID,
+		VideoFileID: file.ID,
+		Started:     started,
+		Position:    types.Duration(10 * time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	show2, err := lib.GetShowByImdbID(999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(show2.Watched)
+	assert.Equal(1, len(show2.Files))
+	assert.Equal(types.Duration(10*time.Minute), show2.Files[0].LastPosition)
+	assert.True(started.Equal(show2.Files[0].LastPlayed))
+
+	err = lib.AddPlaybackEvent(&PlaybackEvent{
+		ShowID:      show.ID,
+		VideoFileID: file.ID,
+		Started:     started.Add(time.Hour),
+		Position:    types.Duration(41 * time.Minute),
+		Completed:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	show3, err := lib.GetShowByImdbID(999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(show3.Watched)
+
+	err = lib.MarkUnwatched(show3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(show3.Watched)
+
+	show4, err := lib.GetShowByImdbID(999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(show4.Watched)
+
+	history, err := lib.GetPlaybackHistory(show4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(3, len(history))
+	assert.False(history[0].Completed)
+	assert.True(history[1].Completed)
+	assert.False(history[2].Completed)
+	assert.Equal(file.ID, history[1].VideoFileID)
+
+	err = lib.MarkWatched(show4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err = lib.GetPlaybackHistory(show4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(4, len(history))
+	assert.Equal(types.Duration(42*time.Minute), history[3].Position)
+}
+
+func TestMarkSeriesWatched(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := lib.GetSeriesByImdbID(555555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, imdbID := range []int{999991, 999992, 999993} {
+		episode, err := lib.GetShowByImdbID(imdbID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		episode.Season = 1 + i/2
+		episode.Episode = 1 + i%2
+		series.Episodes = append(series.Episodes, episode)
+	}
+	if err := lib.Save(series); err != nil {
+		t.Fatal(err)
+	}
+
+	watched := func() []bool {
+		var result []bool
+		for _, imdbID := range []int{999991, 999992, 999993} {
+			episode, err := lib.GetShowByImdbID(imdbID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, episode.Watched)
+		}
+		return result
+	}
+
+	assert := assert.New(t)
+
+	assert.Nil(lib.MarkSeasonWatched(series, 1))
+	assert.Equal([]bool{true, true, false}, watched())
+
+	assert.Nil(lib.MarkSeriesWatched(series))
+	assert.Equal([]bool{true, true, true}, watched())
+
+	assert.Nil(lib.MarkSeasonUnwatched(series, 2))
+	assert.Equal([]bool{true, true, false}, watched())
+
+	assert.Nil(lib.MarkSeriesUnwatched(series))
+	assert.Equal([]bool{false, false, false}, watched())
+}
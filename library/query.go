@@ -0,0 +1,315 @@
+package library
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/DexterLB/mvm/types"
+)
+
+// Query returns all movies and episodes matching the query, with their files.
+//
+// A query is a list of space-separated terms, all of which must match.
+// A term is either a word to search for in titles, a keyword, or a
+// key-value pair such as season:2 or year>2010. Values containing spaces
+// must be quoted: series:"the wire". Terms prefixed with - are negated.
+//
+// Keys:
+//
+//	title:     the show's title contains the text
+//	series:    the title of the show's series contains the text
+//	path:      the path of one of the show's files contains the text
+//	season:    season number (supports comparisons, e.g. season>2)
+//	episode:   episode number (supports comparisons)
+//	year:      release year (supports comparisons)
+//	rating:    imdb rating (supports comparisons)
+//	votes:     number of imdb votes (supports comparisons)
+//	released:  release date as 2006-01-02 or 2006 (supports comparisons)
+//	imdb:      imdb id, either as a number or as tt1234567
+//	lang:      the show has subtitles in this language
+//	spoken:    the show is spoken in this language
+//
+// Keywords:
+//
+//	watched, unwatched, movie, episode,
+//	missing (aired episodes without files), upcoming (episodes yet to air)
+func (lib *Library) Query(query string) ([]*Show, error) {
+	terms, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	db := lib.db
+	for _, term := range terms {
+		condition, arguments, err := term.condition()
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(condition, arguments...)
+	}
+
+	var shows []*Show
+	err = db.Order("series_id, season, episode, year, title").Find(&shows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, show := range shows {
+		err = lib.db.Model(show).Association("Files").Find(&show.Files).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return shows, nil
+}
+
+// queryTerm is a single condition in a query
+type queryTerm struct {
+	key      string
+	operator string
+	value    string
+	negated  bool
+}
+
+// parseQuery splits a query into terms
+func parseQuery(query string) ([]*queryTerm, error) {
+	tokens, err := splitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := make([]*queryTerm, len(tokens))
+	for i, token := range tokens {
+		terms[i] = parseTerm(token)
+	}
+	return terms, nil
+}
+
+// splitQuery splits the query on whitespace, keeping quoted parts intact
+// and removing the quotes
+func splitQuery(query string) ([]string, error) {
+	var (
+		tokens  []string
+		current []rune
+		quoted  bool
+		inToken bool
+	)
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inToken = true
+		case unicode.IsSpace(r) && !quoted:
+			if inToken {
+				tokens = append(tokens, string(current))
+			}
+			current = current[:0]
+			inToken = false
+		default:
+			current = append(current, r)
+			inToken = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in query")
+	}
+	if inToken {
+		tokens = append(tokens, string(current))
+	}
+
+	return tokens, nil
+}
+
+var queryOperators = []string{">=", "<=", ":", "=", ">", "<"}
+
+// parseTerm parses a single (unquoted) query token
+func parseTerm(token string) *queryTerm {
+	term := &queryTerm{}
+
+	if len(token) > 1 && token[0] == '-' {
+		term.negated = true
+		token = token[1:]
+	}
+
+	position := strings.IndexAny(token, ":=<>")
+	if position <= 0 {
+		term.value = token
+		return term
+	}
+
+	for _, operator := range queryOperators {
+		if strings.HasPrefix(token[position:], operator) {
+			term.key = strings.ToLower(token[:position])
+			term.operator = operator
+			term.value = token[position+len(operator):]
+			break
+		}
+	}
+
+	return term
+}
+
+// condition compiles the term to an SQL condition
+func (t *queryTerm) condition() (string, []interface{}, error) {
+	condition, arguments, err := t.positiveCondition()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if t.negated {
+		condition = fmt.Sprintf("NOT (%s)", condition)
+	}
+	return condition, arguments, nil
+}
+
+func (t *queryTerm) positiveCondition() (string, []interface{}, error) {
+	switch t.key {
+	case "":
+		return t.keywordCondition()
+	case "title":
+		return t.textCondition(`title LIKE ? ESCAPE '\'`)
+	case "series":
+		return t.textCondition(
+			`series_id IN (SELECT id FROM series WHERE title LIKE ? ESCAPE '\')`,
+		)
+	case "path":
+		return t.textCondition(
+			`id IN (SELECT show_id FROM video_files WHERE path LIKE ? ESCAPE '\')`,
+		)
+	case "season", "episode", "year":
+		return t.numberCondition(t.key)
+	case "rating":
+		return t.numberCondition("imdb_rating")
+	case "votes":
+		return t.numberCondition("imdb_votes")
+	case "released":
+		return t.dateCondition("release_date")
+	case "imdb":
+		return t.imdbCondition()
+	case "lang":
+		return t.languageCondition(
+			"id IN (SELECT show_id FROM video_files WHERE id IN " +
+				"(SELECT video_file_id FROM subtitles WHERE language = ?))",
+		)
+	case "spoken":
+		return t.languageCondition(
+			"(' ' || languages || ' ') LIKE ('% ' || ? || ' %')",
+		)
+	default:
+		return "", nil, fmt.Errorf("unknown query key: %s", t.key)
+	}
+}
+
+// keywordCondition handles terms without a key, which are either keywords
+// or words which must be contained in the title
+func (t *queryTerm) keywordCondition() (string, []interface{}, error) {
+	switch strings.ToLower(t.value) {
+	case "watched":
+		return "watched = ?", []interface{}{true}, nil
+	case "unwatched":
+		return "watched = ?", []interface{}{false}, nil
+	case "missing":
+		return missingCondition, []interface{}{time.Time{}, time.Now()}, nil
+	case "upcoming":
+		return upcomingCondition, []interface{}{time.Now()}, nil
+	case "movie", "movies":
+		return "series_id = 0", nil, nil
+	case "episode", "episodes":
+		return "series_id != 0", nil, nil
+	}
+
+	return t.textCondition(
+		`(title LIKE ? ESCAPE '\' OR series_id IN (SELECT id FROM series WHERE title LIKE ? ESCAPE '\'))`,
+	)
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns (with \ as the
+// escape character), so that they match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// textCondition passes the term's value as a substring pattern for
+// each placeholder in the condition (the conditions have to declare \
+// as the escape character of their LIKE patterns)
+func (t *queryTerm) textCondition(condition string) (string, []interface{}, error) {
+	if t.operator != ":" && t.operator != "=" && t.operator != "" {
+		return "", nil, fmt.Errorf("can't compare text with %s", t.operator)
+	}
+
+	pattern := "%" + likeEscaper.Replace(t.value) + "%"
+	arguments := make([]interface{}, strings.Count(condition, "?"))
+	for i := range arguments {
+		arguments[i] = pattern
+	}
+	return condition, arguments, nil
+}
+
+func (t *queryTerm) numberCondition(column string) (string, []interface{}, error) {
+	number, err := strconv.ParseFloat(t.value, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid number for %s: %s", t.key, t.value)
+	}
+	return t.comparison(column), []interface{}{number}, nil
+}
+
+func (t *queryTerm) dateCondition(column string) (string, []interface{}, error) {
+	if year, err := strconv.Atoi(t.value); err == nil {
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		end := start.AddDate(1, 0, 0)
+		return dateRangeCondition(column, t.operator, start, end)
+	}
+
+	start, err := time.Parse("2006-01-02", t.value)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid date for %s: %s", t.key, t.value)
+	}
+	return dateRangeCondition(column, t.operator, start, start.AddDate(0, 0, 1))
+}
+
+// dateRangeCondition compares a date column with the period [start, end)
+func dateRangeCondition(column string, operator string, start time.Time, end time.Time) (string, []interface{}, error) {
+	switch operator {
+	case ":", "=":
+		return fmt.Sprintf("(%s >= ? AND %s < ?)", column, column),
+			[]interface{}{start, end}, nil
+	case ">":
+		return column + " >= ?", []interface{}{end}, nil
+	case ">=":
+		return column + " >= ?", []interface{}{start}, nil
+	case "<":
+		return column + " < ?", []interface{}{start}, nil
+	case "<=":
+		return column + " < ?", []interface{}{end}, nil
+	}
+	return "", nil, fmt.Errorf("unknown operator: %s", operator)
+}
+
+func (t *queryTerm) imdbCondition() (string, []interface{}, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(t.value, "tt"))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid imdb id: %s", t.value)
+	}
+	return "imdb_id = ?", []interface{}{id}, nil
+}
+
+func (t *queryTerm) languageCondition(condition string) (string, []interface{}, error) {
+	language, err := types.ParseLanguage(t.value)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid language: %s", t.value)
+	}
+	return condition, []interface{}{language.String()}, nil
+}
+
+// comparison makes an SQL comparison between the column and a placeholder
+func (t *queryTerm) comparison(column string) string {
+	operator := t.operator
+	if operator == ":" {
+		operator = "="
+	}
+	return fmt.Sprintf("%s %s ?", column, operator)
+}
@@ -0,0 +1,153 @@
+package library
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DexterLB/mvm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	terms, err := parseQuery(`series:"the wire" season:2  unwatched year>2010 -lang:de "foo bar"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []*queryTerm{
+		{key: "series", operator: ":", value: "the wire"},
+		{key: "season", operator: ":", value: "2"},
+		{value: "unwatched"},
+		{key: "year", operator: ">", value: "2010"},
+		{key: "lang", operator: ":", value: "de", negated: true},
+		{value: "foo bar"},
+	}, terms)
+
+	terms, err = parseQuery(`rating>=7.5 released<=2016-03-01`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*queryTerm{
+		{key: "rating", operator: ">=", value: "7.5"},
+		{key: "released", operator: "<=", value: "2016-03-01"},
+	}, terms)
+
+	_, err = parseQuery(`series:"the wire`)
+	assert.NotNil(t, err)
+}
+
+func TestQuery(t *testing.T) {
+	lib, err := New("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := lib.GetSeriesByImdbID(306414)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series.Title = "The Wire"
+
+	var episodes []*Show
+	for i, imdbID := range []int{749451, 749452, 749453} {
+		episode, err := lib.GetShowByImdbID(imdbID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		episode.Title = "Episode"
+		episode.Season = 1 + i/2
+		episode.Episode = 1 + i%2
+		episode.Year = 2002 + i/2
+		episodes = append(episodes, episode)
+	}
+	series.Episodes = episodes
+
+	movie, err := lib.GetShowByImdbID(1375666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie.Title = "Inception"
+	movie.Year = 2010
+	movie.ImdbRating = 8.8
+	movie.ReleaseDate = time.Date(2010, time.July, 16, 0, 0, 0, 0, time.UTC)
+	movie.Languages = types.Languages{
+		types.MustParseLanguage("en"),
+		types.MustParseLanguage("ja"),
+	}
+
+	file, err := lib.GetFileByPath("/movies/inception.mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtitle, err := lib.GetSubtitleByFilename("/movies/inception.de.srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtitle.Language = types.MustParseLanguage("de")
+	file.Subtitles = []*Subtitle{subtitle}
+	movie.Files = []*VideoFile{file}
+
+	for _, item := range []interface{}{series, movie, file, subtitle} {
+		if err := lib.Save(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := lib.MarkWatched(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	ids := func(query string) []int {
+		shows, err := lib.Query(query)
+		if err != nil {
+			t.Errorf("error for query %s: %s", query, err)
+			return nil
+		}
+
+		var result []int
+		for _, show := range shows {
+			result = append(result, show.ImdbID)
+		}
+		return result
+	}
+
+	assert.Equal([]int{1375666, 749451, 749452, 749453}, ids(""))
+	assert.Equal([]int{749451, 749452, 749453}, ids(`series:"the wire"`))
+	assert.Equal([]int{749453}, ids(`series:"the wire" season:2`))
+	assert.Equal([]int{749451}, ids(`wire season:1 -episode:2`))
+	assert.Equal([]int{749451, 749452, 749453}, ids(`episode`))
+	assert.Equal([]int{1375666}, ids(`incep`))
+	assert.Equal([]int{1375666}, ids(`movie year>=2010`))
+	assert.Equal([]int{749453}, ids(`year>2002 year<2010`))
+	assert.Equal([]int{1375666}, ids(`rating>8`))
+	assert.Equal([]int{1375666}, ids(`released:2010-07-16`))
+	assert.Equal([]int{1375666}, ids(`released:2010`))
+	assert.Equal([]int(nil), ids(`released>2010`))
+	assert.Equal([]int{1375666}, ids(`imdb:tt1375666`))
+	assert.Equal([]int{1375666}, ids(`lang:de`))
+	assert.Equal([]int(nil), ids(`lang:fr`))
+	assert.Equal([]int{1375666}, ids(`spoken:ja`))
+	assert.Equal([]int{1375666}, ids(`watched`))
+	assert.Equal([]int{749451, 749452, 749453}, ids(`unwatched`))
+	assert.Equal([]int{1375666}, ids(`path:inception`))
+	assert.Equal([]int{1375666}, ids(`path:/movies/inception.mkv`))
+	assert.Equal([]int(nil), ids(`path:/movies/incep_ion`))
+	assert.Equal([]int(nil), ids(`title:%`))
+	assert.Equal([]int(nil), ids(`incep\`))
+
+	shows, err := lib.Query(`inception`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1, len(shows[0].Files))
+	assert.Equal("/movies/inception.mkv", shows[0].Files[0].Path)
+
+	_, err = lib.Query(`foo:bar`)
+	assert.NotNil(err)
+	_, err = lib.Query(`season:two`)
+	assert.NotNil(err)
+	_, err = lib.Query(`title>foo`)
+	assert.NotNil(err)
+}
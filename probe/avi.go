@@ -0,0 +1,253 @@
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// aviCodecs maps video FourCCs to codec names
+var aviCodecs = map[string]string{
+	"XVID": "mpeg4",
+	"DIVX": "mpeg4",
+	"DX50": "mpeg4",
+	"FMP4": "mpeg4",
+	"MP4V": "mpeg4",
+	"DIV3": "msmpeg4v3",
+	"MP43": "msmpeg4v3",
+	"H264": "h264",
+	"X264": "h264",
+	"AVC1": "h264",
+	"HEVC": "hevc",
+	"H265": "hevc",
+	"MJPG": "mjpeg",
+	"CVID": "cinepak",
+	"IV41": "indeo4",
+	"IV50": "indeo5",
+	"WMV3": "wmv3",
+	"MPG2": "mpeg2video",
+}
+
+// aviAudioCodecs maps WAVEFORMATEX format tags to codec names
+var aviAudioCodecs = map[uint16]string{
+	0x0001: "pcm",
+	0x0050: "mp2",
+	0x0055: "mp3",
+	0x00ff: "aac",
+	0x0161: "wmav2",
+	0x2000: "ac3",
+	0x2001: "dts",
+	0xf1ac: "flac",
+}
+
+type riffChunk struct {
+	id   string
+	size int64
+}
+
+func readRiffChunk(r io.Reader) (*riffChunk, error) {
+	header := make([]byte, 8)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	return &riffChunk{
+		id:   string(header[0:4]),
+		size: int64(binary.LittleEndian.Uint32(header[4:8])),
+	}, nil
+}
+
+// body reads the chunk's contents
+func (c *riffChunk) body(r io.Reader) ([]byte, error) {
+	if c.size > 1<<20 {
+		return nil, fmt.Errorf("riff chunk %s too large", c.id)
+	}
+	if c.size < 0 {
+		return nil, fmt.Errorf("invalid riff chunk %s size: %d", c.id, c.size)
+	}
+	data := make([]byte, c.size+c.size%2)
+	_, err := io.ReadFull(r, data)
+	return data[:c.size], err
+}
+
+func avi(r io.ReadSeeker) (*Info, error) {
+	_, err := r.Seek(12, io.SeekStart) // "RIFF", size, "AVI "
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		chunk, err := readRiffChunk(r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find avi header: %s", err)
+		}
+		skip := chunk.size + chunk.size%2
+
+		if chunk.id == "LIST" {
+			if chunk.size < 4 {
+				return nil, fmt.Errorf("invalid riff list size: %d", chunk.size)
+			}
+			listType := make([]byte, 4)
+			_, err = io.ReadFull(r, listType)
+			if err != nil {
+				return nil, err
+			}
+			if string(listType) == "hdrl" {
+				data, err := (&riffChunk{size: chunk.size - 4}).body(r)
+				if err != nil {
+					return nil, fmt.Errorf("unable to read avi header: %s", err)
+				}
+				return aviHeader(data)
+			}
+			skip -= 4
+		}
+
+		_, err = r.Seek(skip, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// aviHeader parses the contents of the hdrl list
+func aviHeader(data []byte) (*Info, error) {
+	info := &Info{}
+
+	var (
+		microSecPerFrame uint32
+		totalFrames      uint32
+	)
+
+	err := walkRiff(data, func(id string, body []byte) error {
+		switch id {
+		case "avih":
+			if len(body) < 40 {
+				return fmt.Errorf("avi header too short")
+			}
+			microSecPerFrame = binary.LittleEndian.Uint32(body[0:4])
+			totalFrames = binary.LittleEndian.Uint32(body[16:20])
+			info.Width = uint(binary.LittleEndian.Uint32(body[32:36]))
+			info.Height = uint(binary.LittleEndian.Uint32(body[36:40]))
+		case "strl":
+			return aviStream(info, body)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info.Duration = time.Duration(totalFrames) * time.Duration(microSecPerFrame) * time.Microsecond
+	if info.Framerate == 0 && microSecPerFrame != 0 {
+		info.Framerate = 1e6 / float32(microSecPerFrame)
+	}
+
+	return info, nil
+}
+
+// aviStream parses the contents of a strl list
+func aviStream(info *Info, data []byte) error {
+	var (
+		streamType string
+		handler    string
+		rate       float32
+		name       string
+		format     []byte
+	)
+
+	err := walkRiff(data, func(id string, body []byte) error {
+		switch id {
+		case "strh":
+			if len(body) < 28 {
+				return fmt.Errorf("avi stream header too short")
+			}
+			streamType = string(body[0:4])
+			handler = string(body[4:8])
+			scale := binary.LittleEndian.Uint32(body[20:24])
+			if scale != 0 {
+				rate = float32(binary.LittleEndian.Uint32(body[24:28])) / float32(scale)
+			}
+		case "strf":
+			format = body
+		case "strn":
+			name = strings.TrimRight(string(body), "\x00")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch streamType {
+	case "vids":
+		if info.VideoCodec != "" {
+			return nil
+		}
+		fourcc := handler
+		if len(format) >= 20 {
+			fourcc = string(format[16:20])
+		}
+		info.VideoCodec = aviCodecName(fourcc)
+		info.Framerate = rate
+	case "auds":
+		track := Track{Name: name, Language: "und"}
+		var bitrate float32
+		if len(format) >= 12 {
+			tag := binary.LittleEndian.Uint16(format[0:2])
+			track.Codec = aviAudioCodecs[tag]
+			if track.Codec == "" {
+				track.Codec = fmt.Sprintf("0x%04x", tag)
+			}
+			bitrate = float32(binary.LittleEndian.Uint32(format[8:12])) * 8 / 1000
+		}
+		info.addAudioTrack(track, bitrate)
+	case "txts":
+		info.SubtitleTracks = append(info.SubtitleTracks, Track{
+			Codec:    "text",
+			Language: "und",
+			Name:     name,
+		})
+	}
+
+	return nil
+}
+
+func aviCodecName(fourcc string) string {
+	fourcc = strings.TrimRight(fourcc, "\x00 ")
+	if name, ok := aviCodecs[strings.ToUpper(fourcc)]; ok {
+		return name
+	}
+	return strings.ToLower(fourcc)
+}
+
+// walkRiff calls f for each chunk in data. The body of LIST chunks is
+// passed without the list type, and the list type is used as the id.
+func walkRiff(data []byte, f func(id string, body []byte) error) error {
+	for len(data) >= 8 {
+		id := string(data[0:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if size > len(data) {
+			return fmt.Errorf("truncated riff chunk %s", id)
+		}
+
+		body := data[:size]
+		if id == "LIST" && size >= 4 {
+			id = string(body[0:4])
+			body = body[4:]
+		}
+
+		err := f(id, body)
+		if err != nil {
+			return err
+		}
+
+		if size%2 == 1 && size < len(data) {
+			size++
+		}
+		data = data[size:]
+	}
+	return nil
+}
@@ -0,0 +1,4 @@
+// Package probe reads technical information (duration, resolution, codecs,
+// tracks etc) from the headers of Matroska/WebM, MP4/MOV and AVI video files
+// without decoding them or calling external programs.
+package probe
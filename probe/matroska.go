@@ -0,0 +1,369 @@
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// matroska element ids
+const (
+	mkvSegment         = 0x18538067
+	mkvInfo            = 0x1549a966
+	mkvTimecodeScale   = 0x2ad7b1
+	mkvDuration        = 0x4489
+	mkvTracks          = 0x1654ae6b
+	mkvTrackEntry      = 0xae
+	mkvTrackType       = 0x83
+	mkvCodecID         = 0x86
+	mkvName            = 0x536e
+	mkvLanguage        = 0x22b59c
+	mkvLanguageIETF    = 0x22b59d
+	mkvDefaultDuration = 0x23e383
+	mkvVideo           = 0xe0
+	mkvPixelWidth      = 0xb0
+	mkvPixelHeight     = 0xba
+	mkvCluster         = 0x1f43b675
+)
+
+// matroska track types
+const (
+	mkvTrackVideo    = 1
+	mkvTrackAudio    = 2
+	mkvTrackSubtitle = 17
+)
+
+// mkvCodecs maps matroska codec id prefixes to codec names
+var mkvCodecs = []struct {
+	prefix string
+	name   string
+}{
+	{"V_MPEG4/ISO/AVC", "h264"},
+	{"V_MPEGH/ISO/HEVC", "hevc"},
+	{"V_MPEG4/ISO/", "mpeg4"},
+	{"V_MPEG4/MS/V3", "msmpeg4v3"},
+	{"V_MPEG2", "mpeg2video"},
+	{"V_MPEG1", "mpeg1video"},
+	{"V_VP8", "vp8"},
+	{"V_VP9", "vp9"},
+	{"V_AV1", "av1"},
+	{"V_THEORA", "theora"},
+	{"A_AAC", "aac"},
+	{"A_AC3", "ac3"},
+	{"A_EAC3", "eac3"},
+	{"A_DTS", "dts"},
+	{"A_TRUEHD", "truehd"},
+	{"A_MPEG/L3", "mp3"},
+	{"A_MPEG/L2", "mp2"},
+	{"A_VORBIS", "vorbis"},
+	{"A_OPUS", "opus"},
+	{"A_FLAC", "flac"},
+	{"A_PCM", "pcm"},
+	{"S_TEXT/UTF8", "subrip"},
+	{"S_TEXT/ASCII", "subrip"},
+	{"S_TEXT/ASS", "ass"},
+	{"S_ASS", "ass"},
+	{"S_TEXT/SSA", "ssa"},
+	{"S_SSA", "ssa"},
+	{"S_TEXT/WEBVTT", "webvtt"},
+	{"S_VOBSUB", "dvd_subtitle"},
+	{"S_HDMV/PGS", "hdmv_pgs_subtitle"},
+}
+
+func mkvCodecName(codecID string) string {
+	for _, codec := range mkvCodecs {
+		if strings.HasPrefix(codecID, codec.prefix) {
+			return codec.name
+		}
+	}
+	return strings.ToLower(codecID)
+}
+
+// ebmlElement is the header of an EBML element. A size of -1 means
+// that the size is unknown.
+type ebmlElement struct {
+	id   uint64
+	size int64
+}
+
+// readVint reads an EBML variable-length integer. If keepMarker is true,
+// the length marker bit is not removed (as is the case with element ids).
+func readVint(r io.Reader, keepMarker bool) (uint64, int, error) {
+	first := make([]byte, 1)
+	_, err := io.ReadFull(r, first)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, fmt.Errorf("invalid ebml variable-length integer")
+	}
+
+	value := uint64(first[0])
+	if !keepMarker {
+		value &= uint64(0xff >> uint(length))
+	}
+
+	rest := make([]byte, length-1)
+	_, err = io.ReadFull(r, rest)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length, nil
+}
+
+func readEbmlElement(r io.Reader) (*ebmlElement, error) {
+	id, _, err := readVint(r, true)
+	if err != nil {
+		return nil, err
+	}
+	size, length, err := readVint(r, false)
+	if err != nil {
+		return nil, err
+	}
+
+	element := &ebmlElement{id: id, size: int64(size)}
+	if size == 1<<uint(7*length)-1 { // all ones means unknown size
+		element.size = -1
+	}
+	return element, nil
+}
+
+func ebmlUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+func ebmlString(data []byte) string {
+	return strings.TrimRight(string(data), "\x00")
+}
+
+// walkEbml calls f for each element in data
+func walkEbml(data []byte, f func(id uint64, body []byte) error) error {
+	for len(data) > 0 {
+		r := &byteCounter{data: data}
+		element, err := readEbmlElement(r)
+		if err != nil {
+			return fmt.Errorf("malformed ebml element: %s", err)
+		}
+		data = data[r.read:]
+
+		if element.size < 0 || element.size > int64(len(data)) {
+			element.size = int64(len(data))
+		}
+
+		err = f(element.id, data[:element.size])
+		if err != nil {
+			return err
+		}
+		data = data[element.size:]
+	}
+	return nil
+}
+
+// byteCounter is a reader over a byte slice which remembers how much
+// has been read
+type byteCounter struct {
+	data []byte
+	read int
+}
+
+func (b *byteCounter) Read(p []byte) (int, error) {
+	if b.read >= len(b.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data[b.read:])
+	b.read += n
+	return n, nil
+}
+
+func matroska(r io.ReadSeeker) (*Info, error) {
+	header, err := readEbmlElement(r)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Seek(header.size, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	segment, err := readEbmlElement(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read matroska segment: %s", err)
+	}
+	if segment.id != mkvSegment {
+		return nil, fmt.Errorf("no matroska segment")
+	}
+
+	info := &Info{}
+	var (
+		haveInfo   bool
+		haveTracks bool
+	)
+
+	for !(haveInfo && haveTracks) {
+		element, err := readEbmlElement(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read matroska element: %s", err)
+		}
+
+		switch element.id {
+		case mkvInfo, mkvTracks:
+			if element.size < 0 || element.size > 1<<24 {
+				return nil, fmt.Errorf("matroska header too large")
+			}
+			data := make([]byte, element.size)
+			_, err = io.ReadFull(r, data)
+			if err != nil {
+				return nil, err
+			}
+
+			if element.id == mkvInfo {
+				err = mkvSegmentInfo(info, data)
+				haveInfo = true
+			} else {
+				err = walkEbml(data, func(id uint64, body []byte) error {
+					if id == mkvTrackEntry {
+						return mkvTrack(info, body)
+					}
+					return nil
+				})
+				haveTracks = true
+			}
+			if err != nil {
+				return nil, err
+			}
+		case mkvCluster:
+			// the headers are before the first cluster
+			if !haveTracks {
+				return nil, fmt.Errorf("no tracks in matroska file")
+			}
+			return info, nil
+		default:
+			if element.size < 0 {
+				return nil, fmt.Errorf("unknown-sized matroska element")
+			}
+			_, err = r.Seek(element.size, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return info, nil
+}
+
+func mkvSegmentInfo(info *Info, data []byte) error {
+	var (
+		timecodeScale uint64 = 1000000
+		duration      float64
+	)
+
+	err := walkEbml(data, func(id uint64, body []byte) error {
+		switch id {
+		case mkvTimecodeScale:
+			timecodeScale = ebmlUint(body)
+		case mkvDuration:
+			duration = ebmlFloat(body)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	info.Duration = time.Duration(duration * float64(timecodeScale))
+	return nil
+}
+
+func mkvTrack(info *Info, data []byte) error {
+	var (
+		trackType       uint64
+		defaultDuration uint64
+		width           uint64
+		height          uint64
+		track           = Track{Language: "eng"} // the matroska default
+		languageIETF    string
+	)
+
+	err := walkEbml(data, func(id uint64, body []byte) error {
+		switch id {
+		case mkvTrackType:
+			trackType = ebmlUint(body)
+		case mkvCodecID:
+			track.Codec = mkvCodecName(ebmlString(body))
+		case mkvName:
+			track.Name = ebmlString(body)
+		case mkvLanguage:
+			track.Language = ebmlString(body)
+		case mkvLanguageIETF:
+			languageIETF = ebmlString(body)
+		case mkvDefaultDuration:
+			defaultDuration = ebmlUint(body)
+		case mkvVideo:
+			return walkEbml(body, func(id uint64, body []byte) error {
+				switch id {
+				case mkvPixelWidth:
+					width = ebmlUint(body)
+				case mkvPixelHeight:
+					height = ebmlUint(body)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if languageIETF != "" {
+		track.Language = languageIETF
+	}
+
+	switch trackType {
+	case mkvTrackVideo:
+		if info.VideoCodec != "" {
+			return nil
+		}
+		info.VideoCodec = track.Codec
+		info.Width = uint(width)
+		info.Height = uint(height)
+		if defaultDuration != 0 {
+			info.Framerate = float32(1e9 / float64(defaultDuration))
+		}
+	case mkvTrackAudio:
+		info.addAudioTrack(track, 0)
+	case mkvTrackSubtitle:
+		info.SubtitleTracks = append(info.SubtitleTracks, track)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// mp4Codecs maps sample entry formats to codec names
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"mp4v": "mpeg4",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"dtsc": "dts",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+	"tx3g": "mov_text",
+	"text": "mov_text",
+	"wvtt": "webvtt",
+	"stpp": "ttml",
+	"c608": "eia_608",
+}
+
+// isMp4Box tells whether a box type is likely to start an mp4 file
+func isMp4Box(boxType []byte) bool {
+	switch string(boxType) {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide", "pnot":
+		return true
+	}
+	return false
+}
+
+func mp4(r io.ReadSeeker, size int64) (*Info, error) {
+	var position int64
+	for position < size {
+		boxType, bodySize, headerSize, err := readMp4BoxHeader(r, size-position)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read mp4 box: %s", err)
+		}
+
+		if boxType == "moov" {
+			if bodySize > 1<<26 {
+				return nil, fmt.Errorf("mp4 header too large")
+			}
+			data := make([]byte, bodySize)
+			_, err = io.ReadFull(r, data)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read mp4 header: %s", err)
+			}
+			return mp4Movie(data)
+		}
+
+		_, err = r.Seek(bodySize, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		position += headerSize + bodySize
+	}
+
+	return nil, fmt.Errorf("no moov box in mp4 file")
+}
+
+// readMp4BoxHeader reads a box header and returns the box type and sizes.
+// remaining is used for boxes which extend to the end of the file.
+func readMp4BoxHeader(r io.Reader, remaining int64) (string, int64, int64, error) {
+	header := make([]byte, 8)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	boxType := string(header[4:8])
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	headerSize := int64(8)
+
+	switch size {
+	case 0:
+		size = remaining
+	case 1:
+		_, err = io.ReadFull(r, header)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		size = int64(binary.BigEndian.Uint64(header))
+		headerSize = 16
+	}
+
+	if size < headerSize {
+		return "", 0, 0, fmt.Errorf("invalid size of box %s", boxType)
+	}
+	return boxType, size - headerSize, headerSize, nil
+}
+
+// walkMp4 calls f for each box in data
+func walkMp4(data []byte, f func(boxType string, body []byte) error) error {
+	for len(data) >= 8 {
+		r := &byteCounter{data: data}
+		boxType, size, _, err := readMp4BoxHeader(r, int64(len(data)-8))
+		if err != nil {
+			return err
+		}
+		data = data[r.read:]
+		if size > int64(len(data)) {
+			return fmt.Errorf("truncated mp4 box %s", boxType)
+		}
+
+		err = f(boxType, data[:size])
+		if err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// mp4Track contains the data collected from a trak box
+type mp4Track struct {
+	handler     string
+	format      string
+	language    string
+	name        string
+	timescale   uint32
+	duration    uint64
+	samples     uint64
+	sampleBytes uint64
+	width       uint
+	height      uint
+}
+
+func mp4Movie(data []byte) (*Info, error) {
+	info := &Info{}
+
+	err := walkMp4(data, func(boxType string, body []byte) error {
+		switch boxType {
+		case "mvhd":
+			timescale, duration, err := mp4TimescaleDuration(body, 12, 20)
+			if err != nil {
+				return err
+			}
+			if timescale != 0 {
+				info.Duration = time.Duration(
+					float64(duration) / float64(timescale) * float64(time.Second),
+				)
+			}
+		case "trak":
+			track := &mp4Track{}
+			err := walkMp4Deep(body, track)
+			if err != nil {
+				return err
+			}
+			track.addTo(info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// mp4TimescaleDuration reads the timescale and duration from a full box
+// (mvhd or mdhd) with the given version 0 and version 1 offsets
+func mp4TimescaleDuration(body []byte, offset0 int, offset1 int) (uint32, uint64, error) {
+	if len(body) < 4 {
+		return 0, 0, fmt.Errorf("truncated mp4 header")
+	}
+	if body[0] == 1 {
+		if len(body) < offset1+12 {
+			return 0, 0, fmt.Errorf("truncated mp4 header")
+		}
+		return binary.BigEndian.Uint32(body[offset1 : offset1+4]),
+			binary.BigEndian.Uint64(body[offset1+4 : offset1+12]), nil
+	}
+	if len(body) < offset0+8 {
+		return 0, 0, fmt.Errorf("truncated mp4 header")
+	}
+	return binary.BigEndian.Uint32(body[offset0 : offset0+4]),
+		uint64(binary.BigEndian.Uint32(body[offset0+4 : offset0+8])), nil
+}
+
+// walkMp4Deep collects track data from the boxes inside a trak box
+func walkMp4Deep(data []byte, track *mp4Track) error {
+	return walkMp4(data, func(boxType string, body []byte) error {
+		switch boxType {
+		case "mdia", "minf", "stbl":
+			return walkMp4Deep(body, track)
+		case "mdhd":
+			var err error
+			track.timescale, track.duration, err = mp4TimescaleDuration(body, 12, 20)
+			if err != nil {
+				return err
+			}
+			languageOffset := 20
+			if body[0] == 1 {
+				languageOffset = 32
+			}
+			if len(body) >= languageOffset+2 {
+				track.language = mp4Language(
+					binary.BigEndian.Uint16(body[languageOffset : languageOffset+2]),
+				)
+			}
+		case "hdlr":
+			if len(body) >= 12 {
+				track.handler = string(body[8:12])
+			}
+			if len(body) > 24 {
+				name := strings.TrimRight(string(body[24:]), "\x00")
+				// skip generic names such as "SoundHandler"
+				if !strings.HasSuffix(name, "Handler") {
+					track.name = name
+				}
+			}
+		case "stsd":
+			// version, flags, entry count, then the first sample entry
+			if len(body) >= 16 {
+				track.format = string(body[12:16])
+			}
+			if len(body) >= 8+8+28+4 {
+				entry := body[16:]
+				// reserved, data reference index, pre-defined and
+				// reserved fields come before the dimensions
+				track.width = uint(binary.BigEndian.Uint16(entry[24:26]))
+				track.height = uint(binary.BigEndian.Uint16(entry[26:28]))
+			}
+		case "stsz":
+			if len(body) < 12 {
+				return fmt.Errorf("truncated stsz box")
+			}
+			sampleSize := uint64(binary.BigEndian.Uint32(body[4:8]))
+			track.samples = uint64(binary.BigEndian.Uint32(body[8:12]))
+			if sampleSize != 0 {
+				track.sampleBytes = sampleSize * track.samples
+				return nil
+			}
+			for i := 12; i+4 <= len(body); i += 4 {
+				track.sampleBytes += uint64(binary.BigEndian.Uint32(body[i : i+4]))
+			}
+		}
+		return nil
+	})
+}
+
+// mp4Language decodes a packed ISO 639-2/T language code
+func mp4Language(packed uint16) string {
+	if packed == 0 || packed == 0x7fff {
+		return "und"
+	}
+	return string([]byte{
+		byte(packed>>10&0x1f) + 0x60,
+		byte(packed>>5&0x1f) + 0x60,
+		byte(packed&0x1f) + 0x60,
+	})
+}
+
+func (t *mp4Track) addTo(info *Info) {
+	codec, ok := mp4Codecs[t.format]
+	if !ok {
+		codec = strings.ToLower(strings.TrimSpace(t.format))
+	}
+
+	var (
+		seconds float64
+		bitrate float32
+	)
+	if t.timescale != 0 {
+		seconds = float64(t.duration) / float64(t.timescale)
+	}
+	if seconds > 0 {
+		bitrate = float32(float64(t.sampleBytes*8) / seconds / 1000)
+	}
+
+	switch t.handler {
+	case "vide":
+		if info.VideoCodec != "" {
+			return
+		}
+		info.VideoCodec = codec
+		info.Width = t.width
+		info.Height = t.height
+		info.VideoBitrate = bitrate
+		if seconds > 0 {
+			info.Framerate = float32(float64(t.samples) / seconds)
+		}
+	case "soun":
+		info.addAudioTrack(Track{
+			Codec:    codec,
+			Language: t.language,
+			Name:     t.name,
+		}, bitrate)
+	case "sbtl", "subt", "text", "subp", "clcp":
+		info.SubtitleTracks = append(info.SubtitleTracks, Track{
+			Codec:    codec,
+			Language: t.language,
+			Name:     t.name,
+		})
+	}
+}
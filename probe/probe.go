@@ -0,0 +1,111 @@
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Info contains technical data about a video file. Bitrates are in kbit/s.
+type Info struct {
+	Duration time.Duration
+
+	Width        uint
+	Height       uint
+	VideoCodec   string
+	Framerate    float32
+	VideoBitrate float32
+
+	AudioCodec   string
+	AudioBitrate float32
+
+	AudioTracks    []Track
+	SubtitleTracks []Track
+
+	totalAudioBitrate float32
+}
+
+// Track describes a single audio or subtitle track
+type Track struct {
+	Codec    string
+	Language string
+	Name     string
+}
+
+// File probes the video file with the given name
+func File(filename string) (*Info, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return Reader(f)
+}
+
+// Reader probes the video file which can be read from r
+func Reader(r io.ReadSeeker) (*Info, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, 12)
+	_, err = io.ReadFull(r, magic)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file header: %s", err)
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var info *Info
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		info, err = matroska(r)
+	case bytes.Equal(magic[0:4], []byte("RIFF")) && bytes.Equal(magic[8:12], []byte("AVI ")):
+		info, err = avi(r)
+	case isMp4Box(magic[4:8]):
+		info, err = mp4(r, size)
+	default:
+		return nil, fmt.Errorf("unknown container format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info.estimateVideoBitrate(size)
+	return info, nil
+}
+
+// estimateVideoBitrate calculates the video bitrate from the total file
+// size for containers which don't store it, assuming that the file consists
+// of video and audio data only
+func (i *Info) estimateVideoBitrate(size int64) {
+	if i.VideoBitrate != 0 || i.Duration <= 0 {
+		return
+	}
+
+	total := float32(size*8) / float32(i.Duration.Seconds()) / 1000
+	if total > i.totalAudioBitrate {
+		i.VideoBitrate = total - i.totalAudioBitrate
+	}
+}
+
+func (i *Info) addAudioTrack(track Track, bitrate float32) {
+	if len(i.AudioTracks) == 0 {
+		i.AudioCodec = track.Codec
+		i.AudioBitrate = bitrate
+	}
+	i.totalAudioBitrate += bitrate
+	i.AudioTracks = append(i.AudioTracks, track)
+}
@@ -0,0 +1,217 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvi(t *testing.T) {
+	info, err := File("../importer/fixtures/drop.avi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(uint(256), info.Width)
+	assert.Equal(uint(240), info.Height)
+	assert.Equal("indeo4", info.VideoCodec)
+	assert.InDelta(30, info.Framerate, 0.01)
+	assert.InDelta(887, info.VideoBitrate, 10)
+	assert.InDelta(6.07, info.Duration.Seconds(), 0.01)
+	assert.Equal("", info.AudioCodec)
+	assert.Empty(info.AudioTracks)
+}
+
+// ebml encodes an element with the given id (including its length
+// marker) and body, using an 8-byte size
+func ebml(id uint64, body ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	idBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(idBytes, id)
+	buf.Write(bytes.TrimLeft(idBytes, "\x00"))
+
+	data := bytes.Join(body, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	size[0] = 0x01
+	buf.Write(size)
+
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func ebmlUintBytes(value uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	return data
+}
+
+func TestMatroska(t *testing.T) {
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(1500)) // ms
+
+	file := bytes.Join([][]byte{
+		ebml(0x1a45dfa3, ebml(0x4282, []byte("webm"))),
+		ebml(mkvSegment,
+			ebml(0x114d9b74), // an empty seek head
+			ebml(mkvInfo,
+				ebml(mkvTimecodeScale, ebmlUintBytes(1000000)),
+				ebml(mkvDuration, duration),
+			),
+			ebml(mkvTracks,
+				ebml(mkvTrackEntry,
+					ebml(mkvTrackType, []byte{mkvTrackVideo}),
+					ebml(mkvCodecID, []byte("V_MPEG4/ISO/AVC")),
+					ebml(mkvDefaultDuration, ebmlUintBytes(41708333)),
+					ebml(mkvVideo,
+						ebml(mkvPixelWidth, ebmlUintBytes(1920)),
+						ebml(mkvPixelHeight, ebmlUintBytes(800)),
+					),
+				),
+				ebml(mkvTrackEntry,
+					ebml(mkvTrackType, []byte{mkvTrackAudio}),
+					ebml(mkvCodecID, []byte("A_AC3")),
+					ebml(mkvLanguage, []byte("ger")),
+				),
+				ebml(mkvTrackEntry,
+					ebml(mkvTrackType, []byte{mkvTrackAudio}),
+					ebml(mkvCodecID, []byte("A_DTS")),
+					ebml(mkvName, []byte("Director's commentary")),
+				),
+				ebml(mkvTrackEntry,
+					ebml(mkvTrackType, []byte{mkvTrackSubtitle}),
+					ebml(mkvCodecID, []byte("S_TEXT/UTF8")),
+					ebml(mkvLanguage, []byte("bul")),
+				),
+			),
+			ebml(mkvCluster, make([]byte, 1000)),
+		),
+	}, nil)
+
+	info, err := Reader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(1500*time.Millisecond, info.Duration)
+	assert.Equal(uint(1920), info.Width)
+	assert.Equal(uint(800), info.Height)
+	assert.Equal("h264", info.VideoCodec)
+	assert.InDelta(23.976, info.Framerate, 0.001)
+	assert.Equal("ac3", info.AudioCodec)
+	assert.Equal([]Track{
+		{Codec: "ac3", Language: "ger"},
+		{Codec: "dts", Language: "eng", Name: "Director's commentary"},
+	}, info.AudioTracks)
+	assert.Equal([]Track{
+		{Codec: "subrip", Language: "bul"},
+	}, info.SubtitleTracks)
+}
+
+func box(boxType string, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)+8))
+	copy(header[4:], boxType)
+	return append(header, data...)
+}
+
+func uint32s(values ...uint32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(data[4*i:], value)
+	}
+	return data
+}
+
+func mp4TestTrack(handler string, format string, language uint16, entry []byte, stsz []byte) []byte {
+	mdhd := uint32s(0, 0, 0, 1000, 10000)
+	mdhd = append(mdhd, byte(language>>8), byte(language), 0, 0)
+
+	hdlr := append(uint32s(0, 0), []byte(handler)...)
+	hdlr = append(hdlr, make([]byte, 12)...)
+	hdlr = append(hdlr, []byte("SomeHandler\x00")...)
+
+	return box("trak",
+		box("tkhd", make([]byte, 84)),
+		box("mdia",
+			box("mdhd", mdhd),
+			box("hdlr", hdlr),
+			box("minf",
+				box("stbl",
+					box("stsd", uint32s(0, 1), box(format, entry)),
+					box("stsz", stsz),
+				),
+			),
+		),
+	)
+}
+
+func TestMp4(t *testing.T) {
+	videoEntry := make([]byte, 78)
+	binary.BigEndian.PutUint16(videoEntry[24:], 1280)
+	binary.BigEndian.PutUint16(videoEntry[26:], 720)
+
+	// "eng" and "fre" packed into 15 bits
+	eng := uint16(5<<10 | 14<<5 | 7)
+	fre := uint16(6<<10 | 18<<5 | 5)
+
+	file := bytes.Join([][]byte{
+		box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2avc1mp41")),
+		box("mdat", make([]byte, 5000)),
+		box("moov",
+			box("mvhd", uint32s(0, 0, 0, 600, 6000), make([]byte, 80)),
+			mp4TestTrack("vide", "avc1", 0x55c4, videoEntry,
+				uint32s(0, 1000, 250)), // 250 frames of 1000 bytes
+			mp4TestTrack("soun", "mp4a", eng, make([]byte, 28),
+				uint32s(0, 0, 3, 5000, 5000, 2500)),
+			mp4TestTrack("sbtl", "tx3g", fre, make([]byte, 8),
+				uint32s(0, 0, 0)),
+		),
+	}, nil)
+
+	info, err := Reader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(10*time.Second, info.Duration)
+	assert.Equal(uint(1280), info.Width)
+	assert.Equal(uint(720), info.Height)
+	assert.Equal("h264", info.VideoCodec)
+	assert.InDelta(25, info.Framerate, 0.001)
+	assert.InDelta(200, info.VideoBitrate, 0.001)
+	assert.Equal("aac", info.AudioCodec)
+	assert.InDelta(10, info.AudioBitrate, 0.001)
+	assert.Equal([]Track{{Codec: "aac", Language: "eng"}}, info.AudioTracks)
+	assert.Equal([]Track{{Codec: "mov_text", Language: "fre"}}, info.SubtitleTracks)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := Reader(bytes.NewReader([]byte("this is not a video file")))
+	assert.NotNil(t, err)
+}
+
+func TestMalformedAvi(t *testing.T) {
+	for _, data := range []string{
+		"RIFF....AVI LIST\x00\x00\x00\x00hdrl",
+		"RIFF....AVI LIST\x02\x00\x00\x00hdrl",
+		"RIFF....AVI LIST\x10\x00\x00\x00hdrl",
+	} {
+		_, err := Reader(bytes.NewReader([]byte(data)))
+		assert.NotNil(t, err, "%q", data)
+	}
+
+	_, err := (&riffChunk{id: "hdrl", size: -4}).body(bytes.NewReader(nil))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,99 @@
+package progress
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Counter keeps count of the progress, so that it can be read at any time
+// (e.g. for logging). It's safe for concurrent use.
+type Counter struct {
+	lock    sync.Mutex
+	total   int
+	value   int
+	done    bool
+	started time.Time
+	now     func() time.Time
+}
+
+// NewCounter creates a counter with a 0/0 progress. Rates are measured
+// from the time the counter is created.
+func NewCounter() *Counter {
+	return &Counter{
+		started: time.Now(),
+		now:     time.Now,
+	}
+}
+
+// SetTotal sets the total progress
+func (c *Counter) SetTotal(total int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.total = total
+}
+
+// Set updates the current progress
+func (c *Counter) Set(value int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.value = value
+}
+
+// AddTotal adds to the current total
+func (c *Counter) AddTotal(toTotal int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.total += toTotal
+}
+
+// Add adds to the current value
+func (c *Counter) Add(value int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.value += value
+}
+
+// Done marks the progress as finished
+func (c *Counter) Done() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.done = true
+}
+
+// Get returns the current value and total
+func (c *Counter) Get() (value int, total int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.value, c.total
+}
+
+// String describes the progress with its count, rate and estimated time
+// left, e.g. "12/40 (1.5/s, 19s left)". The total is omitted if it's 0.
+func (c *Counter) String() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	count := fmt.Sprintf("%d", c.value)
+	if c.total > 0 {
+		count = fmt.Sprintf("%d/%d", c.value, c.total)
+	}
+	if c.done {
+		return count + " (done)"
+	}
+
+	elapsed := c.now().Sub(c.started).Seconds()
+	if c.value == 0 || elapsed <= 0 {
+		return count
+	}
+	rate := float64(c.value) / elapsed
+
+	if c.total <= c.value {
+		return fmt.Sprintf("%s (%.1f/s)", count, rate)
+	}
+
+	left := time.Duration(float64(c.total-c.value) / rate * float64(time.Second))
+	return fmt.Sprintf(
+		"%s (%.1f/s, %s left)", count, rate, left.Round(time.Second),
+	)
+}
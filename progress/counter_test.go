@@ -0,0 +1,50 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	counter := NewCounter()
+	now := counter.started
+	counter.now = func() time.Time { return now }
+
+	assert := assert.New(t)
+
+	assert.Equal("0", counter.String())
+
+	counter.AddTotal(40)
+	counter.Add(12)
+	now = now.Add(8 * time.Second)
+	assert.Equal("12/40 (1.5/s, 19s left)", counter.String())
+
+	counter.Set(40)
+	assert.Equal("40/40 (5.0/s)", counter.String())
+
+	counter.Done()
+	assert.Equal("40/40 (done)", counter.String())
+
+	value, total := counter.Get()
+	assert.Equal(40, value)
+	assert.Equal(40, total)
+}
+
+func TestProgressChannelDone(t *testing.T) {
+	p := NewProgressChannel()
+
+	go func() {
+		p.SetTotal(2)
+		p.Add(1)
+		p.Done()
+	}()
+
+	assert.Equal(t, 2, <-p.Total())
+	assert.Equal(t, 1, <-p.Value())
+	<-p.DoneChan()
+
+	_, ok := <-p.Value()
+	assert.False(t, ok)
+}
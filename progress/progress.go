@@ -23,6 +23,7 @@ func NewProgressChannel() *ProgressChannel {
 	return &ProgressChannel{
 		total: make(chan int),
 		value: make(chan int),
+		done:  make(chan struct{}),
 	}
 }
 
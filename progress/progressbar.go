@@ -1,6 +1,10 @@
 package progress
 
-import "github.com/cheggaaa/pb"
+import (
+	"sync/atomic"
+
+	"github.com/cheggaaa/pb"
+)
 
 // ProgressBar is a console progress bar which displays much info
 type ProgressBar struct {
@@ -28,11 +32,11 @@ func StartProgressBar(total int, prefix string) *ProgressBar {
 }
 
 func (p *ProgressBar) SetTotal(max int) {
-	p.Total = int64(max)
+	atomic.StoreInt64(&p.Total, int64(max))
 }
 
 func (p *ProgressBar) AddTotal(toTotal int) {
-	p.Total += int64(toTotal)
+	atomic.AddInt64(&p.Total, int64(toTotal))
 }
 
 func (p *ProgressBar) Done() {
@@ -46,3 +50,36 @@ func (p *ProgressBar) Add(value int) {
 func (p *ProgressBar) Set(value int) {
 	p.ProgressBar.Set(value)
 }
+
+// Pool displays several progress bars, each on its own line
+type Pool struct {
+	pool *pb.Pool
+}
+
+// StartPool makes the progressbars show up. Unlike StartProgressBar, the
+// bars shouldn't be started by themselves.
+func StartPool(bars ...*ProgressBar) (*Pool, error) {
+	pbBars := make([]*pb.ProgressBar, len(bars))
+	for i := range bars {
+		pbBars[i] = &bars[i].ProgressBar
+	}
+	pool := pb.NewPool(pbBars...)
+
+	// bars without a total lose these when they're started, but they
+	// may get a total later
+	for i := range bars {
+		bars[i].ShowPercent = true
+		bars[i].ShowTimeLeft = true
+	}
+
+	err := pool.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &Pool{pool: pool}, nil
+}
+
+// Stop redraws the bars for the last time and stops displaying them
+func (p *Pool) Stop() error {
+	return p.pool.Stop()
+}
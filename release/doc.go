@@ -0,0 +1,4 @@
+// Package release guesses information about a video from its file name,
+// assuming common scene release naming conventions (e.g.
+// Show.Name.S02E05.720p.HDTV.x264-GROUP or Movie.Title.2014.1080p.BluRay)
+package release
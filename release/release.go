@@ -0,0 +1,203 @@
+package release
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Info contains everything that could be guessed from a file name. Fields
+// which couldn't be guessed are left empty.
+type Info struct {
+	Title      string
+	Year       int
+	Season     int
+	Episode    int
+	Resolution string
+	Source     string
+	Group      string
+}
+
+// IsEpisode tells whether the file looks like an episode of a series
+func (i *Info) IsEpisode() bool {
+	return i.Episode != 0
+}
+
+var (
+	separators          = regexp.MustCompile(`[._\s]+`)
+	brackets            = regexp.MustCompile(`[\[\](){}]`)
+	leadingGroupPattern = regexp.MustCompile(`^\[([^\]]+)\]`)
+
+	seasonEpisodePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bs(\d{1,2})\s?e(\d{1,3})`),
+		regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`),
+		regexp.MustCompile(`(?i)\bseason\s?(\d{1,2})\s?episode\s?(\d{1,3})\b`),
+	}
+	episodePattern = regexp.MustCompile(`(?i)\b(?:episode\s?|ep\s?|e)(\d{1,3})\b`)
+	seasonPattern  = regexp.MustCompile(`(?i)\b(?:season\s?|series\s?|s)(\d{1,2})\b`)
+	yearPattern    = regexp.MustCompile(`\b(19[0-9]{2}|20[0-9]{2})\b`)
+	// partPattern matches the part of a multi-part rip (such as cd1). A
+	// "part" separated from its number is left alone, since it's often a
+	// part of the title.
+	partPattern = regexp.MustCompile(`(?i)\b(?:(?:cd|dis[ck])\s?\d{1,2}|part\d{1,2})\b`)
+
+	resolutionPattern = regexp.MustCompile(`(?i)\b(\d{3,4}[pi]|4k|uhd)\b`)
+	groupPattern      = regexp.MustCompile(`-([A-Za-z0-9]+)$`)
+
+	sources = []struct {
+		pattern *regexp.Regexp
+		name    string
+	}{
+		{regexp.MustCompile(`(?i)\b(blu-?ray|bdrip|brrip|bd25|bd50|bdremux)\b`), "BluRay"},
+		{regexp.MustCompile(`(?i)\b(web-?dl|webrip|web)\b`), "WEB"},
+		{regexp.MustCompile(`(?i)\b(hdtv|pdtv|sdtv|dsr|tvrip)\b`), "HDTV"},
+		{regexp.MustCompile(`(?i)\b(dvdrip|dvd-?r|dvd|dvdscr)\b`), "DVD"},
+		{regexp.MustCompile(`(?i)\b(hdrip)\b`), "HDRip"},
+		{regexp.MustCompile(`(?i)\b(cam|hdcam|telesync|hdts)\b`), "CAM"},
+	}
+
+	// junk marks the end of the title if nothing else does
+	junk = regexp.MustCompile(
+		`(?i)\b(x264|x265|h\.?264|h\.?265|hevc|xvid|divx|aac|ac3|dts|` +
+			`proper|repack|extended|unrated|internal|limited|multi|dual)\b`,
+	)
+)
+
+// Parse guesses information about a video from its path. The base name is
+// examined first, and the parent directory is used for anything missing.
+func Parse(path string) *Info {
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+
+	info := parseName(name)
+
+	parentName := filepath.Base(filepath.Dir(path))
+	if parentName == "." || parentName == "/" {
+		return info
+	}
+	parent := parseName(parentName)
+
+	if info.Title == "" {
+		info.Title = parent.Title
+	}
+	if info.Year == 0 {
+		info.Year = parent.Year
+	}
+	if info.Season == 0 && info.Episode != 0 {
+		info.Season = parent.Season
+	}
+	if info.Resolution == "" {
+		info.Resolution = parent.Resolution
+	}
+	if info.Source == "" {
+		info.Source = parent.Source
+	}
+	if info.Group == "" {
+		info.Group = parent.Group
+	}
+
+	return info
+}
+
+// parseName parses a single file or directory name without extension
+func parseName(name string) *Info {
+	info := &Info{}
+
+	if groups := leadingGroupPattern.FindStringSubmatch(name); groups != nil {
+		info.Group = groups[1]
+		name = strings.TrimPrefix(name, groups[0])
+	}
+
+	name = brackets.ReplaceAllString(name, " ")
+	name = strings.TrimSpace(separators.ReplaceAllString(name, " "))
+
+	// titleEnd is the position of the first thing that isn't a part of
+	// the title
+	titleEnd := len(name)
+	markEnd := func(position int) {
+		if position < titleEnd {
+			titleEnd = position
+		}
+	}
+
+	for _, pattern := range seasonEpisodePatterns {
+		if location := pattern.FindStringSubmatchIndex(name); location != nil {
+			info.Season, _ = strconv.Atoi(name[location[2]:location[3]])
+			info.Episode, _ = strconv.Atoi(name[location[4]:location[5]])
+			markEnd(location[0])
+			break
+		}
+	}
+
+	if info.Episode == 0 {
+		if location := episodePattern.FindStringSubmatchIndex(name); location != nil {
+			info.Episode, _ = strconv.Atoi(name[location[2]:location[3]])
+			markEnd(location[0])
+		}
+	}
+
+	if info.Episode == 0 {
+		if location := seasonPattern.FindStringSubmatchIndex(name); location != nil {
+			info.Season, _ = strconv.Atoi(name[location[2]:location[3]])
+			markEnd(location[0])
+		}
+	}
+
+	// the last year-like number is the year, unless it's at the very
+	// beginning, in which case it's the title (as in "2012.2009.720p")
+	years := yearPattern.FindAllStringIndex(name, -1)
+	if len(years) > 0 && years[len(years)-1][0] > 0 {
+		location := years[len(years)-1]
+		info.Year, _ = strconv.Atoi(name[location[0]:location[1]])
+		markEnd(location[0])
+	}
+
+	if location := resolutionPattern.FindStringIndex(name); location != nil {
+		info.Resolution = strings.ToLower(name[location[0]:location[1]])
+		markEnd(location[0])
+	}
+
+	sourceEnd := 0
+	for _, source := range sources {
+		if location := source.pattern.FindStringIndex(name); location != nil {
+			info.Source = source.name
+			sourceEnd = location[1]
+			markEnd(location[0])
+			break
+		}
+	}
+
+	if location := partPattern.FindStringIndex(name); location != nil {
+		markEnd(location[0])
+	}
+
+	if location := junk.FindStringIndex(name); location != nil {
+		markEnd(location[0])
+	}
+
+	// a trailing -GROUP is only a release group if it comes after the
+	// title (otherwise it's a part of a title such as "Spider-Man") and
+	// isn't a part of the source (as in "WEB-DL")
+	if location := groupPattern.FindStringSubmatchIndex(name); location != nil {
+		if location[0] >= titleEnd && location[1] > sourceEnd && info.Group == "" {
+			info.Group = name[location[2]:location[3]]
+		}
+	}
+
+	info.Title = strings.Trim(name[:titleEnd], " -")
+
+	return info
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^\pL\pN]+`)
+
+// TitleWords normalises a title for comparing it with other titles, and
+// splits it into words. The words are lowercase, without punctuation, and
+// "&" is turned into "and".
+func TitleWords(title string) []string {
+	title = strings.ToLower(title)
+	title = strings.Replace(title, "&", " and ", -1)
+	title = strings.Replace(title, "'", "", -1)
+	return strings.Fields(nonAlphanumeric.ReplaceAllString(title, " "))
+}
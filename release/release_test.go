@@ -0,0 +1,74 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		path string
+		info Info
+	}{
+		{
+			"Show.Name.S02E05.720p.HDTV.x264-LOL.mkv",
+			Info{Title: "Show Name", Season: 2, Episode: 5, Resolution: "720p", Source: "HDTV", Group: "LOL"},
+		},
+		{
+			"Movie.Title.2014.1080p.BluRay.x264-SPARKS.mkv",
+			Info{Title: "Movie Title", Year: 2014, Resolution: "1080p", Source: "BluRay", Group: "SPARKS"},
+		},
+		{
+			"/media/Some Show/Season 3/Some Show - 3x07 - Episode Name.avi",
+			Info{Title: "Some Show", Season: 3, Episode: 7},
+		},
+		{
+			"/media/Stalker (1979)/stalker.cd1.avi",
+			Info{Title: "stalker", Year: 1979},
+		},
+		{
+			"Kill.Bill.2003.DVDRip.XviD.part2.avi",
+			Info{Title: "Kill Bill", Year: 2003, Source: "DVD"},
+		},
+		{
+			"Some.Movie.Disc.2.mkv",
+			Info{Title: "Some Movie"},
+		},
+		{
+			"/media/The Wire/Season 2/The.Wire.E05.mkv",
+			Info{Title: "The Wire", Season: 2, Episode: 5},
+		},
+		{
+			"2012.2009.720p.WEB-DL.mkv",
+			Info{Title: "2012", Year: 2009, Resolution: "720p", Source: "WEB"},
+		},
+		{
+			"Blade.Runner.2049.2017.2160p.UHD.BluRay.mkv",
+			Info{Title: "Blade Runner 2049", Year: 2017, Resolution: "2160p", Source: "BluRay"},
+		},
+		{
+			"Spider-Man.mp4",
+			Info{Title: "Spider-Man"},
+		},
+		{
+			"[HorribleSubs] Some Anime S01E12 [1080p].mkv",
+			Info{Title: "Some Anime", Season: 1, Episode: 12, Resolution: "1080p", Group: "HorribleSubs"},
+		},
+		{
+			"Ocean's 11.avi",
+			Info{Title: "Ocean's 11"},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.info, *Parse(c.path), c.path)
+	}
+}
+
+func TestTitleWords(t *testing.T) {
+	assert.Equal(t, []string{"law", "and", "order"}, TitleWords("Law & Order"))
+	assert.Equal(t, []string{"oceans", "11"}, TitleWords("Ocean's 11"))
+	assert.Equal(t, []string{"spider", "man"}, TitleWords("Spider-Man"))
+	assert.Equal(t, 0, len(TitleWords(" - ")))
+}
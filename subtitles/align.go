@@ -0,0 +1,189 @@
+package subtitles
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Alignment is how a subtitle's times have to be adjusted to match the
+// speech in a video (first scaled by Factor, then shifted by Offset)
+type Alignment struct {
+	Factor float64
+	Offset time.Duration
+	// Score tells how well the adjusted cues match the speech: from 0 for
+	// cues placed no better than at random, to 1 for speech during all cues
+	Score float64
+}
+
+// alignmentFactors are the time factors of the common frame rate
+// conversions, with no conversion first, since it's the most likely
+var alignmentFactors = []float64{
+	1,
+	25 / 23.976, 23.976 / 25,
+	25 / 24, 24 / 25,
+	24 / 23.976, 23.976 / 24,
+}
+
+// coarseAlignmentStep is the resolution with which offsets are searched
+// before finding the exact one
+const coarseAlignmentStep = 100 * time.Millisecond
+
+// Align finds the frame rate conversion and the offset (up to maxOffset
+// in either direction) with which the subtitle's cues best match the
+// speech. The speech tells for each frame of the given duration of the
+// audio whether there's speech in it.
+func Align(
+	subtitle *Subtitle,
+	speech []bool,
+	frame time.Duration,
+	maxOffset time.Duration,
+) (*Alignment, error) {
+	var speechFrames int
+	for i := range speech {
+		if speech[i] {
+			speechFrames++
+		}
+	}
+	if speechFrames == 0 {
+		return nil, fmt.Errorf("there's no speech in the audio")
+	}
+	if len(subtitle.Cues) == 0 {
+		return nil, fmt.Errorf("the subtitle has no cues")
+	}
+
+	step := int(coarseAlignmentStep / frame)
+	if step < 1 {
+		step = 1
+	}
+	coarseSpeech := downsample(speech, step)
+	maxCoarseOffset := int(maxOffset/frame) / step
+
+	var (
+		best          *Alignment
+		bestCueFrames []int
+		bestRatio     float64
+		bestOffset    int
+	)
+	for _, factor := range alignmentFactors {
+		cueFrames := subtitle.cueFrames(factor, frame)
+		if len(cueFrames) == 0 {
+			continue
+		}
+
+		coarseCues := make([]float64, cueFrames[len(cueFrames)-1]/step+1)
+		for _, i := range cueFrames {
+			coarseCues[i/step] += 1 / float64(step)
+		}
+
+		offset, overlap := bestOverlap(coarseCues, coarseSpeech, maxCoarseOffset)
+		overlap /= float64(len(cueFrames))
+		if best == nil || overlap > bestRatio {
+			best = &Alignment{Factor: factor}
+			bestCueFrames, bestRatio, bestOffset = cueFrames, overlap, offset*step
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("the subtitle's cues are empty")
+	}
+
+	// find the exact offset around the coarse one
+	matching := overlapCount(bestCueFrames, speech, bestOffset)
+	best.Offset = time.Duration(bestOffset) * frame
+	for offset := bestOffset - step; offset <= bestOffset+step; offset++ {
+		if count := overlapCount(bestCueFrames, speech, offset); count > matching {
+			best.Offset = time.Duration(offset) * frame
+			matching = count
+		}
+	}
+
+	// a subtitle placed at random matches as much speech as there is
+	// speech in the audio, so the score is how much better it matches
+	density := float64(speechFrames) / float64(len(speech))
+	precision := float64(matching) / float64(len(bestCueFrames))
+	if density < 1 {
+		best.Score = math.Max(0, (precision-density)/(1-density))
+	}
+
+	return best, nil
+}
+
+// cueFrames returns the indices of the frames during which a cue is shown,
+// when the subtitle's times are scaled by the factor
+func (s *Subtitle) cueFrames(factor float64, frame time.Duration) []int {
+	var frames []int
+	last := -1
+	for _, cue := range s.Cues {
+		start := int(math.Floor(float64(cue.Start) * factor / float64(frame)))
+		end := int(math.Ceil(float64(cue.End) * factor / float64(frame)))
+		if start <= last {
+			// cues overlap, and the frames are already counted
+			start = last + 1
+		}
+		if start < 0 {
+			start = 0
+		}
+
+		for i := start; i < end; i++ {
+			frames = append(frames, i)
+			last = i
+		}
+	}
+	return frames
+}
+
+// downsample returns the fraction of true values in each group of step
+// values
+func downsample(values []bool, step int) []float64 {
+	result := make([]float64, (len(values)+step-1)/step)
+	for i := range values {
+		if values[i] {
+			result[i/step] += 1 / float64(step)
+		}
+	}
+	return result
+}
+
+// bestOverlap finds the offset (up to maxOffset in either direction) with
+// which the cues overlap the speech the most, preferring smaller offsets
+func bestOverlap(cues []float64, speech []float64, maxOffset int) (int, float64) {
+	var nonzero []int
+	for i := range cues {
+		if cues[i] > 0 {
+			nonzero = append(nonzero, i)
+		}
+	}
+
+	offsets := []int{0}
+	for distance := 1; distance <= maxOffset; distance++ {
+		offsets = append(offsets, -distance, distance)
+	}
+
+	var bestOffset int
+	best := -1.0
+	for _, offset := range offsets {
+		var overlap float64
+		for _, i := range nonzero {
+			if j := i + offset; j >= 0 && j < len(speech) {
+				overlap += cues[i] * speech[j]
+			}
+		}
+		if overlap > best {
+			bestOffset, best = offset, overlap
+		}
+	}
+	return bestOffset, best
+}
+
+// overlapCount counts the cue frames during which there's speech when the
+// cues are shifted by the offset
+func overlapCount(cueFrames []int, speech []bool, offset int) int {
+	var count int
+	for _, i := range cueFrames {
+		if j := i + offset; j >= 0 && j < len(speech) && speech[j] {
+			count++
+		}
+	}
+	return count
+}
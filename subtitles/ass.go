@@ -0,0 +1,189 @@
+package subtitles
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// defaultEventFormat is used for events sections without a format line
+var defaultEventFormat = []string{
+	"layer", "start", "end", "style", "name",
+	"marginl", "marginr", "marginv", "effect", "text",
+}
+
+var (
+	overrideBlockRegex = regexp.MustCompile(`\{[^}]*\}`)
+	overrideTagRegex   = regexp.MustCompile(`\\([ibu])(\d+)`)
+	assLineBreaks      = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ")
+)
+
+// parseASS reads the dialogue events of ASS and SSA scripts. Styles and
+// positioning are ignored.
+func parseASS(text string) (*Subtitle, error) {
+	subtitle := &Subtitle{}
+	format := defaultEventFormat
+	inEvents := false
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		key, value := line[:colon], strings.TrimSpace(line[colon+1:])
+
+		switch key {
+		case "Format":
+			format = strings.Split(strings.ToLower(value), ",")
+			for i := range format {
+				format[i] = strings.TrimSpace(format[i])
+			}
+		case "Dialogue":
+			cue, err := parseASSDialogue(value, format)
+			if err != nil {
+				return nil, err
+			}
+			subtitle.Cues = append(subtitle.Cues, cue)
+		}
+	}
+
+	subtitle.sortCues()
+	return subtitle, nil
+}
+
+func parseASSDialogue(line string, format []string) (Cue, error) {
+	// the text is last, and can contain commas
+	values := strings.SplitN(line, ",", len(format))
+	if len(values) < len(format) {
+		return Cue{}, fmt.Errorf("invalid dialogue line: %s", line)
+	}
+
+	fields := make(map[string]string)
+	for i := range format {
+		fields[format[i]] = values[i]
+	}
+
+	start, err := parseTimestamp(fields["start"])
+	if err != nil {
+		return Cue{}, err
+	}
+	end, err := parseTimestamp(fields["end"])
+	if err != nil {
+		return Cue{}, err
+	}
+
+	return Cue{
+		Start: start,
+		End:   end,
+		Text:  assToText(fields["text"]),
+	}, nil
+}
+
+// assToText converts italic, bold and underline override tags to
+// SRT-style tags, removing all other overrides. Tags left open are
+// closed at the end of the text.
+func assToText(assText string) string {
+	open := make(map[string]bool)
+
+	text := overrideBlockRegex.ReplaceAllStringFunc(assText, func(block string) string {
+		result := &strings.Builder{}
+		for _, match := range overrideTagRegex.FindAllStringSubmatch(block, -1) {
+			name, enabled := match[1], match[2] != "0"
+			if enabled != open[name] {
+				result.WriteString(basicTag(name, !enabled))
+				open[name] = enabled
+			}
+		}
+		return result.String()
+	})
+
+	for _, name := range []string{"u", "b", "i"} {
+		if open[name] {
+			text += basicTag(name, true)
+		}
+	}
+
+	return assLineBreaks.Replace(text)
+}
+
+// textToASS converts SRT-style tags to override tags
+func textToASS(text string) string {
+	text = mapTags(
+		removeEmptyLines(text),
+		func(name string, closing bool) string {
+			if closing {
+				return `{\` + name + `0}`
+			}
+			return `{\` + name + `1}`
+		},
+		plainText,
+	)
+	return strings.Replace(text, "\n", `\N`, -1)
+}
+
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+PlayResX: 384
+PlayResY: 288
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+const ssaHeader = `[Script Info]
+ScriptType: v4.00
+PlayResX: 384
+PlayResY: 288
+
+[V4 Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, TertiaryColour, BackColour, Bold, Italic, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, AlphaLevel, Encoding
+Style: Default,Arial,20,16777215,255,0,0,0,0,1,2,2,2,10,10,10,0,1
+
+[Events]
+Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// writeASS writes an ASS script (or an SSA one if ssa is true) in which
+// all cues have the default style
+func writeASS(w io.Writer, subtitle *Subtitle, ssa bool) error {
+	header, layer := assHeader, "0"
+	if ssa {
+		header, layer = ssaHeader, "Marked=0"
+	}
+
+	_, err := io.WriteString(w, header)
+	if err != nil {
+		return err
+	}
+
+	for _, cue := range subtitle.Cues {
+		_, err = fmt.Fprintf(
+			w, "Dialogue: %s,%s,%s,Default,,0,0,0,,%s\n",
+			layer,
+			formatTimestamp(cue.Start, 1, ".", 2),
+			formatTimestamp(cue.End, 1, ".", 2),
+			textToASS(cue.Text),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package subtitles
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DexterLB/mvm/types"
+	"github.com/saintfish/chardet"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// defaultEncoding is assumed when the encoding of a subtitle can't be
+// detected at all
+const defaultEncoding = "windows-1252"
+
+// legacyEncodings are the code pages in which subtitles for each language
+// usually come, the most common one first (with their WHATWG names, which
+// is why there are no latin-1 and latin-5: they're the same as windows-1252
+// and windows-1254)
+var legacyEncodings = map[string][]string{
+	"bg": {"windows-1251", "iso-8859-5"},
+	"ru": {"windows-1251", "koi8-r", "iso-8859-5", "ibm866"},
+	"uk": {"windows-1251", "koi8-u"},
+	"be": {"windows-1251"},
+	"mk": {"windows-1251"},
+	"sr": {"windows-1251", "windows-1250"},
+
+	"cs": {"windows-1250", "iso-8859-2"},
+	"sk": {"windows-1250", "iso-8859-2"},
+	"pl": {"windows-1250", "iso-8859-2"},
+	"hu": {"windows-1250", "iso-8859-2"},
+	"sl": {"windows-1250", "iso-8859-2"},
+	"hr": {"windows-1250", "iso-8859-2"},
+	"bs": {"windows-1250", "iso-8859-2"},
+	"ro": {"windows-1250", "iso-8859-2", "iso-8859-16"},
+	"sq": {"windows-1250", "iso-8859-2"},
+
+	"el": {"windows-1253", "iso-8859-7"},
+	"tr": {"windows-1254"},
+	"he": {"windows-1255", "iso-8859-8"},
+	"ar": {"windows-1256", "iso-8859-6"},
+	"fa": {"windows-1256"},
+	"ur": {"windows-1256"},
+	"et": {"windows-1257", "iso-8859-13"},
+	"lv": {"windows-1257", "iso-8859-13"},
+	"lt": {"windows-1257", "iso-8859-13"},
+	"vi": {"windows-1258"},
+	"th": {"windows-874"},
+
+	"ja": {"shift_jis", "euc-jp", "iso-2022-jp"},
+	"zh": {"gb18030", "big5"},
+	"ko": {"euc-kr"},
+}
+
+// DetectEncoding returns the name of the character encoding of a subtitle.
+// The subtitle's language (if known) is a strong hint: only the encodings
+// usually used for the language are considered. Languages which aren't
+// known to use special code pages are assumed to be in windows-1252.
+func DetectEncoding(data []byte, language types.Language) string {
+	if validUTF8(data) {
+		return "utf-8"
+	}
+
+	results, _ := chardet.NewTextDetector().DetectAll(data)
+	detected := make([]string, 0, len(results))
+	for i := range results {
+		if name, err := encodingName(results[i].Charset); err == nil {
+			detected = append(detected, name)
+		}
+	}
+
+	for _, name := range detected {
+		if strings.HasPrefix(name, "utf-16") {
+			// byte order marks are stronger than any hint
+			return name
+		}
+	}
+
+	candidates := legacyEncodings[language.ISO2()]
+	if candidates == nil && language != (types.Language{}) {
+		candidates = []string{defaultEncoding}
+	}
+
+	if len(candidates) == 0 {
+		if len(detected) > 0 {
+			return detected[0]
+		}
+		return defaultEncoding
+	}
+
+	// the detector's results are sorted by confidence
+	for _, name := range detected {
+		for _, candidate := range candidates {
+			if name == candidate {
+				return name
+			}
+		}
+	}
+	return candidates[0]
+}
+
+// ToUTF8 converts data in the given encoding to UTF-8
+func ToUTF8(data []byte, encoding string) ([]byte, error) {
+	if encoding == "utf-8" {
+		return data, nil
+	}
+
+	enc, err := htmlindex.Get(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %s", encoding)
+	}
+
+	converted, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert from %s: %s", encoding, err)
+	}
+	return converted, nil
+}
+
+// encodingName returns the canonical (lowercase, WHATWG) name of an encoding
+func encodingName(name string) (string, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return htmlindex.Name(enc)
+}
+
+// validUTF8 tells whether data is valid UTF-8, allowing it to end in the
+// middle of a character, since it might be just the beginning of a file
+func validUTF8(data []byte) bool {
+	for i := 0; i < utf8.UTFMax && i <= len(data); i++ {
+		tail := data[len(data)-i:]
+		if (i == 0 || !utf8.FullRune(tail)) && utf8.Valid(data[:len(data)-i]) {
+			return true
+		}
+	}
+	return false
+}
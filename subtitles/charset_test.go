@@ -0,0 +1,94 @@
+package subtitles
+
+import (
+	"testing"
+
+	"github.com/DexterLB/mvm/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	bulgarianText = "Какво правиш тук? Не знам какво да ти кажа.\n" +
+		"Аз съм тук, но той не е. Това не е за мен, ще се видим утре.\n"
+	russianText = "Что ты здесь делаешь? Я не знаю, что тебе сказать.\n" +
+		"Мы все были там, но его не было. Это не для меня, увидимся завтра.\n"
+	polishText = "Co tu robisz? Nie wiem, co ci powiedzieć.\n" +
+		"Jestem tutaj, ale jego nie ma. Zobaczymy się jutro, źle się czuję.\n"
+	greekText = "Τι κάνεις εδώ; Δεν ξέρω τι να σου πω.\n" +
+		"Είμαι εδώ, αλλά αυτός δεν είναι. Θα τα πούμε αύριο.\n"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	encode := func(enc encoding.Encoding, text string) []byte {
+		data, err := enc.NewEncoder().Bytes([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	for _, test := range []struct {
+		data     []byte
+		language string
+		expected string
+	}{
+		{[]byte(bulgarianText), "bg", "utf-8"},
+		{[]byte(bulgarianText)[:20], "bg", "utf-8"},
+		{encode(charmap.Windows1251, bulgarianText), "bg", "windows-1251"},
+		{encode(charmap.Windows1251, russianText), "ru", "windows-1251"},
+		{encode(charmap.KOI8R, russianText), "ru", "koi8-r"},
+		{encode(charmap.Windows1250, polishText), "pl", "windows-1250"},
+		// the text has no characters which differ between the two
+		{encode(charmap.Windows1253, greekText), "el", "iso-8859-7"},
+		{encode(charmap.Windows1252, "Ça va très bien"), "fr", "windows-1252"},
+		{encode(charmap.Windows1251, russianText), "", "windows-1251"},
+	} {
+		language := types.Language{}
+		if test.language != "" {
+			language = types.MustParseLanguage(test.language)
+		}
+
+		assert.Equal(
+			test.expected,
+			DetectEncoding(test.data, language),
+			"%s text in %s", test.language, test.expected,
+		)
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	assert := assert.New(t)
+
+	data, err := charmap.Windows1251.NewEncoder().Bytes([]byte(bulgarianText))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, err := ToUTF8(data, "windows-1251")
+	assert.Nil(err)
+	assert.Equal(bulgarianText, string(converted))
+
+	converted, err = ToUTF8([]byte(bulgarianText), "utf-8")
+	assert.Nil(err)
+	assert.Equal(bulgarianText, string(converted))
+
+	_, err = ToUTF8(data, "foo")
+	assert.NotNil(err)
+}
+
+func TestLegacyEncodingNames(t *testing.T) {
+	for language, encodings := range legacyEncodings {
+		for _, name := range encodings {
+			canonical, err := encodingName(name)
+			if err != nil {
+				t.Errorf("unknown encoding %s for %s", name, language)
+			} else if canonical != name {
+				t.Errorf("%s for %s should be called %s", name, language, canonical)
+			}
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package subtitles parses and writes subtitles in the SRT, WebVTT, ASS/SSA
+// and MicroDVD formats, so that they can be converted between formats,
+// and detects and converts their character encodings.
+//
+// Parsed subtitles are a list of cues, whose text keeps only the basic
+// formatting (italic, bold and underline) as SRT-style tags, since that's
+// what all formats can represent.
+package subtitles
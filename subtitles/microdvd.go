@@ -0,0 +1,162 @@
+package subtitles
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	microDVDRegex        = regexp.MustCompile(`^\{(\d+)\}\{(\d*)\}(.*)$`)
+	microDVDControlRegex = regexp.MustCompile(`\{([a-zA-Z]):([^}]*)\}`)
+)
+
+// microDVDDuration is how long cues without an end frame are shown
+const microDVDDuration = 3 * time.Second
+
+// parseMicroDVD reads a frame-based MicroDVD subtitle. Many of them start
+// with a cue at frame 1 whose text is the frame rate, and it takes
+// precedence over the given one.
+func parseMicroDVD(text string, framerate float64) (*Subtitle, error) {
+	subtitle := &Subtitle{}
+
+	for _, line := range strings.Split(text, "\n") {
+		match := microDVDRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		start, _ := strconv.Atoi(match[1])
+		end, _ := strconv.Atoi(match[2])
+
+		if len(subtitle.Cues) == 0 && start <= 1 && end <= 1 {
+			if rate, err := strconv.ParseFloat(strings.TrimSpace(match[3]), 64); err == nil && rate > 0 {
+				framerate = rate
+				continue
+			}
+		}
+
+		if framerate <= 0 {
+			return nil, fmt.Errorf("unknown frame rate for MicroDVD subtitle")
+		}
+
+		cue := Cue{
+			Start: frameTime(start, framerate),
+			Text:  microDVDToText(match[3]),
+		}
+		if match[2] == "" {
+			cue.End = cue.Start + microDVDDuration
+		} else {
+			cue.End = frameTime(end, framerate)
+		}
+		subtitle.Cues = append(subtitle.Cues, cue)
+	}
+
+	subtitle.sortCues()
+	return subtitle, nil
+}
+
+// microDVDToText converts the text of a MicroDVD cue, whose lines are
+// separated by "|". Styles are set by control codes such as {y:i}, which
+// affects the line it's on, and {Y:i}, which affects all lines.
+func microDVDToText(microDVDText string) string {
+	var cueStyles string
+
+	lines := strings.Split(microDVDText, "|")
+	for i := range lines {
+		var lineStyles string
+		lines[i] = microDVDControlRegex.ReplaceAllStringFunc(lines[i], func(control string) string {
+			match := microDVDControlRegex.FindStringSubmatch(control)
+			switch match[1] {
+			case "y":
+				lineStyles += strings.ToLower(match[2])
+			case "Y":
+				cueStyles += strings.ToLower(match[2])
+			}
+			return ""
+		})
+
+		lines[i] = styleLine(lines[i], cueStyles+lineStyles)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// styleLine wraps the line in the tags for the given MicroDVD styles
+func styleLine(line string, styles string) string {
+	for _, name := range []string{"u", "b", "i"} {
+		if strings.Contains(styles, name) {
+			line = basicTag(name, false) + line + basicTag(name, true)
+		}
+	}
+	return line
+}
+
+// textToMicroDVD converts a cue's text to MicroDVD. Since styles can only
+// be applied to whole lines, a line gets all styles used anywhere on it.
+func textToMicroDVD(text string) string {
+	lines := strings.Split(removeEmptyLines(text), "\n")
+	for i := range lines {
+		var styles string
+		line := mapTags(
+			lines[i],
+			func(name string, closing bool) string {
+				if !closing && !strings.Contains(styles, name) {
+					styles += name
+				}
+				return ""
+			},
+			plainText,
+		)
+
+		if styles != "" {
+			line = "{y:" + styles + "}" + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "|")
+}
+
+func writeMicroDVD(w io.Writer, subtitle *Subtitle, framerate float64) error {
+	if framerate <= 0 {
+		return fmt.Errorf("unknown frame rate for MicroDVD subtitle")
+	}
+
+	// frame rates are usually float32s, so they need a bit of rounding
+	rate := strconv.FormatFloat(math.Round(framerate*1000)/1000, 'f', -1, 64)
+	_, err := fmt.Fprintf(w, "{1}{1}%s\n", rate)
+	if err != nil {
+		return err
+	}
+
+	for _, cue := range subtitle.Cues {
+		_, err = fmt.Fprintf(
+			w, "{%d}{%d}%s\n",
+			timeFrame(cue.Start, framerate),
+			timeFrame(cue.End, framerate),
+			textToMicroDVD(cue.Text),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frameTime returns the time (in whole milliseconds) at which the given
+// frame is shown
+func frameTime(frame int, framerate float64) time.Duration {
+	return time.Duration(math.Round(float64(frame)/framerate*1000)) * time.Millisecond
+}
+
+// timeFrame returns the frame shown at the given time
+func timeFrame(t time.Duration, framerate float64) int {
+	if t < 0 {
+		return 0
+	}
+	return int(math.Round(t.Seconds() * framerate))
+}
@@ -0,0 +1,91 @@
+package subtitles
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var cueIndexRegex = regexp.MustCompile(`^\s*\d+\s*$`)
+
+// parseCues reads cues which consist of a timing line, followed by lines
+// of text until an empty line. This is how both SRT and WebVTT cues look.
+func parseCues(text string) (*Subtitle, error) {
+	subtitle := &Subtitle{}
+	lines := strings.Split(text, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		match := timingLineRegex.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+
+		start, err := parseTimestamp(match[1])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTimestamp(match[2])
+		if err != nil {
+			return nil, err
+		}
+
+		var textLines []string
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			if timingLineRegex.MatchString(lines[i+1]) {
+				break
+			}
+			if cueIndexRegex.MatchString(lines[i+1]) &&
+				i+2 < len(lines) && timingLineRegex.MatchString(lines[i+2]) {
+				// a missing empty line between cues
+				break
+			}
+
+			i++
+			textLines = append(textLines, lines[i])
+		}
+
+		subtitle.Cues = append(subtitle.Cues, Cue{
+			Start: start,
+			End:   end,
+			Text:  strings.Join(textLines, "\n"),
+		})
+	}
+
+	return subtitle, nil
+}
+
+func parseSRT(text string) (*Subtitle, error) {
+	subtitle, err := parseCues(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range subtitle.Cues {
+		subtitle.Cues[i].Text = mapTags(subtitle.Cues[i].Text, basicTag, removeOverrides)
+	}
+	return subtitle, nil
+}
+
+var overrideRegex = regexp.MustCompile(`\{\\[^}]*\}`)
+
+// removeOverrides removes ASS override blocks, which some SRT files have
+func removeOverrides(text string) string {
+	return overrideRegex.ReplaceAllString(text, "")
+}
+
+func writeSRT(w io.Writer, subtitle *Subtitle) error {
+	for i, cue := range subtitle.Cues {
+		_, err := fmt.Fprintf(
+			w, "%d\n%s --> %s\n%s\n\n",
+			i+1,
+			formatTimestamp(cue.Start, 2, ",", 3),
+			formatTimestamp(cue.End, 2, ",", 3),
+			removeEmptyLines(cue.Text),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
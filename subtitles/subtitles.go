@@ -0,0 +1,287 @@
+package subtitles
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a subtitle file format, named after its usual file extension
+type Format string
+
+// Supported subtitle formats
+const (
+	SRT      Format = "srt"
+	WebVTT   Format = "vtt"
+	ASS      Format = "ass"
+	SSA      Format = "ssa"
+	MicroDVD Format = "sub"
+)
+
+// Formats lists all supported formats
+var Formats = []Format{SRT, WebVTT, ASS, SSA, MicroDVD}
+
+var formatNames = map[string]Format{
+	"srt":      SRT,
+	"subrip":   SRT,
+	"vtt":      WebVTT,
+	"webvtt":   WebVTT,
+	"ass":      ASS,
+	"ssa":      SSA,
+	"sub":      MicroDVD,
+	"microdvd": MicroDVD,
+}
+
+// ParseFormat parses a format from its name or file extension
+// (e.g. "srt", ".vtt" or "webvtt")
+func ParseFormat(name string) (Format, error) {
+	format, ok := formatNames[strings.ToLower(strings.TrimPrefix(name, "."))]
+	if !ok {
+		return "", fmt.Errorf("unknown subtitle format: %s", name)
+	}
+	return format, nil
+}
+
+// UnmarshalText parses a format from raw text
+func (f *Format) UnmarshalText(text []byte) error {
+	format, err := ParseFormat(string(text))
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// Cue is a piece of text which is shown for a period of time
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	// Text can have several lines, and <i>, <b> and <u> tags
+	Text string
+}
+
+// Subtitle is a list of cues
+type Subtitle struct {
+	Cues []Cue
+}
+
+var (
+	microDVDLineRegex = regexp.MustCompile(`^\{\d+\}\{\d*\}`)
+	timingLineRegex   = regexp.MustCompile(`^\s*(\S+)\s*-->\s*(\S+)`)
+)
+
+// DetectFormat guesses the format of a subtitle from its contents
+func DetectFormat(data []byte) (Format, error) {
+	text := normaliseText(data)
+	trimmed := strings.TrimSpace(text)
+
+	switch {
+	case strings.HasPrefix(trimmed, "WEBVTT"):
+		return WebVTT, nil
+	case strings.Contains(text, "[V4+ Styles]") || strings.Contains(text, "v4.00+"):
+		return ASS, nil
+	case strings.Contains(text, "[Events]"):
+		return SSA, nil
+	case microDVDLineRegex.MatchString(trimmed):
+		return MicroDVD, nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if timingLineRegex.MatchString(line) {
+			return SRT, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown subtitle format")
+}
+
+// Parse reads a subtitle in the given format. The frame rate is needed
+// only for MicroDVD subtitles which don't specify one themselves.
+func Parse(data []byte, format Format, framerate float64) (*Subtitle, error) {
+	text := normaliseText(data)
+
+	var (
+		subtitle *Subtitle
+		err      error
+	)
+
+	switch format {
+	case SRT:
+		subtitle, err = parseSRT(text)
+	case WebVTT:
+		subtitle, err = parseWebVTT(text)
+	case ASS, SSA:
+		subtitle, err = parseASS(text)
+	case MicroDVD:
+		subtitle, err = parseMicroDVD(text, framerate)
+	default:
+		return nil, fmt.Errorf("unknown subtitle format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subtitle.Cues) == 0 {
+		return nil, fmt.Errorf("no %s subtitle cues found", format)
+	}
+	return subtitle, nil
+}
+
+// Write writes the subtitle in the given format. The frame rate is needed
+// only for MicroDVD subtitles.
+func (s *Subtitle) Write(w io.Writer, format Format, framerate float64) error {
+	switch format {
+	case SRT:
+		return writeSRT(w, s)
+	case WebVTT:
+		return writeWebVTT(w, s)
+	case ASS:
+		return writeASS(w, s, false)
+	case SSA:
+		return writeASS(w, s, true)
+	case MicroDVD:
+		return writeMicroDVD(w, s, framerate)
+	default:
+		return fmt.Errorf("unknown subtitle format: %s", format)
+	}
+}
+
+// Convert converts a subtitle from one format to another
+func Convert(data []byte, from Format, to Format, framerate float64) ([]byte, error) {
+	subtitle, err := Parse(data, from, framerate)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	err = subtitle.Write(buf, to, framerate)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sortCues orders the cues by their start time
+func (s *Subtitle) sortCues() {
+	sort.SliceStable(s.Cues, func(i, j int) bool {
+		return s.Cues[i].Start < s.Cues[j].Start
+	})
+}
+
+// normaliseText removes the byte order mark and uses \n for line endings
+func normaliseText(data []byte) string {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	return strings.Replace(text, "\r", "\n", -1)
+}
+
+var timestampRegex = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{1,2})(?:[,.](\d+))?$`)
+
+// parseTimestamp parses timestamps such as 01:02:03,456 (SRT),
+// 02:03.456 (WebVTT) and 1:02:03.45 (ASS)
+func parseTimestamp(text string) (time.Duration, error) {
+	match := timestampRegex.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return 0, fmt.Errorf("invalid timestamp: %s", text)
+	}
+
+	var parts [3]int
+	for i := range parts {
+		if match[i+1] != "" {
+			parts[i], _ = strconv.Atoi(match[i+1])
+		}
+	}
+
+	// the fraction is in whatever precision the format uses
+	fraction := (match[4] + "000")[:3]
+	milliseconds, _ := strconv.Atoi(fraction)
+
+	return time.Duration(parts[0])*time.Hour +
+		time.Duration(parts[1])*time.Minute +
+		time.Duration(parts[2])*time.Second +
+		time.Duration(milliseconds)*time.Millisecond, nil
+}
+
+// formatTimestamp formats a timestamp as hours:minutes:seconds, followed
+// by the separator and the given number of fraction digits (up to 3)
+func formatTimestamp(d time.Duration, hourDigits int, separator string, fractionDigits int) string {
+	if d < 0 {
+		d = 0
+	}
+
+	precision := time.Millisecond
+	for i := fractionDigits; i < 3; i++ {
+		precision *= 10
+	}
+	d = d.Round(precision)
+
+	return fmt.Sprintf(
+		"%0*d:%02d:%02d%s%0*d",
+		hourDigits, int(d/time.Hour),
+		int(d/time.Minute)%60,
+		int(d/time.Second)%60,
+		separator,
+		fractionDigits, int(d%time.Second/precision),
+	)
+}
+
+var tagRegex = regexp.MustCompile(`<(/?)([a-zA-Z]+)[^>]*>`)
+
+// basicTags are the formatting tags which are kept in the cues' text
+var basicTags = map[string]bool{"i": true, "b": true, "u": true}
+
+// mapTags rewrites the text of a cue by replacing each basic tag
+// with the result of tag, and each piece of text between tags with the
+// result of text. Other tags are removed.
+func mapTags(
+	cueText string,
+	tag func(name string, closing bool) string,
+	text func(string) string,
+) string {
+	result := &strings.Builder{}
+
+	last := 0
+	for _, match := range tagRegex.FindAllStringSubmatchIndex(cueText, -1) {
+		result.WriteString(text(cueText[last:match[0]]))
+		last = match[1]
+
+		name := strings.ToLower(cueText[match[4]:match[5]])
+		if basicTags[name] {
+			result.WriteString(tag(name, match[3] > match[2]))
+		}
+	}
+	result.WriteString(text(cueText[last:]))
+
+	return result.String()
+}
+
+// basicTag writes the tag as is
+func basicTag(name string, closing bool) string {
+	if closing {
+		return "</" + name + ">"
+	}
+	return "<" + name + ">"
+}
+
+// plainText leaves text as is
+func plainText(text string) string {
+	return text
+}
+
+// removeEmptyLines removes the empty lines in a cue's text, since in most
+// formats they mark the end of the cue
+func removeEmptyLines(text string) string {
+	lines := strings.Split(text, "\n")
+	result := lines[:0]
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			result = append(result, line)
+		}
+	}
+	return strings.Join(result, "\n")
+}
@@ -0,0 +1,306 @@
+package subtitles
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ms(milliseconds int) time.Duration {
+	return time.Duration(milliseconds) * time.Millisecond
+}
+
+var testCues = []Cue{
+	{Start: ms(1000), End: ms(3500), Text: "Hello there!"},
+	{Start: ms(4000), End: ms(6250), Text: "<i>Two lines,</i>\nand <b>bold</b> & <u>underline</u>"},
+	{Start: ms(3723450), End: ms(3725000), Text: "Over an hour"},
+}
+
+const testSRT = `1
+00:00:01,000 --> 00:00:03,500
+Hello there!
+
+2
+00:00:04,000 --> 00:00:06,250
+<i>Two lines,</i>
+and <b>bold</b> & <u>underline</u>
+
+3
+01:02:03,450 --> 01:02:05,000
+Over an hour
+
+`
+
+const testWebVTT = `WEBVTT
+
+00:00:01.000 --> 00:00:03.500
+Hello there!
+
+00:00:04.000 --> 00:00:06.250
+<i>Two lines,</i>
+and <b>bold</b> &amp; <u>underline</u>
+
+01:02:03.450 --> 01:02:05.000
+Over an hour
+
+`
+
+const testMicroDVD = `{1}{1}25
+{25}{88}Hello there!
+{100}{156}{y:i}Two lines,|{y:bu}and bold & underline
+{93086}{93125}Over an hour
+`
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		format Format
+		data   string
+		cues   []Cue
+	}{
+		{SRT, testSRT, testCues},
+		{WebVTT, testWebVTT, testCues},
+		{SRT, "\ufeff1\r\n00:00:01,000 --> 00:00:03,500\r\nHello there!\r\n2\r\n00:00:04,000 --> 00:00:06,250\r\n{\\an8}<font color=\"red\">Two</font>\r\n", []Cue{
+			{Start: ms(1000), End: ms(3500), Text: "Hello there!"},
+			{Start: ms(4000), End: ms(6250), Text: "Two"},
+		}},
+		{WebVTT, `WEBVTT - a title
+
+NOTE a comment
+
+STYLE
+::cue { color: red }
+
+intro
+00:01.000 --> 00:03.500 align:start line:0
+<v Bob>Hello <c.loud>there</c>!</v>
+`, []Cue{
+			{Start: ms(1000), End: ms(3500), Text: "Hello there!"},
+		}},
+		{ASS, `[Script Info]
+ScriptType: v4.00+
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Comment: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,a comment
+Dialogue: 0,0:00:04.00,0:00:06.25,Default,,0,0,0,,{\i1}Two lines,{\i0}\Nand {\b1}bold{\b0} & {\u1\pos(10,10)}underline
+Dialogue: 0,0:00:01.00,0:00:03.50,Default,,0,0,0,,{\an8}Hello there!
+Dialogue: 0,1:02:03.45,1:02:05.00,Default,,0,0,0,,Over an hour
+`, testCues},
+		{SSA, `[Events]
+Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: Marked=0,0:00:01.00,0:00:03.50,Default,,0000,0000,0000,,Hello, there!
+`, []Cue{
+			{Start: ms(1000), End: ms(3500), Text: "Hello, there!"},
+		}},
+		{MicroDVD, testMicroDVD, []Cue{
+			{Start: ms(1000), End: ms(3520), Text: "Hello there!"},
+			{Start: ms(4000), End: ms(6240), Text: "<i>Two lines,</i>\n<b><u>and bold & underline</u></b>"},
+			{Start: ms(3723440), End: ms(3725000), Text: "Over an hour"},
+		}},
+		{MicroDVD, "{0}{50}{Y:i}Hello|there!\n", []Cue{
+			{Start: ms(0), End: ms(2000), Text: "<i>Hello</i>\n<i>there!</i>"},
+		}},
+	} {
+		subtitle, err := Parse([]byte(test.data), test.format, 25)
+		if err != nil {
+			t.Errorf("unable to parse %s: %s", test.format, err)
+			continue
+		}
+		assert.Equal(t, test.cues, subtitle.Cues, string(test.format))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse([]byte("{25}{50}Hello"), MicroDVD, 0)
+	assert.NotNil(t, err)
+
+	_, err = Parse([]byte("Hello"), SRT, 0)
+	assert.NotNil(t, err)
+
+	_, err = Parse([]byte("00:00:01.000 --> 00:00:02.000\nHello"), WebVTT, 0)
+	assert.NotNil(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	subtitle := &Subtitle{Cues: testCues}
+
+	for _, test := range []struct {
+		format   Format
+		expected string
+	}{
+		{SRT, testSRT},
+		{WebVTT, testWebVTT},
+		{MicroDVD, testMicroDVD},
+	} {
+		buf := &bytes.Buffer{}
+		err := subtitle.Write(buf, test.format, 25)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, buf.String(), string(test.format))
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		data, err := Convert([]byte(testSRT), SRT, format, 23.976)
+		if err != nil {
+			t.Errorf("unable to convert to %s: %s", format, err)
+			continue
+		}
+
+		detected, err := DetectFormat(data)
+		assert.Nil(t, err)
+		assert.Equal(t, format, detected)
+
+		subtitle, err := Parse(data, format, 0)
+		if err != nil {
+			t.Errorf("unable to parse converted %s: %s", format, err)
+			continue
+		}
+
+		assert.Equal(t, len(testCues), len(subtitle.Cues))
+		for i := range subtitle.Cues {
+			assert.InDelta(t, testCues[i].Start, subtitle.Cues[i].Start, float64(ms(25)), string(format))
+			assert.InDelta(t, testCues[i].End, subtitle.Cues[i].End, float64(ms(25)), string(format))
+		}
+		if format != MicroDVD {
+			// MicroDVD can only style whole lines
+			assert.Equal(t, testCues[1].Text, subtitle.Cues[1].Text, string(format))
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, expected := range map[string]Format{
+		"srt":    SRT,
+		".VTT":   WebVTT,
+		"webvtt": WebVTT,
+		"ass":    ASS,
+		"ssa":    SSA,
+		"sub":    MicroDVD,
+	} {
+		format, err := ParseFormat(name)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, format)
+	}
+
+	_, err := ParseFormat("smi")
+	assert.NotNil(t, err)
+}
+
+func TestTimestamps(t *testing.T) {
+	for text, expected := range map[string]time.Duration{
+		"01:02:03,456": ms(3723456),
+		"02:03.456":    ms(123456),
+		"1:02:03.45":   ms(3723450),
+		"00:00:01.5":   ms(1500),
+		"00:00:01":     ms(1000),
+	} {
+		d, err := parseTimestamp(text)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, d, text)
+	}
+
+	assert.Equal(t, "01:02:03,456", formatTimestamp(ms(3723456), 2, ",", 3))
+	assert.Equal(t, "1:02:03.46", formatTimestamp(ms(3723456), 1, ".", 2))
+	assert.Equal(t, "00:00:00.000", formatTimestamp(-ms(500), 2, ".", 3))
+}
+
+func TestShiftAndScale(t *testing.T) {
+	subtitle := &Subtitle{Cues: append([]Cue{}, testCues...)}
+
+	subtitle.Shift(-ms(2000))
+	assert.Equal(t, []Cue{
+		{Start: ms(0), End: ms(1500), Text: testCues[0].Text},
+		{Start: ms(2000), End: ms(4250), Text: testCues[1].Text},
+		{Start: ms(3721450), End: ms(3723000), Text: testCues[2].Text},
+	}, subtitle.Cues)
+
+	subtitle.Shift(-ms(2000))
+	assert.Equal(t, 2, len(subtitle.Cues))
+	assert.Equal(t, ms(0), subtitle.Cues[0].Start)
+
+	subtitle = &Subtitle{Cues: append([]Cue{}, testCues...)}
+	subtitle.Retime(25, 23.976)
+	assert.Equal(t, ms(1043), subtitle.Cues[0].Start)
+	assert.Equal(t, ms(3882476), subtitle.Cues[2].Start)
+}
+
+func TestAdjustTiming(t *testing.T) {
+	data, err := AdjustTiming([]byte(testSRT), SRT, 0, 2, ms(500))
+	assert.Nil(t, err)
+	subtitle, err := Parse(data, SRT, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, Cue{Start: ms(2500), End: ms(7500), Text: "Hello there!"}, subtitle.Cues[0])
+
+	data, err = AdjustTiming([]byte(testMicroDVD), MicroDVD, 25, 1, ms(1000))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "{50}{113}Hello there!\n")
+
+	ass := "[Script Info]\r\nTitle: 0:00:01.00\r\n\r\n[Events]\r\n" +
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\r\n" +
+		"Dialogue: 0,0:00:01.00,0:00:03.50,Fancy,,0,0,0,,{\\an8}Hello, there!\r\n"
+	data, err = AdjustTiming([]byte(ass), ASS, 0, 1, -ms(1500))
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Replace(
+		ass, "0:00:01.00,0:00:03.50", "0:00:00.00,0:00:02.00", 1,
+	), string(data))
+}
+
+func TestAlign(t *testing.T) {
+	frame := 10 * time.Millisecond
+	random := rand.New(rand.NewSource(42))
+
+	// ten minutes of speech at random times, and a subtitle whose
+	// cues are a bit longer than each line
+	speech := make([]bool, 60000)
+	synced := &Subtitle{}
+	for start := 100; start < len(speech)-1000; start += 200 + random.Intn(400) {
+		length := 100 + random.Intn(200)
+		for i := start; i < start+length; i++ {
+			speech[i] = true
+		}
+		synced.Cues = append(synced.Cues, Cue{
+			Start: time.Duration(start)*frame - ms(100),
+			End:   time.Duration(start+length)*frame + ms(200),
+		})
+	}
+
+	alignment, err := Align(synced, speech, frame, 30*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, alignment.Factor)
+	assert.Equal(t, time.Duration(0), alignment.Offset)
+	assert.True(t, alignment.Score > 0.6, "score is %f", alignment.Score)
+
+	// a subtitle for a 25 fps release, which is 2.5s late
+	unsynced := &Subtitle{Cues: append([]Cue{}, synced.Cues...)}
+	unsynced.Shift(ms(2500))
+	unsynced.Retime(23.976, 25)
+
+	alignment, err = Align(unsynced, speech, frame, 30*time.Second)
+	assert.Nil(t, err)
+	assert.InDelta(t, 25/23.976, alignment.Factor, 0.0001)
+	assert.InDelta(t, -ms(2500), alignment.Offset, float64(frame))
+	assert.True(t, alignment.Score > 0.6, "score is %f", alignment.Score)
+
+	// a subtitle for something else entirely
+	random = rand.New(rand.NewSource(7))
+	other := &Subtitle{}
+	for start := 100; start < len(speech)-1000; start += 200 + random.Intn(400) {
+		other.Cues = append(other.Cues, Cue{
+			Start: time.Duration(start) * frame,
+			End:   time.Duration(start+100+random.Intn(200)) * frame,
+		})
+	}
+	alignment, err = Align(other, speech, frame, 30*time.Second)
+	assert.Nil(t, err)
+	assert.True(t, alignment.Score < 0.3, "score is %f", alignment.Score)
+
+	_, err = Align(synced, make([]bool, 100), frame, time.Second)
+	assert.NotNil(t, err)
+	_, err = Align(&Subtitle{}, speech, frame, time.Second)
+	assert.NotNil(t, err)
+}
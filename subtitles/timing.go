@@ -0,0 +1,128 @@
+package subtitles
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"time"
+)
+
+// Shift moves all cues by the offset, which can be negative. Cues which
+// would end before the beginning are removed, and ones which would start
+// before it start at the beginning.
+func (s *Subtitle) Shift(offset time.Duration) {
+	cues := s.Cues[:0]
+	for _, cue := range s.Cues {
+		cue.Start += offset
+		cue.End += offset
+
+		if cue.End <= 0 {
+			continue
+		}
+		if cue.Start < 0 {
+			cue.Start = 0
+		}
+		cues = append(cues, cue)
+	}
+	s.Cues = cues
+}
+
+// Scale multiplies the times of all cues by the factor
+func (s *Subtitle) Scale(factor float64) {
+	for i := range s.Cues {
+		s.Cues[i].Start = scaleTime(s.Cues[i].Start, factor)
+		s.Cues[i].End = scaleTime(s.Cues[i].End, factor)
+	}
+}
+
+// Retime converts a subtitle which matches a video with one frame rate
+// to match the same video with another one (e.g. a subtitle for a 25 fps
+// PAL release to a 23.976 fps one, which is a bit slower)
+func (s *Subtitle) Retime(from float64, to float64) {
+	s.Scale(from / to)
+}
+
+// AdjustTiming scales the times in a subtitle by the factor and then
+// shifts them by the offset, keeping the subtitle's format. ASS and SSA
+// scripts are changed in place, so that their styles are kept.
+func AdjustTiming(
+	data []byte,
+	format Format,
+	framerate float64,
+	factor float64,
+	offset time.Duration,
+) ([]byte, error) {
+	if format == ASS || format == SSA {
+		return adjustASSTiming(data, factor, offset)
+	}
+
+	subtitle, err := Parse(data, format, framerate)
+	if err != nil {
+		return nil, err
+	}
+	subtitle.Scale(factor)
+	subtitle.Shift(offset)
+
+	buf := &bytes.Buffer{}
+	err = subtitle.Write(buf, format, framerate)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// adjustASSTiming changes only the start and end times of the events
+// in an ASS or SSA script
+func adjustASSTiming(data []byte, factor float64, offset time.Duration) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	format := defaultEventFormat
+	inEvents := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "[") {
+			inEvents = strings.EqualFold(trimmed, "[Events]")
+			continue
+		}
+
+		colon := strings.Index(trimmed, ":")
+		if !inEvents || colon < 0 {
+			continue
+		}
+		key, value := trimmed[:colon], strings.TrimSpace(trimmed[colon+1:])
+
+		switch key {
+		case "Format":
+			format = strings.Split(strings.ToLower(value), ",")
+			for j := range format {
+				format[j] = strings.TrimSpace(format[j])
+			}
+		case "Dialogue", "Comment":
+			values := strings.SplitN(value, ",", len(format))
+			for j := range values {
+				if format[j] != "start" && format[j] != "end" {
+					continue
+				}
+
+				t, err := parseTimestamp(values[j])
+				if err != nil {
+					return nil, err
+				}
+				values[j] = formatTimestamp(scaleTime(t, factor)+offset, 1, ".", 2)
+			}
+
+			lines[i] = key + ": " + strings.Join(values, ",")
+			if strings.HasSuffix(line, "\r") {
+				lines[i] += "\r"
+			}
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// scaleTime multiplies the time by the factor, rounding to milliseconds
+func scaleTime(t time.Duration, factor float64) time.Duration {
+	return time.Duration(math.Round(t.Seconds()*factor*1000)) * time.Millisecond
+}
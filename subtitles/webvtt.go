@@ -0,0 +1,56 @@
+package subtitles
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	webVTTUnescaper = strings.NewReplacer(
+		"&amp;", "&", "&lt;", "<", "&gt;", ">",
+		"&nbsp;", "\u00a0", "&lrm;", "\u200e", "&rlm;", "\u200f",
+	)
+	webVTTEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+func parseWebVTT(text string) (*Subtitle, error) {
+	if !strings.HasPrefix(strings.TrimSpace(text), "WEBVTT") {
+		return nil, fmt.Errorf("missing WEBVTT header")
+	}
+
+	// comments, styles and regions can't contain "-->", so they are
+	// skipped just like the cue identifiers
+	subtitle, err := parseCues(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range subtitle.Cues {
+		// voice, class and timestamp tags are removed
+		subtitle.Cues[i].Text = mapTags(
+			subtitle.Cues[i].Text, basicTag, webVTTUnescaper.Replace,
+		)
+	}
+	return subtitle, nil
+}
+
+func writeWebVTT(w io.Writer, subtitle *Subtitle) error {
+	_, err := fmt.Fprintf(w, "WEBVTT\n\n")
+	if err != nil {
+		return err
+	}
+
+	for _, cue := range subtitle.Cues {
+		_, err = fmt.Fprintf(
+			w, "%s --> %s\n%s\n\n",
+			formatTimestamp(cue.Start, 2, ".", 3),
+			formatTimestamp(cue.End, 2, ".", 3),
+			mapTags(removeEmptyLines(cue.Text), basicTag, webVTTEscaper.Replace),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
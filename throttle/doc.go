@@ -0,0 +1,8 @@
+// Package throttle limits the rate of requests to remote services and
+// retries the ones which fail temporarily, waiting a jittered exponential
+// backoff between attempts. It provides a http.RoundTripper for plain HTTP
+// clients and a generic Do for other kinds of calls (such as XML-RPC).
+//
+// When a service says that our quota is exhausted, the limiter pauses all
+// requests to it for a while, instead of failing them.
+package throttle
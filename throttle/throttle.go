@@ -0,0 +1,188 @@
+package throttle
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Policy describes how requests to a service are limited and retried
+type Policy struct {
+	// RequestsPerSecond is the maximum rate of requests (0 means unlimited)
+	RequestsPerSecond float64
+	// MaxRetries is the number of times a temporarily failed request is
+	// retried before giving up
+	MaxRetries int
+	// InitialBackoff is the approximate delay before the first retry. It is
+	// doubled for each subsequent retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// QuotaPause is how long all requests wait after the service reports
+	// that our quota is exhausted
+	QuotaPause time.Duration
+}
+
+// DefaultPolicy doesn't limit the rate of requests, and retries failed
+// requests 4 times within about half a minute
+var DefaultPolicy = Policy{
+	MaxRetries:     4,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	QuotaPause:     10 * time.Minute,
+}
+
+// Backoff returns the delay before the given retry (starting from 1).
+// The delay is randomised between half and all of the exponential backoff,
+// so that parallel clients don't retry all at once.
+func (p *Policy) Backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < retry && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// Class tells how a failed request should be handled
+type Class int
+
+const (
+	// Permanent errors are returned immediately
+	Permanent Class = iota
+	// Temporary errors are retried after a backoff
+	Temporary
+	// QuotaExceeded errors pause all requests, and are then retried
+	// indefinitely
+	QuotaExceeded
+)
+
+// ErrStopped is returned when waiting is interrupted by closing the stop
+// channel
+var ErrStopped = errors.New("stopped while waiting to retry")
+
+// Classify treats network timeouts as temporary and all other errors as
+// permanent
+func Classify(err error) Class {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return Temporary
+	}
+	return Permanent
+}
+
+// Limiter spaces requests to a service according to its policy. It is
+// safe for concurrent use, and should be shared by all clients of the
+// service.
+type Limiter struct {
+	Policy Policy
+
+	lock sync.Mutex
+	// next is the earliest time for the next request
+	next time.Time
+	now  func() time.Time
+}
+
+// NewLimiter creates a limiter with the given policy
+func NewLimiter(policy Policy) *Limiter {
+	return &Limiter{
+		Policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a request can be made. It returns false if the stop
+// channel is closed before that.
+func (l *Limiter) Wait(stop <-chan struct{}) bool {
+	l.lock.Lock()
+	now := l.now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	if l.Policy.RequestsPerSecond > 0 {
+		l.next = start.Add(
+			time.Duration(float64(time.Second) / l.Policy.RequestsPerSecond),
+		)
+	}
+	l.lock.Unlock()
+
+	return sleep(start.Sub(now), stop)
+}
+
+// Pause makes all requests wait for the given duration
+func (l *Limiter) Pause(duration time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	until := l.now().Add(duration)
+	if until.After(l.next) {
+		l.next = until
+	}
+}
+
+// Do calls f (a single request) until it succeeds, its error is
+// permanent, or it has been retried MaxRetries times. Quota errors pause
+// the limiter for QuotaPause and are retried until the stop channel is
+// closed.
+func (l *Limiter) Do(
+	stop <-chan struct{},
+	classify func(error) Class,
+	f func() error,
+) error {
+	retries := 0
+	for {
+		if !l.Wait(stop) {
+			return ErrStopped
+		}
+
+		err := f()
+		if err == nil {
+			return nil
+		}
+
+		switch classify(err) {
+		case QuotaExceeded:
+			l.Pause(l.Policy.QuotaPause)
+		case Temporary:
+			retries++
+			if retries > l.Policy.MaxRetries {
+				return err
+			}
+			if !sleep(l.Policy.Backoff(retries), stop) {
+				return ErrStopped
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// sleep waits for the duration, returning false if stop is closed first
+func sleep(duration time.Duration, stop <-chan struct{}) bool {
+	if duration <= 0 {
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
@@ -0,0 +1,223 @@
+package throttle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := &Policy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+	}
+
+	for retry, max := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 10 * time.Second,
+		9: 10 * time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			backoff := policy.Backoff(retry)
+			assert.True(t, backoff >= max/2 && backoff <= max,
+				"backoff %s for retry %d", backoff, retry)
+		}
+	}
+
+	assert.Equal(t, time.Duration(0), (&Policy{}).Backoff(3))
+}
+
+func TestLimiterWait(t *testing.T) {
+	limiter := NewLimiter(Policy{RequestsPerSecond: 50})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.True(t, limiter.Wait(nil))
+	}
+	elapsed := time.Since(start)
+
+	assert.True(t, elapsed >= 75*time.Millisecond, "elapsed %s", elapsed)
+
+	stop := make(chan struct{})
+	close(stop)
+	limiter.Pause(time.Hour)
+	assert.False(t, limiter.Wait(stop))
+}
+
+func TestLimiterDo(t *testing.T) {
+	limiter := NewLimiter(Policy{
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		QuotaPause:     time.Millisecond,
+	})
+
+	classify := func(err error) Class {
+		switch {
+		case strings.Contains(err.Error(), "503"):
+			return Temporary
+		case strings.Contains(err.Error(), "407"):
+			return QuotaExceeded
+		default:
+			return Permanent
+		}
+	}
+
+	assert := assert.New(t)
+
+	calls := 0
+	err := limiter.Do(nil, classify, func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("503 Service Unavailable")
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(3, calls)
+
+	calls = 0
+	err = limiter.Do(nil, classify, func() error {
+		calls++
+		return fmt.Errorf("503 Service Unavailable")
+	})
+	assert.Equal("503 Service Unavailable", err.Error())
+	assert.Equal(3, calls)
+
+	calls = 0
+	err = limiter.Do(nil, classify, func() error {
+		calls++
+		return fmt.Errorf("401 Unauthorized")
+	})
+	assert.Equal("401 Unauthorized", err.Error())
+	assert.Equal(1, calls)
+
+	// quota errors are retried for as long as they happen
+	calls = 0
+	err = limiter.Do(nil, classify, func() error {
+		calls++
+		if calls < 10 {
+			return fmt.Errorf("407 Download limit reached")
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(10, calls)
+
+	stop := make(chan struct{})
+	limiter.Policy.QuotaPause = time.Hour
+	calls = 0
+	err = limiter.Do(stop, classify, func() error {
+		calls++
+		close(stop)
+		return fmt.Errorf("407 Download limit reached")
+	})
+	assert.Equal(ErrStopped, err)
+	assert.Equal(1, calls)
+}
+
+func TestTransport(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			switch {
+			case r.URL.Path == "/flaky" && requests < 3:
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+			case r.URL.Path == "/throttled" && requests < 2:
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, "slow down", http.StatusTooManyRequests)
+			case r.URL.Path == "/missing":
+				http.NotFound(w, r)
+			default:
+				body, _ := ioutil.ReadAll(r.Body)
+				fmt.Fprintf(w, "ok %s", body)
+			}
+		},
+	))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			Base: server.Client().Transport,
+			Limiter: NewLimiter(Policy{
+				MaxRetries:     3,
+				InitialBackoff: time.Millisecond,
+			}),
+		},
+	}
+
+	assert := assert.New(t)
+
+	get := func(path string) (int, string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("/flaky")
+	assert.Equal(200, status)
+	assert.Equal("ok ", body)
+	assert.Equal(3, requests)
+
+	requests = 0
+	status, _ = get("/throttled")
+	assert.Equal(200, status)
+	assert.Equal(2, requests)
+
+	requests = 0
+	status, _ = get("/missing")
+	assert.Equal(404, status)
+	assert.Equal(1, requests)
+
+	requests = 0
+	resp, err := client.Post(server.URL+"/flaky", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal("ok body", string(data))
+	assert.Equal(3, requests)
+
+	// retries don't replace the body of the caller's request
+	requests = 0
+	request, err := http.NewRequest("POST", server.URL+"/flaky", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := request.Body
+	resp, err = client.Transport.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(200, resp.StatusCode)
+	assert.Equal(3, requests)
+	assert.True(original == request.Body)
+
+	// a body which can't be sent again isn't retried
+	requests = 0
+	request, err = http.NewRequest(
+		"POST", server.URL+"/flaky", ioutil.NopCloser(strings.NewReader("body")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Transport.RoundTrip(request)
+	assert.NotNil(err)
+	assert.Equal(1, requests)
+}
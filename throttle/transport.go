@@ -0,0 +1,128 @@
+package throttle
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport is a http.RoundTripper which limits the rate of requests and
+// retries the ones which fail with a timeout, a server error (5xx) or
+// 429 Too Many Requests.
+type Transport struct {
+	// Base makes the actual requests (nil means http.DefaultTransport)
+	Base    http.RoundTripper
+	Limiter *Limiter
+}
+
+// RoundTrip performs the request, retrying it if needed. Each attempt is
+// made with a copy of the request, so the caller's request isn't changed.
+// Requests with a body can only be retried if they have GetBody set.
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	stop := request.Context().Done()
+	policy := &t.Limiter.Policy
+
+	for retry := 1; ; retry++ {
+		if !t.Limiter.Wait(stop) {
+			return nil, request.Context().Err()
+		}
+
+		attempt, err := attemptRequest(request, retry)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base().RoundTrip(attempt)
+
+		lastAttempt := retry > policy.MaxRetries
+		if err != nil {
+			if lastAttempt || Classify(err) != Temporary {
+				return nil, err
+			}
+		} else {
+			if lastAttempt || !retryableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		if !rewindable(request) {
+			if err == nil {
+				err = fmt.Errorf("%s", resp.Status)
+			}
+			return nil, fmt.Errorf(
+				"unable to retry request (%s): its body can't be rewound", err,
+			)
+		}
+
+		delay := policy.Backoff(retry)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = after
+				// everyone else should wait too
+				t.Limiter.Pause(after)
+			}
+		}
+		if !sleep(delay, stop) {
+			return nil, request.Context().Err()
+		}
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// rewindable tells if the request's body can be sent again
+func rewindable(request *http.Request) bool {
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+// attemptRequest returns a copy of the request to send on the given
+// attempt. Retries get a fresh body from GetBody.
+func attemptRequest(request *http.Request, retry int) (*http.Request, error) {
+	attempt := request.Clone(request.Context())
+	if retry == 1 || request.Body == nil || request.Body == http.NoBody {
+		return attempt, nil
+	}
+	if request.GetBody == nil {
+		return nil, fmt.Errorf("unable to retry request: its body can't be rewound")
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("unable to rewind request body: %s", err)
+	}
+	attempt.Body = body
+	return attempt, nil
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or a date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
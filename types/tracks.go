@@ -0,0 +1,44 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Track describes an audio or subtitle track embedded in a video file
+type Track struct {
+	Codec    string `json:"codec"`
+	Language string `json:"language"`
+	Name     string `json:"name"`
+}
+
+// Tracks is an instance of []Track which implements the SQL Valuer and
+// Scanner interfaces, so it can be stored in a database.
+// Its SQL type should be blob.
+type Tracks []Track
+
+// Scan deserialises the object from raw database data
+func (t *Tracks) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		var result Tracks
+		err := json.Unmarshal(data, &result)
+		if err != nil {
+			return fmt.Errorf("unable to parse tracks: %s", err)
+		}
+		*t = result
+	default:
+		return fmt.Errorf("unknown type for tracks")
+	}
+	return nil
+}
+
+// Value serialises the object to raw database data
+func (t Tracks) Value() (driver.Value, error) {
+	data, err := json.Marshal(&t)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise tracks: %s", err)
+	}
+	return data, nil
+}
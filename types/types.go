@@ -61,6 +61,16 @@ func (d Duration) Value() (driver.Value, error) {
 	return int64(d), nil
 }
 
+// UnmarshalText parses a duration such as 36h or 1h30m from raw text
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
 // MapStringString is an instance of map[string]string which implements the SQL
 // Valuer and Scanner interfaces, so it can be stored in a database.
 // Its SQL type should be integer.
@@ -136,6 +146,8 @@ func (m *MapStringStepStatus) Scan(src interface{}) error {
 			return fmt.Errorf("unable to parse map: %s", err)
 		}
 		*m = result
+	case nil:
+		*m = nil
 	default:
 		return fmt.Errorf("unknown type for map[string]*StepStatus")
 	}
@@ -163,6 +175,27 @@ func (m MapStringStepStatus) For(key string) *StepStatus {
 	return status
 }
 
+// Done tells whether all of the given steps have succeeded or have been
+// skipped
+func (m MapStringStepStatus) Done(keys ...string) bool {
+	for _, key := range keys {
+		if status := m[key]; status == nil || !status.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// Failed tells whether any of the steps has failed
+func (m MapStringStepStatus) Failed() bool {
+	for _, status := range m {
+		if status != nil && status.Status == Error {
+			return true
+		}
+	}
+	return false
+}
+
 // Status represents the state of an import step
 type Status int
 
@@ -203,6 +236,18 @@ func (m *StepStatus) Skip() {
 	m.Message = ""
 }
 
+// Reset sets the status to Incomplete
+func (m *StepStatus) Reset() {
+	m.Status = Incomplete
+	m.Message = ""
+}
+
+// Done tells whether the step has succeeded or has been skipped, so it
+// doesn't need to be done again
+func (m *StepStatus) Done() bool {
+	return m.Status == Success || m.Status == Skipped
+}
+
 // Errorf creates a string pointer to an error message
 func Errorf(message string, arguments ...interface{}) *string {
 	errorMessage := fmt.Sprintf(message, arguments...)